@@ -0,0 +1,243 @@
+package babyapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// APIKeyHeader is the default header used for API keys
+	APIKeyHeader = "X-API-Key"
+
+	sha256HashPrefix = "sha256:"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request doesn't have its type of credentials, so the
+	// next Authenticator is tried
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned by an Authenticator when the request's credentials are not valid
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is the identity of an authenticated request
+type Principal struct {
+	// ID is the API key's name, the Basic auth username, or the JWT subject
+	ID string
+	// Method is the type of authentication that was used, like "api_key", "basic", or "jwt"
+	Method string
+	// Roles are used by AuthorizationPolicy. They come from the JWT "roles" claim or the Roles maps of the other
+	// Authenticators
+	Roles []string
+	// Claims has the JWT claims. It is nil for other methods
+	Claims map[string]any
+}
+
+// HasRole returns true if the Principal has any of the roles
+func (p *Principal) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(p.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator gets the Principal for a request. It returns ErrNoCredentials if the request doesn't have credentials
+// that it handles
+type Authenticator interface {
+	Authenticate(*http.Request) (*Principal, error)
+}
+
+// AuthenticatorFunc allows using a function as an Authenticator
+type AuthenticatorFunc func(*http.Request) (*Principal, error)
+
+// Authenticate calls the function
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+// authValidator is implemented by Authenticators that can check their configuration when they are added to an API
+type authValidator interface {
+	validate() error
+}
+
+// authChallenger is implemented by Authenticators that set the WWW-Authenticate header on 401 responses
+type authChallenger interface {
+	challenge() string
+}
+
+// GetPrincipalFromContext returns the Principal stored by AuthenticationMiddleware or nil if there isn't one
+func GetPrincipalFromContext(ctx context.Context) *Principal {
+	principal, ok := ctx.Value(principalCtxKey).(*Principal)
+	if !ok {
+		return nil
+	}
+	return principal
+}
+
+// NewContextWithPrincipal stores the Principal in the context
+func NewContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey, principal)
+}
+
+// AuthenticationMiddleware tries each Authenticator in order and stores the first Principal in the request context.
+// Requests are rejected with 401 Unauthorized if none of the Authenticators succeed or if any of them finds invalid
+// credentials. Use it with AddMiddleware, AddIDMiddleware, or on individual routes. It panics if an Authenticator is
+// misconfigured, like a JWTAuthenticator without a Secret
+func AuthenticationMiddleware(authenticators ...Authenticator) func(http.Handler) http.Handler {
+	for _, authenticator := range authenticators {
+		if validator, ok := authenticator.(authValidator); ok {
+			err := validator.validate()
+			if err != nil {
+				panic(fmt.Sprintf("invalid authenticator: %v", err))
+			}
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, authenticator := range authenticators {
+				principal, err := authenticator.Authenticate(r)
+				if errors.Is(err, ErrNoCredentials) {
+					continue
+				}
+				if err != nil {
+					logger := GetLoggerFromContext(r.Context())
+					if logger != nil {
+						logger.Info("authentication failed", "error", err)
+					}
+					unauthorized(w, r, authenticators)
+					return
+				}
+
+				next.ServeHTTP(w, r.WithContext(NewContextWithPrincipal(r.Context(), principal)))
+				return
+			}
+
+			unauthorized(w, r, authenticators)
+		})
+	}
+}
+
+// SetAuthenticators requires authentication for all of the API's routes, including child APIs
+func (a *API[T]) SetAuthenticators(authenticators ...Authenticator) *API[T] {
+	return a.AddMiddleware(AuthenticationMiddleware(authenticators...))
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, authenticators []Authenticator) {
+	for _, authenticator := range authenticators {
+		if challenger, ok := authenticator.(authChallenger); ok {
+			w.Header().Add("WWW-Authenticate", challenger.challenge())
+		}
+	}
+
+	_ = Render(w, r, ErrUnauthorized)
+}
+
+// APIKeyAuthenticator authenticates requests with a static API key in a header
+type APIKeyAuthenticator struct {
+	// Header is the request header with the API key. The default is X-API-Key
+	Header string
+	// Keys maps a name for each key, used as the Principal ID, to the key
+	Keys map[string]string
+	// Roles maps key names to the Principal's roles
+	Roles map[string][]string
+}
+
+// Authenticate compares the header to every key in constant time
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	header := a.Header
+	if header == "" {
+		header = APIKeyHeader
+	}
+
+	key := r.Header.Get(header)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	var principal *Principal
+	for name, validKey := range a.Keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(validKey)) == 1 {
+			principal = &Principal{ID: name, Method: "api_key", Roles: a.Roles[name]}
+		}
+	}
+
+	if principal == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return principal, nil
+}
+
+// BasicAuthenticator authenticates requests with HTTP Basic auth
+type BasicAuthenticator struct {
+	// Realm is used in the WWW-Authenticate header
+	Realm string
+	// Users maps usernames to password hashes created with HashPasswordBcrypt or HashPasswordSHA256
+	Users map[string]string
+	// Roles maps usernames to the Principal's roles
+	Roles map[string][]string
+}
+
+// Authenticate checks the username and password against the stored hash
+func (a *BasicAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	hash, ok := a.Users[username]
+	if !ok || !CheckPasswordHash(hash, password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Principal{ID: username, Method: "basic", Roles: a.Roles[username]}, nil
+}
+
+func (a *BasicAuthenticator) challenge() string {
+	realm := a.Realm
+	if realm == "" {
+		realm = "babyapi"
+	}
+	return fmt.Sprintf("Basic realm=%q", realm)
+}
+
+// HashPasswordBcrypt creates a bcrypt password hash for BasicAuthenticator
+func HashPasswordBcrypt(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// HashPasswordSHA256 creates a "sha256:" prefixed hex SHA-256 password hash for BasicAuthenticator. bcrypt is
+// preferred, but SHA-256 is faster and can be used for generated passwords with high entropy
+func HashPasswordSHA256(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return sha256HashPrefix + hex.EncodeToString(sum[:])
+}
+
+// CheckPasswordHash checks the password against a hash from HashPasswordBcrypt or HashPasswordSHA256
+func CheckPasswordHash(hash, password string) bool {
+	if hexHash, ok := strings.CutPrefix(hash, sha256HashPrefix); ok {
+		expected, err := hex.DecodeString(hexHash)
+		if err != nil {
+			return false
+		}
+		sum := sha256.Sum256([]byte(password))
+		return subtle.ConstantTimeCompare(expected, sum[:]) == 1
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,236 @@
+package babyapi_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func requireStatus(t *testing.T, err error, status int) {
+	t.Helper()
+
+	var errResp *babyapi.ErrResponse
+	require.ErrorAs(t, err, &errResp)
+	require.Equal(t, status, errResp.HTTPStatusCode)
+}
+
+func TestAuthentication(t *testing.T) {
+	secret := []byte("secret")
+	bcryptHash, err := babyapi.HashPasswordBcrypt("password")
+	require.NoError(t, err)
+
+	api := babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} })
+	api.SetAuthenticators(
+		&babyapi.APIKeyAuthenticator{Keys: map[string]string{"service": "api-key"}},
+		&babyapi.BasicAuthenticator{Users: map[string]string{
+			"bcrypt": bcryptHash,
+			"sha256": babyapi.HashPasswordSHA256("password"),
+		}},
+		&babyapi.JWTAuthenticator{Secret: secret, Issuer: "babyapi", Audience: "memos"},
+	)
+	api.AddBeforeCreateHook(func(r *http.Request, _, memo *Memo) (*Memo, *babyapi.ErrResponse) {
+		principal := babyapi.GetPrincipalFromContext(r.Context())
+		memo.Text = fmt.Sprintf("%s:%s", principal.Method, principal.ID)
+		return memo, nil
+	})
+
+	client, stop := babytest.NewTestClient[*Memo](t, api)
+	defer stop()
+
+	validToken, err := babyapi.SignJWT(secret, map[string]any{
+		"sub": "user-1",
+		"iss": "babyapi",
+		"aud": []string{"other", "memos"},
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	require.NoError(t, err)
+
+	t.Run("Successful", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			client   *babyapi.Client[*Memo]
+			expected string
+		}{
+			{"APIKey", api.Client(client.Address).SetAPIKey("api-key"), "api_key:service"},
+			{"BasicBcrypt", api.Client(client.Address).SetBasicAuth("bcrypt", "password"), "basic:bcrypt"},
+			{"BasicSHA256", api.Client(client.Address).SetBasicAuth("sha256", "password"), "basic:sha256"},
+			{"JWT", api.Client(client.Address).SetBearerToken(validToken), "jwt:user-1"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp, err := tt.client.Post(context.Background(), &Memo{})
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, resp.Data.Text)
+			})
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		signToken := func(claims map[string]any) string {
+			token, err := babyapi.SignJWT(secret, claims)
+			require.NoError(t, err)
+			return token
+		}
+
+		tests := []struct {
+			name   string
+			client *babyapi.Client[*Memo]
+		}{
+			{"NoCredentials", client},
+			{"InvalidAPIKey", api.Client(client.Address).SetAPIKey("wrong")},
+			{"WrongPassword", api.Client(client.Address).SetBasicAuth("bcrypt", "wrong")},
+			{"UnknownUser", api.Client(client.Address).SetBasicAuth("unknown", "password")},
+			{"WrongSecret", api.Client(client.Address).SetBearerToken(func() string {
+				token, err := babyapi.SignJWT([]byte("wrong"), map[string]any{"iss": "babyapi", "aud": "memos"})
+				require.NoError(t, err)
+				return token
+			}())},
+			{"Expired", api.Client(client.Address).SetBearerToken(signToken(map[string]any{
+				"iss": "babyapi", "aud": "memos", "exp": time.Now().Add(-time.Minute).Unix(),
+			}))},
+			{"NotBefore", api.Client(client.Address).SetBearerToken(signToken(map[string]any{
+				"iss": "babyapi", "aud": "memos", "nbf": time.Now().Add(time.Minute).Unix(),
+			}))},
+			{"WrongIssuer", api.Client(client.Address).SetBearerToken(signToken(map[string]any{"iss": "other", "aud": "memos"}))},
+			{"WrongAudience", api.Client(client.Address).SetBearerToken(signToken(map[string]any{"iss": "babyapi", "aud": "other"}))},
+			// The "none" algorithm is rejected even without a signature
+			{"NoneAlgorithm", api.Client(client.Address).SetBearerToken("eyJhbGciOiJub25lIn0.eyJzdWIiOiJ1c2VyLTEifQ.")},
+			{"Malformed", api.Client(client.Address).SetBearerToken("not-a-token")},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := tt.client.GetAll(context.Background(), "")
+				requireStatus(t, err, http.StatusUnauthorized)
+			})
+		}
+	})
+
+	t.Run("ChallengeHeaders", func(t *testing.T) {
+		resp, err := http.Get(client.Address + "/memos")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		require.Equal(t, []string{`Basic realm="babyapi"`, "Bearer"}, resp.Header.Values("WWW-Authenticate"))
+	})
+
+	t.Run("RequestEditorOverridesCredentials", func(t *testing.T) {
+		_, err := api.Client(client.Address).
+			SetAPIKey("api-key").
+			SetRequestEditor(func(r *http.Request) error {
+				r.Header.Set(babyapi.APIKeyHeader, "wrong")
+				return nil
+			}).
+			GetAll(context.Background(), "")
+		requireStatus(t, err, http.StatusUnauthorized)
+	})
+}
+
+func TestAuthenticationPerRoute(t *testing.T) {
+	api := babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} })
+	api.AddCustomRoute(chi.Route{
+		Pattern: "/admin",
+		Handlers: map[string]http.Handler{
+			http.MethodGet: babyapi.AuthenticationMiddleware(
+				babyapi.AuthenticatorFunc(func(r *http.Request) (*babyapi.Principal, error) {
+					if r.Header.Get("X-Admin") == "" {
+						return nil, babyapi.ErrNoCredentials
+					}
+					if r.Header.Get("X-Admin") != "true" {
+						return nil, errors.New("not an admin")
+					}
+					return &babyapi.Principal{ID: "admin", Method: "custom"}, nil
+				}),
+			)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(babyapi.GetPrincipalFromContext(r.Context()).ID))
+			})),
+		},
+	})
+
+	client, stop := babytest.NewTestClient[*Memo](t, api)
+	defer stop()
+
+	t.Run("OtherRoutesAreNotAuthenticated", func(t *testing.T) {
+		_, err := client.GetAll(context.Background(), "")
+		require.NoError(t, err)
+	})
+
+	for _, tt := range []struct {
+		header         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"", http.StatusUnauthorized, ""},
+		{"false", http.StatusUnauthorized, ""},
+		{"true", http.StatusOK, "admin"},
+	} {
+		t.Run("Admin_"+tt.header, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, client.Address+"/memos/admin", http.NoBody)
+			require.NoError(t, err)
+			req.Header.Set("X-Admin", tt.header)
+
+			resp, err := client.MakeRequest(req, http.StatusOK)
+			if tt.expectedStatus != http.StatusOK {
+				requireStatus(t, err, tt.expectedStatus)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedBody, resp.Body)
+		})
+	}
+}
+
+func TestJWTAuthenticatorConfig(t *testing.T) {
+	t.Run("EmptySecret", func(t *testing.T) {
+		require.PanicsWithValue(t, "invalid authenticator: JWTAuthenticator requires a Secret", func() {
+			babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} }).
+				SetAuthenticators(&babyapi.JWTAuthenticator{})
+		})
+
+		// Tokens signed with an empty key are rejected if the authenticator is used directly
+		token, err := babyapi.SignJWT(nil, map[string]any{"sub": "attacker", "roles": "admin"})
+		require.NoError(t, err)
+
+		r, err := http.NewRequest(http.MethodGet, "/", http.NoBody)
+		require.NoError(t, err)
+		r.Header.Set("Authorization", "Bearer "+token)
+
+		_, err = (&babyapi.JWTAuthenticator{}).Authenticate(r)
+		require.ErrorIs(t, err, babyapi.ErrInvalidCredentials)
+	})
+
+	t.Run("AlgorithmIsPinned", func(t *testing.T) {
+		secret := []byte("secret")
+
+		// SignJWT only uses HS256, so the HS512 token is created here
+		encode := base64.RawURLEncoding.EncodeToString
+		unsigned := encode([]byte(`{"alg":"HS512","typ":"JWT"}`)) + "." + encode([]byte(`{"sub":"user-1"}`))
+		mac := hmac.New(sha512.New, secret)
+		mac.Write([]byte(unsigned))
+		token := unsigned + "." + encode(mac.Sum(nil))
+
+		r, err := http.NewRequest(http.MethodGet, "/", http.NoBody)
+		require.NoError(t, err)
+		r.Header.Set("Authorization", "Bearer "+token)
+
+		_, err = (&babyapi.JWTAuthenticator{Secret: secret}).Authenticate(r)
+		require.ErrorIs(t, err, babyapi.ErrInvalidCredentials)
+
+		principal, err := (&babyapi.JWTAuthenticator{Secret: secret, Algorithm: "HS512"}).Authenticate(r)
+		require.NoError(t, err)
+		require.Equal(t, "user-1", principal.ID)
+	})
+}
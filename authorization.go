@@ -0,0 +1,218 @@
+package babyapi
+
+import (
+	"net/http"
+	"slices"
+)
+
+// Verb identifies an API operation in an AuthorizationPolicy
+type Verb string
+
+const (
+	// VerbGetAll is used for GET /base and the other collection routes: _count, _aggregate, _search, events, and ws
+	VerbGetAll Verb = "GetAll"
+	// VerbGet is used for GET /base/{ID} and /base/{ID}/events
+	VerbGet Verb = "Get"
+	// VerbPost is used for POST /base
+	VerbPost Verb = "Post"
+	// VerbPut is used for PUT /base/{ID}
+	VerbPut Verb = "Put"
+	// VerbPatch is used for PATCH /base/{ID}
+	VerbPatch Verb = "Patch"
+	// VerbDelete is used for DELETE /base/{ID}
+	VerbDelete Verb = "Delete"
+)
+
+// RoleAnyone allows a Verb without a Principal when it is included in AuthorizationPolicy.Roles
+const RoleAnyone = "*"
+
+// Owned is implemented by resources that record their creator. When an API has an AuthorizationPolicy, the owner is
+// set to the Principal ID when the resource is created and kept on updates. Only the owner and admins can modify the
+// resource
+type Owned interface {
+	GetOwnerID() string
+	SetOwnerID(string)
+}
+
+// AuthorizationPolicy declares which Principals can use an API. It relies on a Principal from AuthenticationMiddleware
+// and denies requests with ErrForbidden, or ErrUnauthorized if there is no Principal
+type AuthorizationPolicy[T Resource] struct {
+	// Roles lists the roles that can use each Verb. Any Principal can use Verbs that are not in the map. Include
+	// RoleAnyone to allow requests without a Principal
+	Roles map[Verb][]string
+
+	// AdminRoles can read and modify all resources regardless of ownership
+	AdminRoles []string
+
+	// CanRead decides if a Principal can see a resource in Get and GetAll responses and change events. By default,
+	// resources that implement Owned are only visible to their owner and admins, and other resources are visible to
+	// anyone who can use the Verb. The Principal is nil for requests allowed by RoleAnyone
+	CanRead func(*Principal, T) bool
+
+	// InheritParent requires access to the parent API's resource for all requests to this child API. Reads need to be
+	// able to read the parent resource and writes need to be able to modify it
+	InheritParent bool
+}
+
+// SetAuthorizationPolicy enables role and ownership checks for the API's default routes, including the search, change
+// stream, and WebSocket routes. Lists are filtered to only include resources the Principal can read. Expanding a child
+// API's resources with the expand query parameter uses the child's GetAll rules and filter, so the request fails if
+// the Principal can't list them.
+//
+// The policy is not applied to routes added with AddCustomRoute, AddCustomIDRoute, AddCustomRootRoute, or
+// AddServerSentEventHandler because babyapi can't know which Verb they use. Wrap these handlers with Authorize to apply
+// the same rules. Webhook routes are protected by WebhookConfig.Authorizer instead of the policy
+func (a *API[T]) SetAuthorizationPolicy(policy AuthorizationPolicy[T]) *API[T] {
+	a.authorization = &policy
+	return a
+}
+
+// Authorize creates middleware that checks the Verb's roles and, for routes with a resource ID, ownership of the
+// requested resource
+func (a *API[T]) Authorize(verb Verb) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return a.authorized(verb, next.ServeHTTP)
+	}
+}
+
+// authorized wraps the handler with the authorization check if the API has a policy
+func (a *API[T]) authorized(verb Verb, h http.HandlerFunc) http.HandlerFunc {
+	if h == nil || a.authorization == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		httpErr := a.authorize(r, verb)
+		if httpErr != nil {
+			logger := GetLoggerFromContext(r.Context())
+			if logger != nil {
+				logger.Info("request is not authorized", "verb", verb, "error", httpErr.Error())
+			}
+			_ = Render(w, r, httpErr)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+func (a *API[T]) authorize(r *http.Request, verb Verb) *ErrResponse {
+	principal := GetPrincipalFromContext(r.Context())
+
+	roles, ok := a.authorization.Roles[verb]
+	switch {
+	case ok && slices.Contains(roles, RoleAnyone):
+	case principal == nil:
+		return ErrUnauthorized
+	case ok && !principal.HasRole(roles...):
+		return ErrForbidden
+	}
+
+	write := verb != VerbGet && verb != VerbGetAll
+	if verb == VerbGetAll || verb == VerbPost {
+		return a.authorizeParent(r, write)
+	}
+
+	return a.authorizeResource(r, write)
+}
+
+// authorizeResource checks the Principal's access to the resource in the request context. This is also used by child
+// APIs to check access to their parent resource
+func (a *API[T]) authorizeResource(r *http.Request, write bool) *ErrResponse {
+	if a.authorization == nil {
+		return nil
+	}
+
+	principal := GetPrincipalFromContext(r.Context())
+
+	// The resource is missing when PUT creates a new resource
+	resource, err := a.GetResourceFromContext(r.Context())
+	if err == nil {
+		allowed := a.canRead(principal, resource)
+		if write {
+			allowed = a.canModify(principal, resource)
+		}
+		if !allowed {
+			return ErrForbidden
+		}
+	}
+
+	return a.authorizeParent(r, write)
+}
+
+func (a *API[T]) authorizeParent(r *http.Request, write bool) *ErrResponse {
+	if !a.authorization.InheritParent || a.parent == nil {
+		return nil
+	}
+	return a.parent.authorizeResource(r, write)
+}
+
+// requestFilter combines the GetAll filter with the authorization policy so lists only include resources that the
+// Principal can read
+func (a *API[T]) requestFilter(r *http.Request) FilterFunc[T] {
+	filter := a.getAllFilter(r)
+	if a.authorization == nil {
+		return filter
+	}
+
+	principal := GetPrincipalFromContext(r.Context())
+	if a.isAdmin(principal) {
+		return filter
+	}
+
+	return func(resource T) bool {
+		return filter(resource) && a.canRead(principal, resource)
+	}
+}
+
+// setOwner records the Principal as the owner of new resources and keeps the existing owner on updates so it can't be
+// set or changed by the request body
+func (a *API[T]) setOwner(r *http.Request, previous, resource T) {
+	if a.authorization == nil {
+		return
+	}
+
+	owned, ok := any(resource).(Owned)
+	if !ok {
+		return
+	}
+
+	if previousOwned, ok := any(previous).(Owned); ok && previous != *new(T) {
+		owned.SetOwnerID(previousOwned.GetOwnerID())
+		return
+	}
+
+	// Resources created without a Principal, like when RoleAnyone is allowed, don't have an owner
+	var owner string
+	if principal := GetPrincipalFromContext(r.Context()); principal != nil {
+		owner = principal.ID
+	}
+	owned.SetOwnerID(owner)
+}
+
+func (a *API[T]) canRead(principal *Principal, resource T) bool {
+	if a.isAdmin(principal) {
+		return true
+	}
+	if a.authorization.CanRead != nil {
+		return a.authorization.CanRead(principal, resource)
+	}
+	return isOwner(principal, resource)
+}
+
+func (a *API[T]) canModify(principal *Principal, resource T) bool {
+	return a.isAdmin(principal) || isOwner(principal, resource)
+}
+
+func (a *API[T]) isAdmin(principal *Principal) bool {
+	return principal != nil && principal.HasRole(a.authorization.AdminRoles...)
+}
+
+// isOwner is true for resources that don't implement Owned. Resources without an owner can only be accessed by admins
+func isOwner[T Resource](principal *Principal, resource T) bool {
+	owned, ok := any(resource).(Owned)
+	if !ok {
+		return true
+	}
+	return principal != nil && owned.GetOwnerID() != "" && owned.GetOwnerID() == principal.ID
+}
@@ -0,0 +1,246 @@
+package babyapi_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/stretchr/testify/require"
+)
+
+type Ticket struct {
+	babyapi.DefaultResource
+	Text  string `json:"text"`
+	Owner string `json:"owner"`
+}
+
+func (tk *Ticket) GetOwnerID() string {
+	return tk.Owner
+}
+
+func (tk *Ticket) SetOwnerID(owner string) {
+	tk.Owner = owner
+}
+
+type Comment struct {
+	babyapi.DefaultResource
+	Text string `json:"text"`
+}
+
+func TestAuthorization(t *testing.T) {
+	ticketAPI := babyapi.NewAPI[*Ticket]("Tickets", "/tickets", func() *Ticket { return &Ticket{} }).EnableMergePatch()
+	ticketAPI.SetAuthenticators(&babyapi.APIKeyAuthenticator{
+		Keys: map[string]string{
+			"alice":  "alice-key",
+			"bob":    "bob-key",
+			"admin":  "admin-key",
+			"viewer": "viewer-key",
+		},
+		Roles: map[string][]string{
+			"alice": {"writer"},
+			"bob":   {"writer"},
+			"admin": {"writer", "admin"},
+		},
+	})
+	ticketAPI.SetAuthorizationPolicy(babyapi.AuthorizationPolicy[*Ticket]{
+		Roles: map[babyapi.Verb][]string{
+			babyapi.VerbPost:   {"writer"},
+			babyapi.VerbDelete: {"admin"},
+		},
+		AdminRoles: []string{"admin"},
+	})
+
+	commentAPI := babyapi.NewAPI[*Comment]("Comments", "/comments", func() *Comment { return &Comment{} })
+	commentAPI.SetAuthorizationPolicy(babyapi.AuthorizationPolicy[*Comment]{InheritParent: true})
+	ticketAPI.AddNestedAPI(commentAPI)
+
+	client, stop := babytest.NewTestClient[*Ticket](t, ticketAPI)
+	defer stop()
+
+	clientFor := func(key string) *babyapi.Client[*Ticket] {
+		return ticketAPI.Client(client.Address).SetAPIKey(key)
+	}
+	alice := clientFor("alice-key")
+	bob := clientFor("bob-key")
+	admin := clientFor("admin-key")
+	viewer := clientFor("viewer-key")
+
+	ctx := context.Background()
+
+	var aliceTicket, bobTicket *Ticket
+	t.Run("CreateSetsOwner", func(t *testing.T) {
+		resp, err := alice.Post(ctx, &Ticket{Text: "alice's ticket", Owner: "bob"})
+		require.NoError(t, err)
+		aliceTicket = resp.Data
+		require.Equal(t, "alice", aliceTicket.Owner)
+
+		resp, err = bob.Post(ctx, &Ticket{Text: "bob's ticket"})
+		require.NoError(t, err)
+		bobTicket = resp.Data
+		require.Equal(t, "bob", bobTicket.Owner)
+	})
+
+	t.Run("RoleIsRequiredToCreate", func(t *testing.T) {
+		_, err := viewer.Post(ctx, &Ticket{Text: "viewer's ticket"})
+		requireStatus(t, err, http.StatusForbidden)
+	})
+
+	t.Run("ListIsFiltered", func(t *testing.T) {
+		for _, tt := range []struct {
+			name     string
+			client   *babyapi.Client[*Ticket]
+			expected []string
+		}{
+			{"Alice", alice, []string{aliceTicket.GetID()}},
+			{"Bob", bob, []string{bobTicket.GetID()}},
+			{"Admin", admin, []string{aliceTicket.GetID(), bobTicket.GetID()}},
+			{"Viewer", viewer, []string{}},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				resp, err := tt.client.GetAll(ctx, "")
+				require.NoError(t, err)
+
+				ids := []string{}
+				for _, ticket := range resp.Data.Items {
+					ids = append(ids, ticket.GetID())
+				}
+				require.ElementsMatch(t, tt.expected, ids)
+			})
+		}
+	})
+
+	t.Run("OnlyOwnerCanRead", func(t *testing.T) {
+		_, err := alice.Get(ctx, aliceTicket.GetID())
+		require.NoError(t, err)
+
+		_, err = admin.Get(ctx, aliceTicket.GetID())
+		require.NoError(t, err)
+
+		_, err = bob.Get(ctx, aliceTicket.GetID())
+		requireStatus(t, err, http.StatusForbidden)
+	})
+
+	t.Run("OnlyOwnerOrAdminCanModify", func(t *testing.T) {
+		_, err := bob.Patch(ctx, aliceTicket.GetID(), &Ticket{DefaultResource: aliceTicket.DefaultResource, Text: "changed by bob"})
+		requireStatus(t, err, http.StatusForbidden)
+
+		_, err = bob.Put(ctx, &Ticket{DefaultResource: aliceTicket.DefaultResource, Text: "changed by bob"})
+		requireStatus(t, err, http.StatusForbidden)
+
+		resp, err := alice.Patch(ctx, aliceTicket.GetID(), &Ticket{DefaultResource: aliceTicket.DefaultResource, Text: "changed by alice", Owner: "bob"})
+		require.NoError(t, err)
+		require.Equal(t, "changed by alice", resp.Data.Text)
+		require.Equal(t, "alice", resp.Data.Owner)
+
+		resp, err = admin.Put(ctx, &Ticket{DefaultResource: aliceTicket.DefaultResource, Text: "changed by admin"})
+		require.NoError(t, err)
+		require.Equal(t, "alice", resp.Data.Owner)
+	})
+
+	t.Run("DeleteRequiresAdminRole", func(t *testing.T) {
+		_, err := alice.Delete(ctx, aliceTicket.GetID())
+		requireStatus(t, err, http.StatusForbidden)
+
+		_, err = admin.Delete(ctx, bobTicket.GetID())
+		require.NoError(t, err)
+	})
+
+	t.Run("ChildInheritsParentAccess", func(t *testing.T) {
+		aliceComments := babyapi.NewSubClient[*Ticket, *Comment](alice, "/comments")
+		bobComments := babyapi.NewSubClient[*Ticket, *Comment](bob, "/comments")
+		adminComments := babyapi.NewSubClient[*Ticket, *Comment](admin, "/comments")
+
+		resp, err := aliceComments.Post(ctx, &Comment{Text: "comment"}, aliceTicket.GetID())
+		require.NoError(t, err)
+		comment := resp.Data
+
+		_, err = bobComments.Post(ctx, &Comment{Text: "comment"}, aliceTicket.GetID())
+		requireStatus(t, err, http.StatusForbidden)
+
+		_, err = bobComments.GetAll(ctx, "", aliceTicket.GetID())
+		requireStatus(t, err, http.StatusForbidden)
+
+		_, err = bobComments.Get(ctx, comment.GetID(), aliceTicket.GetID())
+		requireStatus(t, err, http.StatusForbidden)
+
+		_, err = adminComments.Get(ctx, comment.GetID(), aliceTicket.GetID())
+		require.NoError(t, err)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		_, err := client.GetAll(ctx, "")
+		requireStatus(t, err, http.StatusUnauthorized)
+	})
+}
+
+func TestAuthorizationAnyone(t *testing.T) {
+	api := babyapi.NewAPI[*Ticket]("Tickets", "/tickets", func() *Ticket { return &Ticket{} }).EnableMergePatch()
+	// Requests without the header don't have a Principal
+	api.AddMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user := r.Header.Get("X-User"); user != "" {
+				r = r.WithContext(babyapi.NewContextWithPrincipal(r.Context(), &babyapi.Principal{ID: user}))
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	api.SetAuthorizationPolicy(babyapi.AuthorizationPolicy[*Ticket]{
+		Roles: map[babyapi.Verb][]string{
+			babyapi.VerbGetAll: {babyapi.RoleAnyone},
+			babyapi.VerbGet:    {babyapi.RoleAnyone},
+			babyapi.VerbPost:   {babyapi.RoleAnyone},
+		},
+		CanRead: func(*babyapi.Principal, *Ticket) bool { return true },
+	})
+
+	client, stop := babytest.NewTestClient[*Ticket](t, api)
+	defer stop()
+
+	ctx := context.Background()
+
+	owner := api.Client(client.Address).SetRequestEditor(func(r *http.Request) error {
+		r.Header.Set("X-User", "owner")
+		return nil
+	})
+	other := api.Client(client.Address).SetRequestEditor(func(r *http.Request) error {
+		r.Header.Set("X-User", "other")
+		return nil
+	})
+
+	resp, err := owner.Post(ctx, &Ticket{Text: "public"})
+	require.NoError(t, err)
+	ticket := resp.Data
+
+	t.Run("EveryoneCanRead", func(t *testing.T) {
+		for _, c := range []*babyapi.Client[*Ticket]{other, client} {
+			list, err := c.GetAll(ctx, "")
+			require.NoError(t, err)
+			require.Len(t, list.Data.Items, 1)
+
+			_, err = c.Get(ctx, ticket.GetID())
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("OnlyOwnerCanModify", func(t *testing.T) {
+		_, err := client.Patch(ctx, ticket.GetID(), &Ticket{DefaultResource: ticket.DefaultResource, Text: "changed"})
+		requireStatus(t, err, http.StatusUnauthorized)
+
+		_, err = other.Patch(ctx, ticket.GetID(), &Ticket{DefaultResource: ticket.DefaultResource, Text: "changed"})
+		requireStatus(t, err, http.StatusForbidden)
+
+		_, err = owner.Patch(ctx, ticket.GetID(), &Ticket{DefaultResource: ticket.DefaultResource, Text: "changed"})
+		require.NoError(t, err)
+	})
+	t.Run("AnonymousCannotSetOwner", func(t *testing.T) {
+		resp, err := client.Post(ctx, &Ticket{Text: "anonymous", Owner: "owner"})
+		require.NoError(t, err)
+		require.Empty(t, resp.Data.Owner)
+
+		// Resources without an owner can only be modified by admins
+		_, err = owner.Patch(ctx, resp.Data.GetID(), &Ticket{DefaultResource: resp.Data.DefaultResource, Text: "claimed"})
+		requireStatus(t, err, http.StatusForbidden)
+	})
+}
@@ -2,11 +2,13 @@ package babyapi
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
 	"syscall"
 	"time"
 
@@ -50,11 +52,43 @@ type API[T Resource] struct {
 
 	onCreateOrUpdate func(*http.Request, T) *ErrResponse
 
+	hooks hooks[T]
+
 	parent relatedAPI
 
 	responseCodes map[string]int
 	serverCtx     context.Context
 
+	expandKeyName  string
+	maxExpandItems int
+	maxExpandDepth int
+
+	searchIndex *searchIndex[T]
+
+	openAPI openAPIConfig
+
+	encoders map[string]Encoder
+	decoders map[string]Decoder
+
+	problemDetails bool
+
+	crashReporter CrashReporter
+
+	webhooks *webhookManager
+
+	changes *BroadcastChannel[*resourceChange[T]]
+
+	sseHeartbeat time.Duration
+
+	pubSub *apiPubSub
+
+	webSocket bool
+
+	authorization *AuthorizationPolicy[T]
+
+	mergePatch bool
+	jsonPatch  bool
+
 	// GetAll is the handler for /base and returns an array of resources
 	GetAll http.HandlerFunc
 
@@ -73,12 +107,25 @@ type API[T Resource] struct {
 	// Delete is used to delete the resource at /base/{ID}
 	Delete http.HandlerFunc
 
+	// Count is the handler for /base/_count and returns the number of resources matching the GetAll filter. It is nil
+	// unless EnableCount is used
+	Count http.HandlerFunc
+
+	// Aggregate is the handler for /base/_aggregate and returns the number of resources matching the GetAll filter
+	// for each distinct value of the field in the groupBy query parameter. It is nil unless EnableAggregate is used
+	Aggregate http.HandlerFunc
+
 	rootAPI bool
 }
 
 // NewAPI initializes an API using the provided name, base URL path, and function to create a new instance of
-// the resource with defaults
+// the resource with defaults. It panics if the resource has invalid "validate" struct tags
 func NewAPI[T Resource](name, base string, instance func() T) *API[T] {
+	err := parseValidateTags(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		panic(fmt.Sprintf("invalid %s resource: %v", name, err))
+	}
+
 	api := &API[T]{
 		name,
 		base,
@@ -98,9 +145,29 @@ func NewAPI[T Resource](name, base string, instance func() T) *API[T] {
 		defaultBeforeAfter,
 		defaultBeforeAfter,
 		func(*http.Request, T) *ErrResponse { return nil },
+		hooks[T]{},
 		nil,
 		defaultResponseCodes(),
 		nil,
+		"",
+		defaultMaxExpandItems,
+		defaultMaxExpandDepth,
+		nil,
+		openAPIConfig{},
+		defaultEncoders(),
+		defaultDecoders(),
+		false,
+		nil,
+		nil,
+		nil,
+		0,
+		nil,
+		false,
+		nil,
+		false,
+		false,
+		nil,
+		nil,
 		nil,
 		nil,
 		nil,
@@ -270,6 +337,10 @@ func (a *API[T]) Serve(address string) {
 
 	signal.Notify(a.quit, os.Interrupt, syscall.SIGTERM)
 
+	if a.webhooks != nil {
+		go a.webhooks.run(a.serverCtx)
+	}
+
 	go func() {
 		<-a.quit
 		close(a.quit)
@@ -288,6 +359,7 @@ func (a *API[T]) Serve(address string) {
 		if err != nil {
 			log.Fatal(err)
 		}
+		a.closePubSub()
 		serverStopCtx()
 	}()
 
@@ -306,8 +378,12 @@ func (a *API[T]) Stop() {
 	<-a.serverCtx.Done()
 }
 
-// Done returns a channel that's closed when the API stops, similar to context.Done()
+// Done returns a channel that's closed when the API stops, similar to context.Done(). Nested APIs use their parent's
+// channel because they stop with it
 func (a *API[T]) Done() <-chan os.Signal {
+	if a.parent != nil {
+		return a.parent.Done()
+	}
 	return a.quit
 }
 
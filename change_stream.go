@@ -0,0 +1,177 @@
+package babyapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ChangeEventCreated is the server-sent event name used when a resource is created
+	ChangeEventCreated = "created"
+	// ChangeEventUpdated is the server-sent event name used when a resource is updated
+	ChangeEventUpdated = "updated"
+	// ChangeEventDeleted is the server-sent event name used when a resource is deleted
+	ChangeEventDeleted = "deleted"
+
+	changeStreamPath = "/events"
+)
+
+// resourceChange is broadcast to all change stream listeners. Each listener renders it based on its own request
+type resourceChange[T Resource] struct {
+	id       uint64
+	event    string
+	resource T
+}
+
+func (c *resourceChange[T]) withSequenceID(id uint64) any {
+	result := *c
+	result.id = id
+	return &result
+}
+
+// resourceChangeJSON is the encoded form of a resourceChange used when sending changes through a PubSub
+type resourceChangeJSON[T Resource] struct {
+	Event    string `json:"event"`
+	Resource T      `json:"resource"`
+}
+
+func (c *resourceChange[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(resourceChangeJSON[T]{c.event, c.resource})
+}
+
+func (c *resourceChange[T]) UnmarshalJSON(data []byte) error {
+	var change resourceChangeJSON[T]
+	err := json.Unmarshal(data, &change)
+	if err != nil {
+		return err
+	}
+
+	c.event, c.resource = change.Event, change.Resource
+	return nil
+}
+
+// EnableChangeStream adds GET /base/events and GET /base/{ID}/events endpoints which stream server-sent events
+// when resources are created, updated, or deleted. The event data is the resource after using the response wrapper.
+// If the listener accepts text/html or uses the "format=html" query parameter, HTMLer output is used instead of JSON.
+// Events have IDs, so reconnecting listeners using the Last-Event-ID header receive the changes they missed.
+// Listeners only receive changes to resources matching the GetAll filter and can use the "event" and "id" query
+// parameters to choose event names and resources
+func (a *API[T]) EnableChangeStream() *API[T] {
+	if a.rootAPI {
+		panic("change streams cannot be used with a root API")
+	}
+	if a.changes != nil {
+		return a
+	}
+
+	a.changes = &BroadcastChannel[*resourceChange[T]]{}
+	if a.pubSub != nil {
+		connectPubSub(a.changes, a.pubSub, changeStreamPath)
+	}
+
+	a.AddAfterCreateHook(a.changeStreamHook(ChangeEventCreated))
+	a.AddAfterUpdateHook(a.changeStreamHook(ChangeEventUpdated))
+	a.AddAfterDeleteHook(a.changeStreamHook(ChangeEventDeleted))
+
+	return a
+}
+
+func (a *API[T]) changeStreamHook(event string) Hook[T] {
+	return func(_ *http.Request, previous, resource T) (T, *ErrResponse) {
+		change := &resourceChange[T]{event: event, resource: resource}
+		if event == ChangeEventDeleted {
+			change.resource = previous
+		}
+
+		a.changes.SendToAll(change)
+
+		return resource, nil
+	}
+}
+
+// handleChangeStream streams changes to all resources, or only the requested resource when forID is true
+func (a *API[T]) handleChangeStream(forID bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := GetLoggerFromContext(r.Context())
+
+		html := acceptsHTMLEvents(r)
+
+		requestFilter := a.requestFilter(r)
+		filters := []FilterFunc[*resourceChange[T]]{
+			func(change *resourceChange[T]) bool { return requestFilter(change.resource) },
+			queryParamFilter(r, func(change *resourceChange[T]) (string, string) {
+				return change.event, change.resource.GetID()
+			}),
+		}
+		if forID {
+			id := a.GetIDParam(r)
+			filters = append(filters, func(change *resourceChange[T]) bool { return change.resource.GetID() == id })
+		}
+
+		changes, missed := getListenerForRequest(a.changes, r, filters...)
+		defer a.changes.RemoveListener(changes)
+
+		startEventStream(w)
+
+		writeChange := func(change *resourceChange[T]) {
+			data, err := a.renderChange(r, change.resource, html)
+			if err != nil {
+				logger.Error("error rendering change event", "error", err)
+				return
+			}
+
+			(&ServerSentEvent{ID: strconv.FormatUint(change.id, 10), Event: change.event, Data: data}).Write(w)
+		}
+
+		for _, change := range missed {
+			writeChange(change)
+		}
+
+		streamEvents(w, r, a.Done(), a.heartbeatInterval(), changes, writeChange)
+	}
+}
+
+// renderChange creates the event data using the response wrapper and Render, the same as responses. HTML is only used
+// if the response implements HTMLer
+func (a *API[T]) renderChange(r *http.Request, resource T, html bool) (string, error) {
+	if !html {
+		data, err := a.renderJSON(r, resource)
+		return string(data), err
+	}
+
+	resp := a.responseWrapper(resource)
+	err := renderer(&discardResponseWriter{}, r, resp)
+	if err != nil {
+		return "", fmt.Errorf("error rendering resource: %w", err)
+	}
+
+	if htmler, ok := resp.(HTMLer); ok {
+		return htmler.HTML(r), nil
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return "", fmt.Errorf("error encoding resource: %w", err)
+	}
+	return string(data), nil
+}
+
+// acceptsHTMLEvents checks if a change stream listener wants HTML. EventSource can't set the Accept header, so the
+// format query parameter can also be used
+func acceptsHTMLEvents(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "html" {
+		return true
+	}
+
+	for _, mediaType := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ = strings.Cut(mediaType, ";")
+		if strings.TrimSpace(mediaType) == "text/html" {
+			return true
+		}
+	}
+
+	return false
+}
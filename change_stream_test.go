@@ -0,0 +1,188 @@
+package babyapi_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/require"
+)
+
+type Memo struct {
+	babyapi.DefaultResource
+	Text string `json:"text"`
+}
+
+func (m *Memo) HTML(*http.Request) string {
+	return fmt.Sprintf("<p>%s</p>", m.Text)
+}
+
+type memoResponse struct {
+	*Memo
+	Length int `json:"length"`
+}
+
+// Render sets the length so events only include it if they are rendered like responses
+func (mr *memoResponse) Render(http.ResponseWriter, *http.Request) error {
+	mr.Length = len(mr.Text)
+	return nil
+}
+
+// listenForEvents connects to the event stream and returns a channel of "event: <name> data: <data>" strings
+func listenForEvents(t *testing.T, ctx context.Context, url string, header http.Header) <-chan string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	require.NoError(t, err)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := make(chan string)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+
+		var event []string
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				events <- strings.Join(event, " ")
+				event = nil
+				continue
+			}
+			event = append(event, line)
+		}
+	}()
+
+	return events
+}
+
+func nextEvent(t *testing.T, events <-chan string) string {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+		return ""
+	}
+}
+
+func TestChangeStream(t *testing.T) {
+	api := babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} }).EnableChangeStream()
+	api.SetResponseWrapper(func(m *Memo) render.Renderer {
+		return &memoResponse{Memo: m}
+	})
+
+	client, stop := babytest.NewTestClient[*Memo](t, api)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	memo := &Memo{DefaultResource: babyapi.NewDefaultResource(), Text: "first"}
+	require.NoError(t, api.Storage.Set(memo))
+
+	allEvents := listenForEvents(t, ctx, client.Address+"/memos/events", nil)
+	htmlEvents := listenForEvents(t, ctx, client.Address+"/memos/events?format=html", nil)
+	acceptHTMLEvents := listenForEvents(t, ctx, client.Address+"/memos/events", http.Header{"Accept": {"text/html"}})
+	memoEvents := listenForEvents(t, ctx, client.Address+"/memos/"+memo.GetID()+"/events", nil)
+
+	t.Run("Created", func(t *testing.T) {
+		resp, err := client.Post(ctx, &Memo{Text: "new"})
+		require.NoError(t, err)
+
+		require.Equal(t, fmt.Sprintf(`event: created data: {"id":"%s","text":"new","length":3} id: 1`, resp.Data.GetID()), nextEvent(t, allEvents))
+		require.Equal(t, "event: created data: <p>new</p> id: 1", nextEvent(t, htmlEvents))
+		require.Equal(t, "event: created data: <p>new</p> id: 1", nextEvent(t, acceptHTMLEvents))
+	})
+
+	t.Run("UpdatedAndDeleted", func(t *testing.T) {
+		_, err := client.Put(ctx, &Memo{DefaultResource: memo.DefaultResource, Text: "updated"})
+		require.NoError(t, err)
+
+		_, err = client.Delete(ctx, memo.GetID())
+		require.NoError(t, err)
+
+		expectedUpdate := fmt.Sprintf(`event: updated data: {"id":"%s","text":"updated","length":7} id: 2`, memo.GetID())
+		expectedDelete := fmt.Sprintf(`event: deleted data: {"id":"%s","text":"updated","length":7} id: 3`, memo.GetID())
+
+		require.Equal(t, expectedUpdate, nextEvent(t, allEvents))
+		require.Equal(t, expectedDelete, nextEvent(t, allEvents))
+
+		// The resource stream only includes events for its resource, so the created event is skipped
+		require.Equal(t, expectedUpdate, nextEvent(t, memoEvents))
+		require.Equal(t, expectedDelete, nextEvent(t, memoEvents))
+	})
+
+	t.Run("ReplayWithLastEventID", func(t *testing.T) {
+		replayed := listenForEvents(t, ctx, client.Address+"/memos/events", http.Header{"Last-Event-ID": {"1"}})
+
+		require.Contains(t, nextEvent(t, replayed), "event: updated")
+		require.Contains(t, nextEvent(t, replayed), "event: deleted")
+
+		_, err := client.Post(ctx, &Memo{Text: "live"})
+		require.NoError(t, err)
+		require.Contains(t, nextEvent(t, replayed), `"text":"live","length":4} id: 4`)
+	})
+
+	t.Run("QueryFilters", func(t *testing.T) {
+		deleted := listenForEvents(t, ctx, client.Address+"/memos/events?event=deleted", nil)
+
+		created, err := client.Post(ctx, &Memo{Text: "filtered"})
+		require.NoError(t, err)
+
+		byID := listenForEvents(t, ctx, client.Address+"/memos/events?id="+created.Data.GetID(), nil)
+
+		_, err = client.Post(ctx, &Memo{Text: "other"})
+		require.NoError(t, err)
+		_, err = client.Delete(ctx, created.Data.GetID())
+		require.NoError(t, err)
+
+		require.Contains(t, nextEvent(t, deleted), `event: deleted data: {"id":"`+created.Data.GetID())
+		require.Contains(t, nextEvent(t, byID), `event: deleted data: {"id":"`+created.Data.GetID())
+	})
+
+	t.Run("ResourceNotFound", func(t *testing.T) {
+		resp, err := http.Get(client.Address + "/memos/DoesNotExist/events")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestChangeStreamUsesGetAllFilter(t *testing.T) {
+	api := babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} }).EnableChangeStream()
+	api.SetGetAllFilter(func(r *http.Request) babyapi.FilterFunc[*Memo] {
+		text := r.URL.Query().Get("text")
+		return func(m *Memo) bool {
+			return text == "" || m.Text == text
+		}
+	})
+
+	client, stop := babytest.NewTestClient[*Memo](t, api)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := listenForEvents(t, ctx, client.Address+"/memos/events?text=match", nil)
+
+	_, err := client.Post(ctx, &Memo{Text: "skip"})
+	require.NoError(t, err)
+	_, err = client.Post(ctx, &Memo{Text: "match"})
+	require.NoError(t, err)
+
+	require.Contains(t, nextEvent(t, events), `"text":"match"`)
+}
@@ -2,6 +2,9 @@ package babyapi
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -33,22 +36,52 @@ func (a *API[T]) RunCLI() {
 	var pretty bool
 	var headers stringSliceFlag
 	var query string
+	var apiKey, user, token string
 	flag.StringVar(&bindAddress, "bindAddress", "", "Address and port to bind to for example :8080 for port only, localhost:8080 or 172.0.0.1:8080")
 	flag.StringVar(&address, "address", "http://localhost:8080", "server address for client")
 	flag.BoolVar(&pretty, "pretty", true, "pretty print JSON if enabled")
 	flag.Var(&headers, "H", "add headers to request")
 	flag.StringVar(&query, "q", "", "add query parameters to request")
+	flag.StringVar(&apiKey, "api-key", os.Getenv("BABYAPI_API_KEY"), "API key for requests (env BABYAPI_API_KEY)")
+	flag.StringVar(&user, "user", os.Getenv("BABYAPI_USER"), "username:password for Basic auth (env BABYAPI_USER)")
+	flag.StringVar(&token, "token", os.Getenv("BABYAPI_TOKEN"), "bearer token for requests (env BABYAPI_TOKEN)")
 
 	flag.Parse()
 
 	args := flag.Args()
 
-	err := a.RunWithArgs(os.Stdout, args, bindAddress, address, pretty, headers, query)
+	credentials, err := credentialHeaders(apiKey, user, token)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return
+	}
+	// Credentials are added first so -H can still override them
+	headers = append(credentials, headers...)
+
+	err = a.RunWithArgs(os.Stdout, args, bindAddress, address, pretty, headers, query)
 	if err != nil {
 		fmt.Printf("error: %v\n", err)
 	}
 }
 
+// credentialHeaders converts the CLI's credential flags to headers in the same format as -H
+func credentialHeaders(apiKey, user, token string) ([]string, error) {
+	headers := []string{}
+	if apiKey != "" {
+		headers = append(headers, fmt.Sprintf("%s: %s", APIKeyHeader, apiKey))
+	}
+	if user != "" {
+		if !strings.Contains(user, ":") {
+			return nil, errors.New("invalid user: expected username:password")
+		}
+		headers = append(headers, "Authorization: Basic "+base64.StdEncoding.EncodeToString([]byte(user)))
+	}
+	if token != "" {
+		headers = append(headers, "Authorization: Bearer "+token)
+	}
+	return headers, nil
+}
+
 func (a *API[T]) RunWithArgs(out io.Writer, args []string, bindAddress string, address string, pretty bool, headers []string, query string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("at least one argument required")
@@ -59,9 +92,28 @@ func (a *API[T]) RunWithArgs(out io.Writer, args []string, bindAddress string, a
 		return nil
 	}
 
+	if args[0] == "openapi" {
+		return a.printOpenAPI(out, pretty)
+	}
+
 	return a.runClientCLI(out, args, address, pretty, headers, query)
 }
 
+// printOpenAPI writes the generated OpenAPI document so it can be created without running the server
+func (a *API[T]) printOpenAPI(out io.Writer, pretty bool) error {
+	encoder := json.NewEncoder(out)
+	if pretty {
+		encoder.SetIndent("", "\t")
+	}
+
+	err := encoder.Encode(a.OpenAPI())
+	if err != nil {
+		return fmt.Errorf("error encoding OpenAPI document: %w", err)
+	}
+
+	return nil
+}
+
 func (a *API[T]) runClientCLI(out io.Writer, args []string, address string, pretty bool, headers []string, query string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("at least two arguments required")
@@ -137,7 +189,15 @@ func (c *Client[T]) RunFromCLI(args []string, headers []string, query string) (P
 			return fmt.Errorf("error parsing query string: %w", err)
 		}
 
-		r.URL.RawQuery = params.Encode()
+		// Keep existing query params that are set by the client, like the search query
+		existing := r.URL.Query()
+		for key, values := range params {
+			for _, v := range values {
+				existing.Add(key, v)
+			}
+		}
+
+		r.URL.RawQuery = existing.Encode()
 
 		return nil
 	}
@@ -149,6 +209,8 @@ func (c *Client[T]) RunFromCLI(args []string, headers []string, query string) (P
 		return c.runGetCommand(args[2:])
 	case "list":
 		return c.runListCommand(args[2:])
+	case "search":
+		return c.runSearchCommand(args[2:])
 	case "post":
 		return c.runPostCommand(args[2:])
 	case "put":
@@ -196,6 +258,19 @@ func (c *Client[T]) runListCommand(args []string) (PrintableResponse, error) {
 	return items, nil
 }
 
+func (c *Client[T]) runSearchCommand(args []string) (PrintableResponse, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("at least one argument required")
+	}
+
+	result, err := c.Search(context.Background(), args[0], args[1:]...)
+	if err != nil {
+		return nil, fmt.Errorf("error running Search: %w", err)
+	}
+
+	return result, nil
+}
+
 func (c *Client[T]) runPostCommand(args []string) (PrintableResponse, error) {
 	if len(args) < 1 {
 		return nil, fmt.Errorf("at least one argument required")
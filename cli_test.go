@@ -20,3 +20,16 @@ func TestStringSliceFlag(t *testing.T) {
 	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
 	flag.CommandLine.SetOutput(io.Discard)
 }
+
+func TestCredentialHeaders(t *testing.T) {
+	headers, err := credentialHeaders("key", "user:pass", "token")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"X-API-Key: key",
+		"Authorization: Basic dXNlcjpwYXNz",
+		"Authorization: Bearer token",
+	}, headers)
+
+	_, err = credentialHeaders("", "user", "")
+	require.EqualError(t, err, "invalid user: expected username:password")
+}
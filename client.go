@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"path"
 	"strings"
 )
@@ -55,6 +57,7 @@ type Client[T Resource] struct {
 	base                string
 	client              *http.Client
 	requestEditor       RequestEditor
+	credentials         RequestEditor
 	parentPaths         []string
 	customResponseCodes map[string]int
 }
@@ -66,6 +69,7 @@ func NewClient[T Resource](addr, base string) *Client[T] {
 		strings.TrimLeft(base, "/"),
 		http.DefaultClient,
 		DefaultRequestEditor,
+		nil,
 		[]string{},
 		defaultResponseCodes(),
 	}
@@ -74,6 +78,7 @@ func NewClient[T Resource](addr, base string) *Client[T] {
 // NewSubClient creates a Client as a child of an existing Client. This is useful for accessing nested API resources
 func NewSubClient[T, R Resource](parent *Client[T], path string) *Client[R] {
 	newClient := NewClient[R](parent.Address, path)
+	newClient.credentials = parent.credentials
 
 	newClient.parentPaths = make([]string, len(parent.parentPaths))
 	copy(newClient.parentPaths, parent.parentPaths)
@@ -109,6 +114,44 @@ func (c *Client[T]) SetRequestEditor(requestEditor RequestEditor) *Client[T] {
 	return c
 }
 
+// SetAPIKey sends the key in the X-API-Key header with all requests. It is used with an APIKeyAuthenticator
+func (c *Client[T]) SetAPIKey(key string) *Client[T] {
+	c.credentials = func(r *http.Request) error {
+		r.Header.Set(APIKeyHeader, key)
+		return nil
+	}
+	return c
+}
+
+// SetBasicAuth sends the username and password with all requests. It is used with a BasicAuthenticator
+func (c *Client[T]) SetBasicAuth(username, password string) *Client[T] {
+	c.credentials = func(r *http.Request) error {
+		r.SetBasicAuth(username, password)
+		return nil
+	}
+	return c
+}
+
+// SetBearerToken sends the token in the Authorization header with all requests. It is used with a JWTAuthenticator
+func (c *Client[T]) SetBearerToken(token string) *Client[T] {
+	c.credentials = func(r *http.Request) error {
+		r.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+	return c
+}
+
+// editRequest adds the credentials before calling the request editor so the editor can still override them
+func (c *Client[T]) editRequest(r *http.Request) error {
+	if c.credentials != nil {
+		err := c.credentials(r)
+		if err != nil {
+			return err
+		}
+	}
+	return c.requestEditor(r)
+}
+
 // Get will get a resource by ID
 func (c *Client[T]) Get(ctx context.Context, id string, parentIDs ...string) (*Response[T], error) {
 	req, err := c.NewRequestWithParentIDs(ctx, http.MethodGet, http.NoBody, id, parentIDs...)
@@ -133,7 +176,7 @@ func (c *Client[T]) GetAll(ctx context.Context, rawQuery string, parentIDs ...st
 
 	req.URL.RawQuery = rawQuery
 
-	result, err := MakeRequest[*ResourceList[T]](req, c.client, http.StatusOK, c.requestEditor)
+	result, err := MakeRequest[*ResourceList[T]](req, c.client, http.StatusOK, c.editRequest)
 	if err != nil {
 		return nil, fmt.Errorf("error getting all resources: %w", err)
 	}
@@ -141,6 +184,23 @@ func (c *Client[T]) GetAll(ctx context.Context, rawQuery string, parentIDs ...st
 	return result, nil
 }
 
+// Search gets resources matching the query from the API's search endpoint. The results are ordered by relevance
+func (c *Client[T]) Search(ctx context.Context, query string, parentIDs ...string) (*Response[*ResourceList[T]], error) {
+	req, err := c.NewRequestWithParentIDs(ctx, http.MethodGet, http.NoBody, "_search", parentIDs...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.URL.RawQuery = url.Values{searchQueryParam: []string{query}}.Encode()
+
+	result, err := MakeRequest[*ResourceList[T]](req, c.client, http.StatusOK, c.editRequest)
+	if err != nil {
+		return nil, fmt.Errorf("error searching resources: %w", err)
+	}
+
+	return result, nil
+}
+
 // Put makes a PUT request to create/modify a resource by ID
 func (c *Client[T]) Put(ctx context.Context, resource T, parentIDs ...string) (*Response[T], error) {
 	var body bytes.Buffer
@@ -213,21 +273,33 @@ func (c *Client[T]) Patch(ctx context.Context, id string, resource T, parentIDs
 		return nil, fmt.Errorf("error encoding request body: %w", err)
 	}
 
-	return c.patch(ctx, id, &body, parentIDs...)
+	return c.patch(ctx, id, &body, "application/json", parentIDs...)
 }
 
 // PatchRaw makes a PATCH request to modify a resource by ID. It uses the provided string as the request body
 func (c *Client[T]) PatchRaw(ctx context.Context, id, body string, parentIDs ...string) (*Response[T], error) {
-	return c.patch(ctx, id, bytes.NewBufferString(body), parentIDs...)
+	return c.patch(ctx, id, bytes.NewBufferString(body), "application/json", parentIDs...)
 }
 
-func (c *Client[T]) patch(ctx context.Context, id string, body io.Reader, parentIDs ...string) (*Response[T], error) {
+// JSONPatch makes a PATCH request to modify a resource by ID using JSON Patch operations. The API has to use
+// EnableJSONPatch. If a test operation fails, none of the operations are applied and an error is returned
+func (c *Client[T]) JSONPatch(ctx context.Context, id string, operations []JSONPatchOperation, parentIDs ...string) (*Response[T], error) {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(operations)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request body: %w", err)
+	}
+
+	return c.patch(ctx, id, &body, jsonPatchContentType, parentIDs...)
+}
+
+func (c *Client[T]) patch(ctx context.Context, id string, body io.Reader, contentType string, parentIDs ...string) (*Response[T], error) {
 	req, err := c.NewRequestWithParentIDs(ctx, http.MethodPatch, body, id, parentIDs...)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
-	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Content-Type", contentType)
 
 	resp, err := c.MakeRequest(req, c.customResponseCodes[http.MethodPatch])
 	if err != nil {
@@ -289,7 +361,7 @@ func (c *Client[T]) MakeRequest(req *http.Request, expectedStatusCode int) (*Res
 	if expectedStatusCode == 0 {
 		expectedStatusCode = c.customResponseCodes[req.Method]
 	}
-	return MakeRequest[T](req, c.client, expectedStatusCode, c.requestEditor)
+	return MakeRequest[T](req, c.client, expectedStatusCode, c.editRequest)
 }
 
 // MakeRequest generically sends an HTTP request after calling the request editor and checks the response code
@@ -324,6 +396,18 @@ func MakeRequest[T any](req *http.Request, client *http.Client, expectedStatusCo
 			return nil, fmt.Errorf("unexpected status and no body: %d", resp.StatusCode)
 		}
 
+		mediaType, _, _ := mime.ParseMediaType(result.ContentType)
+		if mediaType == problemJSONContentType {
+			var problem ProblemDetails
+			err = json.Unmarshal([]byte(result.Body), &problem)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding error response %q: %w", result.Body, err)
+			}
+			httpErr := problem.ErrResponse()
+			httpErr.HTTPStatusCode = resp.StatusCode
+			return nil, httpErr
+		}
+
 		var httpErr *ErrResponse
 		err = json.Unmarshal([]byte(result.Body), &httpErr)
 		if err != nil {
@@ -0,0 +1,230 @@
+package babyapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultListenRetry = 3 * time.Second
+	// maxListenLineSize is the longest line that can be read from an event stream
+	maxListenLineSize = 10 << 20
+)
+
+// ListenEvent is a server-sent event received by Client.Listen. Data is decoded from the JSON event data and Err is
+// set if the data could not be decoded. RawData always has the original data. Err is also used for errors reading the
+// stream, which don't have any other fields
+type ListenEvent[T any] struct {
+	ID      string
+	Event   string
+	Data    T
+	RawData string
+	Err     error
+}
+
+// Listen connects to the server-sent event stream at the path, relative to the client's base URL, and returns a
+// channel of events. When the stream ends, the client reconnects using the Last-Event-ID header so missed events are
+// received. It stops reconnecting and closes the channel when the context is done, the server responds with
+// something other than 200 OK, or a line is longer than 10MB. An error is returned if the first connection fails and
+// a ListenEvent with a ListenStatusError is sent if reconnecting is rejected by the server
+func (c *Client[T]) Listen(ctx context.Context, path string, parentIDs ...string) (<-chan *ListenEvent[T], error) {
+	address, err := c.URL("", parentIDs...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating target URL: %w", err)
+	}
+	address += path
+
+	l := &eventListener[T]{client: c, address: address, retry: defaultListenRetry}
+
+	resp, err := l.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *ListenEvent[T])
+	go l.run(ctx, resp, events)
+
+	return events, nil
+}
+
+// eventListener keeps track of the last event ID and retry delay between connections
+type eventListener[T Resource] struct {
+	client      *Client[T]
+	address     string
+	lastEventID string
+	retry       time.Duration
+}
+
+func (l *eventListener[T]) connect(ctx context.Context) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.address, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+	if l.lastEventID != "" {
+		req.Header.Set(lastEventIDHeader, l.lastEventID)
+	}
+
+	err = l.client.editRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("error returned from request editor: %w", err)
+	}
+
+	resp, err := l.client.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error doing request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &ListenStatusError{resp.StatusCode, string(body)}
+	}
+
+	return resp, nil
+}
+
+// ListenStatusError is returned by Client.Listen when the server doesn't respond with 200 OK
+type ListenStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *ListenStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d for event stream: %s", e.StatusCode, e.Body)
+}
+
+// run reads events from the response and reconnects until the context is done or reconnecting fails with a status error
+func (l *eventListener[T]) run(ctx context.Context, resp *http.Response, events chan<- *ListenEvent[T]) {
+	defer close(events)
+
+	for {
+		ok, err := l.read(ctx, resp, events)
+		if err != nil {
+			if !l.send(ctx, events, &ListenEvent[T]{Err: err}) {
+				return
+			}
+		}
+		// Reading the same event again would fail, so the listener stops
+		if !ok || errors.Is(err, bufio.ErrTooLong) {
+			return
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(l.retry):
+			}
+
+			var err error
+			resp, err = l.connect(ctx)
+			if err == nil {
+				break
+			}
+
+			// The server rejected the connection, so the error is sent before closing the channel
+			var statusErr *ListenStatusError
+			if errors.As(err, &statusErr) {
+				l.send(ctx, events, &ListenEvent[T]{Err: err})
+				return
+			}
+		}
+	}
+}
+
+// read sends events from the response until it ends. It returns false if the context is done and an error if the
+// stream couldn't be read
+func (l *eventListener[T]) read(ctx context.Context, resp *http.Response, events chan<- *ListenEvent[T]) (bool, error) {
+	defer resp.Body.Close()
+
+	id := l.lastEventID
+	var event string
+	var data []string
+	hasData := false
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, maxListenLineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			l.lastEventID = id
+
+			if hasData {
+				if !l.send(ctx, events, l.newEvent(event, strings.Join(data, "\n"))) {
+					return false, nil
+				}
+			}
+
+			event, data, hasData = "", nil, false
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "":
+			// Lines starting with a colon are comments
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+			hasData = true
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				id = value
+			}
+		case "retry":
+			retry, err := strconv.Atoi(value)
+			if err == nil {
+				l.retry = time.Duration(retry) * time.Millisecond
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		return false, nil
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return true, fmt.Errorf("error reading event stream: %w", err)
+	}
+	return true, nil
+}
+
+// newEvent creates a ListenEvent and decodes its data
+func (l *eventListener[T]) newEvent(event, data string) *ListenEvent[T] {
+	if event == "" {
+		event = "message"
+	}
+
+	result := &ListenEvent[T]{ID: l.lastEventID, Event: event, RawData: data}
+
+	err := json.Unmarshal([]byte(data), &result.Data)
+	if err != nil {
+		result.Err = fmt.Errorf("error decoding event data %q: %w", data, err)
+	}
+
+	return result
+}
+
+func (l *eventListener[T]) send(ctx context.Context, events chan<- *ListenEvent[T], event *ListenEvent[T]) bool {
+	select {
+	case events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,156 @@
+package babyapi_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientListen(t *testing.T) {
+	api := babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} }).EnableChangeStream()
+
+	client, stop := babytest.NewTestClient[*Memo](t, api)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := client.Listen(ctx, "/events")
+	require.NoError(t, err)
+
+	created, err := client.Post(ctx, &Memo{Text: "hello"})
+	require.NoError(t, err)
+
+	event := babytest.RequireEvent(t, events, time.Second)
+	require.NoError(t, event.Err)
+	require.Equal(t, babyapi.ChangeEventCreated, event.Event)
+	require.Equal(t, "1", event.ID)
+	require.Equal(t, created.Data.GetID(), event.Data.GetID())
+	require.Equal(t, "hello", event.Data.Text)
+
+	_, err = client.Post(ctx, &Memo{Text: "second"})
+	require.NoError(t, err)
+	_, err = client.Delete(ctx, created.Data.GetID())
+	require.NoError(t, err)
+
+	event = babytest.RequireEventMatching(t, events, time.Second, func(e *babyapi.ListenEvent[*Memo]) bool {
+		return e.Event == babyapi.ChangeEventDeleted
+	})
+	require.Equal(t, created.Data.GetID(), event.Data.GetID())
+
+	babytest.RequireNoEvent(t, events, 10*time.Millisecond)
+
+	cancel()
+	require.Eventually(t, func() bool {
+		_, ok := <-events
+		return !ok
+	}, time.Second, time.Millisecond)
+}
+
+func TestClientListenReconnect(t *testing.T) {
+	var lock sync.Mutex
+	var lastEventIDs []string
+
+	memoIDs := []string{xid.New().String(), xid.New().String()}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		connection := len(lastEventIDs)
+		lock.Unlock()
+
+		if connection > 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		// The stream ends after each event so the client has to reconnect
+		fmt.Fprintf(w, ": connected\nretry: 1\nevent: memo\ndata: {\"id\": \"%s\",\ndata:  \"text\": \"multi-line\"}\nid: %d\n\n", memoIDs[connection-1], connection)
+	}))
+	defer server.Close()
+
+	client := babyapi.NewClient[*Memo](server.URL, "/memos")
+
+	events, err := client.Listen(context.Background(), "/events")
+	require.NoError(t, err)
+
+	for i, memoID := range memoIDs {
+		event := babytest.RequireEvent(t, events, time.Second)
+		require.NoError(t, event.Err)
+		require.Equal(t, "memo", event.Event)
+		require.Equal(t, fmt.Sprint(i+1), event.ID)
+		require.Equal(t, memoID, event.Data.GetID())
+		require.Equal(t, "multi-line", event.Data.Text)
+		require.Equal(t, "{\"id\": \""+memoID+"\",\n \"text\": \"multi-line\"}", event.RawData)
+	}
+
+	// The status error is sent and then the channel is closed when reconnecting doesn't get 200 OK
+	event := babytest.RequireEvent(t, events, time.Second)
+	var statusErr *babyapi.ListenStatusError
+	require.True(t, errors.As(event.Err, &statusErr))
+	require.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+	require.Equal(t, "unavailable\n", statusErr.Body)
+
+	require.Eventually(t, func() bool {
+		_, ok := <-events
+		return !ok
+	}, time.Second, time.Millisecond)
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.Equal(t, []string{"", "1", "2"}, lastEventIDs)
+}
+
+func TestClientListenError(t *testing.T) {
+	api := babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} })
+
+	client, stop := babytest.NewTestClient[*Memo](t, api)
+	defer stop()
+
+	_, err := client.Listen(context.Background(), "/events")
+
+	var statusErr *babyapi.ListenStatusError
+	require.True(t, errors.As(err, &statusErr))
+	require.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
+
+func TestClientListenLongLines(t *testing.T) {
+	memoID := xid.New().String()
+	longText := strings.Repeat("a", 100*1024)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: memo\ndata: {\"id\": \"%s\", \"text\": \"%s\"}\n\n", memoID, longText)
+		fmt.Fprintf(w, "event: memo\ndata: %s\n\n", strings.Repeat("a", 11<<20))
+	}))
+	defer server.Close()
+
+	client := babyapi.NewClient[*Memo](server.URL, "/memos")
+
+	events, err := client.Listen(context.Background(), "/events")
+	require.NoError(t, err)
+
+	event := babytest.RequireEvent(t, events, time.Second)
+	require.NoError(t, event.Err)
+	require.Equal(t, longText, event.Data.Text)
+
+	// Lines that are too long end the stream instead of reconnecting
+	event = babytest.RequireEvent(t, events, time.Second)
+	require.ErrorIs(t, event.Err, bufio.ErrTooLong)
+
+	_, ok := <-events
+	require.False(t, ok)
+}
@@ -14,6 +14,10 @@ type ctxKey int
 const (
 	loggerCtxKey ctxKey = iota
 	requestBodyCtxKey
+	patchedIDCtxKey
+	responderCtxKey
+	principalCtxKey
+	sessionCtxKey
 )
 
 // GetLoggerFromContext returns the structured logger from the context. It expects to use an HTTP
@@ -79,3 +83,14 @@ func (a *API[T]) newContextWithResource(ctx context.Context, value T) context.Co
 func (a *API[T]) contextKey() ContextKey {
 	return ContextKey(a.name)
 }
+
+// newContextWithPatchedID stores the ID of a resource that is being modified by a generic PATCH. This allows the
+// patched resource to include its existing ID when it is bound
+func newContextWithPatchedID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, patchedIDCtxKey, id)
+}
+
+func getPatchedIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(patchedIDCtxKey).(string)
+	return id
+}
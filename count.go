@@ -0,0 +1,181 @@
+package babyapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+const (
+	// TotalCountHeader is the response header set by GetAll with the number of resources. Use a HEAD request to /base
+	// to get the count without a response body
+	TotalCountHeader = "X-Total-Count"
+
+	groupByQueryParam = "groupBy"
+)
+
+// CountResponse is the response from the /base/_count endpoint
+type CountResponse struct {
+	*DefaultRenderer
+
+	Count int `json:"count"`
+}
+
+// AggregateResponse is the response from the /base/_aggregate endpoint. Counts maps each distinct value of the
+// GroupBy field to the number of resources with that value
+type AggregateResponse struct {
+	*DefaultRenderer
+
+	GroupBy string         `json:"groupBy"`
+	Counts  map[string]int `json:"counts"`
+}
+
+func (a *API[T]) getAllFiltered(r *http.Request) ([]T, *ErrResponse) {
+	resources, err := a.Storage.GetAll(a.requestFilter(r))
+	if err != nil {
+		GetLoggerFromContext(r.Context()).Error("error getting resources", "error", err)
+		return nil, InternalServerError(err)
+	}
+
+	return resources, nil
+}
+
+// EnableCount adds the /base/_count endpoint, which returns the number of resources matching the GetAll filter.
+// It is only routed when the API has a GetAll handler
+func (a *API[T]) EnableCount() *API[T] {
+	if a.rootAPI {
+		panic("count cannot be used with a root API")
+	}
+
+	a.Count = a.defaultCount()
+	return a
+}
+
+// EnableAggregate adds the /base/_aggregate endpoint, which counts resources matching the GetAll filter for each
+// distinct value of a field. Only the provided fields can be used in the groupBy query parameter, so fields that are
+// hidden from responses are not exposed. Fields can be referenced by their Go name or JSON name. It is only routed
+// when the API has a GetAll handler. Panics if any of the fields doesn't exist on the resource
+func (a *API[T]) EnableAggregate(fields ...string) *API[T] {
+	if a.rootAPI {
+		panic("aggregate cannot be used with a root API")
+	}
+
+	resourceType := reflect.TypeOf(new(T)).Elem()
+	for resourceType.Kind() == reflect.Ptr {
+		resourceType = resourceType.Elem()
+	}
+
+	allowed := map[string][]int{}
+	for _, field := range fields {
+		f, ok := groupByField(resourceType, field)
+		if !ok {
+			panic(fmt.Sprintf("invalid aggregate field %q: must be an exported field of %s", field, resourceType.Name()))
+		}
+
+		allowed[f.Name] = f.Index
+		if jsonName := jsonFieldName(f); jsonName != "" {
+			allowed[jsonName] = f.Index
+		}
+	}
+
+	a.Aggregate = a.defaultAggregate(allowed)
+	return a
+}
+
+func (a *API[T]) defaultCount() http.HandlerFunc {
+	return Handler(func(w http.ResponseWriter, r *http.Request) render.Renderer {
+		resources, httpErr := a.getAllFiltered(r)
+		if httpErr != nil {
+			return httpErr
+		}
+
+		render.Status(r, a.responseCodes[http.MethodGet])
+
+		return &CountResponse{Count: len(resources)}
+	})
+}
+
+// defaultAggregate groups by the fields in allowed, which maps Go and JSON field names to the field index
+func (a *API[T]) defaultAggregate(allowed map[string][]int) http.HandlerFunc {
+	return Handler(func(w http.ResponseWriter, r *http.Request) render.Renderer {
+		groupBy := r.URL.Query().Get(groupByQueryParam)
+		if groupBy == "" {
+			return ErrInvalidRequest(fmt.Errorf("missing required %q query parameter", groupByQueryParam))
+		}
+
+		index, ok := allowed[groupBy]
+		if !ok {
+			return ErrInvalidRequest(fmt.Errorf("invalid %s field: %q", groupByQueryParam, groupBy))
+		}
+
+		resources, httpErr := a.getAllFiltered(r)
+		if httpErr != nil {
+			return httpErr
+		}
+
+		counts := map[string]int{}
+		for _, resource := range resources {
+			counts[groupByValue(resource, index)]++
+		}
+
+		render.Status(r, a.responseCodes[http.MethodGet])
+
+		return &AggregateResponse{GroupBy: groupBy, Counts: counts}
+	})
+}
+
+// groupByField finds an exported field by its Go name or JSON name, including fields from embedded structs
+func groupByField(t reflect.Type, name string) (reflect.StructField, bool) {
+	if t.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		if f.Name == name || jsonFieldName(f) == name {
+			return f, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// jsonFieldName returns the name from the field's json tag or an empty string if it doesn't have one
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// groupByValue gets the string representation of a field's value. Nil pointers are represented as "null"
+func groupByValue(resource any, index []int) string {
+	v := reflect.ValueOf(resource)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "null"
+		}
+		v = v.Elem()
+	}
+
+	f, err := v.FieldByIndexErr(index)
+	if err != nil {
+		return "null"
+	}
+
+	for f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface {
+		if f.IsNil() {
+			return "null"
+		}
+		f = f.Elem()
+	}
+
+	return fmt.Sprint(f.Interface())
+}
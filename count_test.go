@@ -0,0 +1,110 @@
+package babyapi_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/stretchr/testify/require"
+)
+
+type Task struct {
+	babyapi.DefaultResource
+	Title     string `json:"title"`
+	Completed *bool  `json:"completed"`
+	Priority  int
+}
+
+func TestCountAndAggregate(t *testing.T) {
+	api := babyapi.NewAPI[*Task]("Tasks", "/tasks", func() *Task { return &Task{} }).
+		EnableCount().
+		EnableAggregate("Completed", "Priority")
+	api.SetGetAllFilter(func(r *http.Request) babyapi.FilterFunc[*Task] {
+		title := r.URL.Query().Get("title")
+		return func(task *Task) bool {
+			return title == "" || task.Title == title
+		}
+	})
+
+	completed, notCompleted := true, false
+	tasks := []*Task{
+		{DefaultResource: babyapi.NewDefaultResource(), Title: "A", Completed: &completed, Priority: 1},
+		{DefaultResource: babyapi.NewDefaultResource(), Title: "A", Completed: &notCompleted, Priority: 1},
+		{DefaultResource: babyapi.NewDefaultResource(), Title: "B", Completed: &completed, Priority: 2},
+		{DefaultResource: babyapi.NewDefaultResource(), Title: "C", Priority: 1},
+	}
+	for _, task := range tasks {
+		require.NoError(t, api.Storage.Set(task))
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+		expectedCount  string
+	}{
+		{"Head", http.MethodHead, "/tasks", http.StatusOK, "", "4"},
+		{"HeadWithFilter", http.MethodHead, "/tasks?title=A", http.StatusOK, "", "2"},
+		{"Count", http.MethodGet, "/tasks/_count", http.StatusOK, `{"count":4}`, ""},
+		{"CountWithFilter", http.MethodGet, "/tasks/_count?title=B", http.StatusOK, `{"count":1}`, ""},
+		{"AggregateCompleted", http.MethodGet, "/tasks/_aggregate?groupBy=Completed", http.StatusOK, `{"groupBy":"Completed","counts":{"false":1,"null":1,"true":2}}`, ""},
+		{"AggregateByJSONName", http.MethodGet, "/tasks/_aggregate?groupBy=completed&title=A", http.StatusOK, `{"groupBy":"completed","counts":{"false":1,"true":1}}`, ""},
+		{"AggregatePriority", http.MethodGet, "/tasks/_aggregate?groupBy=Priority", http.StatusOK, `{"groupBy":"Priority","counts":{"1":3,"2":1}}`, ""},
+		{"AggregateMissingGroupBy", http.MethodGet, "/tasks/_aggregate", http.StatusBadRequest, `{"status":"Invalid request.","error":"missing required \"groupBy\" query parameter"}`, ""},
+		{"AggregateInvalidGroupBy", http.MethodGet, "/tasks/_aggregate?groupBy=Bad", http.StatusBadRequest, `{"status":"Invalid request.","error":"invalid groupBy field: \"Bad\""}`, ""},
+		{"AggregateFieldNotAllowed", http.MethodGet, "/tasks/_aggregate?groupBy=Title", http.StatusBadRequest, `{"status":"Invalid request.","error":"invalid groupBy field: \"Title\""}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, http.NoBody)
+			w := babytest.TestRequest[*Task](t, api, r)
+
+			require.Equal(t, tt.expectedStatus, w.Result().StatusCode)
+			require.Equal(t, tt.expectedBody, strings.TrimSpace(w.Body.String()))
+			if tt.expectedCount != "" {
+				require.Equal(t, tt.expectedCount, w.Result().Header.Get(babyapi.TotalCountHeader))
+			}
+		})
+	}
+
+	t.Run("DisabledWhenNil", func(t *testing.T) {
+		api.Count = nil
+
+		r := httptest.NewRequest(http.MethodGet, "/tasks/_count", http.NoBody)
+		w := babytest.TestRequest[*Task](t, api, r)
+
+		// The path is handled as a resource ID instead
+		require.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+}
+
+func TestCountAndAggregateAreOptIn(t *testing.T) {
+	api := babyapi.NewAPI[*Task]("Tasks", "/tasks", func() *Task { return &Task{} })
+
+	for _, path := range []string{"/tasks/_count", "/tasks/_aggregate?groupBy=Title"} {
+		r := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		w := babytest.TestRequest[*Task](t, api, r)
+		require.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	}
+
+	t.Run("NotRoutedWithoutGetAll", func(t *testing.T) {
+		api := babyapi.NewAPI[*Task]("Tasks", "/tasks", func() *Task { return &Task{} }).EnableCount()
+		api.GetAll = nil
+
+		r := httptest.NewRequest(http.MethodGet, "/tasks/_count", http.NoBody)
+		w := babytest.TestRequest[*Task](t, api, r)
+		require.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	t.Run("InvalidFieldPanics", func(t *testing.T) {
+		require.Panics(t, func() {
+			babyapi.NewAPI[*Task]("Tasks", "/tasks", func() *Task { return &Task{} }).EnableAggregate("Bad")
+		})
+	})
+}
@@ -0,0 +1,602 @@
+package babyapi
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+	"gopkg.in/yaml.v3"
+)
+
+// Encoder writes a response body in a specific format
+type Encoder func(w io.Writer, v any) error
+
+// Decoder reads a request body in a specific format into v
+type Decoder func(r io.Reader, v any) error
+
+// defaultEncoders are the built-in response formats. JSON is not included because it uses go-chi/render's default
+// responder, which is used when the Accept header doesn't match any other format
+func defaultEncoders() map[string]Encoder {
+	return map[string]Encoder{
+		"application/yaml":   encodeYAML,
+		"application/x-yaml": encodeYAML,
+		"text/yaml":          encodeYAML,
+		"application/xml":    encodeXML,
+		"text/xml":           encodeXML,
+		"text/csv":           encodeCSV,
+	}
+}
+
+// defaultDecoders are the built-in request body formats. Requests with other content types use go-chi/render
+func defaultDecoders() map[string]Decoder {
+	return map[string]Decoder{
+		"application/yaml":   decodeYAML,
+		"application/x-yaml": decodeYAML,
+		"text/yaml":          decodeYAML,
+		"application/xml":    decodeXML,
+		"text/xml":           decodeXML,
+		"text/csv":           decodeCSV,
+	}
+}
+
+// messagePackMediaTypes are registered by EnableMessagePack
+var messagePackMediaTypes = []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+
+// maxDecodedBodySize limits request bodies read by a registered Decoder
+const maxDecodedBodySize = 10 << 20
+
+// EnableMessagePack adds MessagePack request and response bodies. It is not enabled by default
+func (a *API[T]) EnableMessagePack() *API[T] {
+	for _, mediaType := range messagePackMediaTypes {
+		a.SetEncoder(mediaType, encodeMessagePack)
+		a.SetDecoder(mediaType, decodeMessagePack)
+	}
+	return a
+}
+
+// SetEncoder adds or replaces the Encoder used for responses when the request accepts the media type. Use a nil
+// Encoder to remove a format
+func (a *API[T]) SetEncoder(mediaType string, encoder Encoder) *API[T] {
+	if encoder == nil {
+		delete(a.encoders, mediaType)
+		return a
+	}
+	a.encoders[mediaType] = encoder
+	return a
+}
+
+// SetDecoder adds or replaces the Decoder used for request bodies with the Content-Type. Use a nil Decoder to remove
+// a format
+func (a *API[T]) SetDecoder(mediaType string, decoder Decoder) *API[T] {
+	if decoder == nil {
+		delete(a.decoders, mediaType)
+		return a
+	}
+	a.decoders[mediaType] = decoder
+	return a
+}
+
+// negotiateEncoder finds the Encoder for the most preferred media type in the Accept header. It returns false if
+// the default JSON response should be used
+func (a *API[T]) negotiateEncoder(r *http.Request) (string, Encoder, bool) {
+	for _, mediaType := range acceptedMediaTypes(r) {
+		if mediaType == "application/json" || mediaType == "*/*" {
+			return "", nil, false
+		}
+		if encoder, ok := a.encoders[mediaType]; ok {
+			return mediaType, encoder, true
+		}
+	}
+	return "", nil, false
+}
+
+// acceptedMediaTypes parses the Accept header and sorts the media types by quality
+func acceptedMediaTypes(r *http.Request) []string {
+	type accepted struct {
+		mediaType string
+		quality   float64
+	}
+
+	var types []accepted
+	for _, field := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(field))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		types = append(types, accepted{mediaType, quality})
+	}
+
+	sort.SliceStable(types, func(i, j int) bool {
+		return types[i].quality > types[j].quality
+	})
+
+	result := make([]string, 0, len(types))
+	for _, t := range types {
+		result = append(result, t.mediaType)
+	}
+	return result
+}
+
+// respondWithEncoder encodes the response using the negotiated format. The response is encoded before writing
+// so errors can still be returned as a JSON ErrResponse
+func respondWithEncoder(w http.ResponseWriter, r *http.Request, mediaType string, encoder Encoder, v any) {
+	var buf bytes.Buffer
+	err := encoder(&buf, v)
+	if err != nil {
+		GetLoggerFromContext(r.Context()).Error("error encoding response", "error", err, "content_type", mediaType)
+		errResp := ErrRender(err)
+		render.Status(r, errResp.HTTPStatusCode)
+		render.DefaultResponder(w, r, errResp)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+		w.WriteHeader(status)
+	}
+	_, _ = w.Write(buf.Bytes())
+}
+
+// bind decodes the request body using a registered Decoder for the Content-Type and calls Bind. Other content types
+// use go-chi/render
+func (a *API[T]) bind(r *http.Request, resource T) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	decoder, ok := a.decoders[mediaType]
+	if !ok {
+		return render.Bind(r, resource)
+	}
+
+	err := decoder(http.MaxBytesReader(nil, r.Body, maxDecodedBodySize), resource)
+	if err != nil {
+		return fmt.Errorf("error decoding %s request body: %w", mediaType, err)
+	}
+
+	return callBinders(r, resource)
+}
+
+var binderType = reflect.TypeOf((*render.Binder)(nil)).Elem()
+
+// callBinders calls Bind the same way as render.Bind after the body is decoded: first on struct fields that implement
+// render.Binder and then on the value itself
+func callBinders(r *http.Request, v render.Binder) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() == reflect.Struct {
+		for i := 0; i < rv.NumField(); i++ {
+			f := rv.Field(i)
+			if !f.Type().Implements(binderType) || isNilValue(f) {
+				continue
+			}
+
+			err := callBinders(r, f.Interface().(render.Binder))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return v.Bind(r)
+}
+
+// genericDocument converts a value to maps, slices, and primitives using its JSON representation so other formats
+// use the same field names and structure as JSON responses
+func genericDocument(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding JSON: %w", err)
+	}
+
+	doc, err := decodeJSONValue(data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding JSON: %w", err)
+	}
+
+	return normalizeNumbers(doc), nil
+}
+
+// normalizeNumbers replaces json.Number with int64 or float64
+func normalizeNumbers(v any) any {
+	switch value := v.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	case map[string]any:
+		for key, item := range value {
+			value[key] = normalizeNumbers(item)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = normalizeNumbers(item)
+		}
+	}
+	return v
+}
+
+// decodeGeneric sets v using a generic document from another format by converting it to JSON
+func decodeGeneric(doc, v any) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("error encoding JSON: %w", err)
+	}
+	return json.Unmarshal(data, v)
+}
+
+func encodeYAML(w io.Writer, v any) error {
+	doc, err := genericDocument(v)
+	if err != nil {
+		return err
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	err = encoder.Encode(doc)
+	if err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func decodeYAML(r io.Reader, v any) error {
+	var doc any
+	err := yaml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return err
+	}
+	return decodeGeneric(doc, v)
+}
+
+const (
+	xmlRootElement = "response"
+	xmlItemElement = "item"
+)
+
+// encodeXML writes the JSON structure as XML elements. Objects become child elements, arrays use repeated item
+// elements, and null values are empty elements
+func encodeXML(w io.Writer, v any) error {
+	doc, err := genericDocument(v)
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	err = encodeXMLElement(encoder, xmlRootElement, doc)
+	if err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func encodeXMLElement(encoder *xml.Encoder, name string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	err := encoder.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case nil:
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			err = encodeXMLElement(encoder, key, value[key])
+			if err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range value {
+			err = encodeXMLElement(encoder, xmlItemElement, item)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		err = encoder.EncodeToken(xml.CharData(fmt.Sprint(value)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return encoder.EncodeToken(start.End())
+}
+
+// decodeXML reads the format written by encodeXML. Since XML has no types, values are converted using the JSON
+// field types of v
+func decodeXML(r io.Reader, v any) error {
+	decoder := xml.NewDecoder(r)
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			doc, err := decodeXMLElement(decoder, start)
+			if err != nil {
+				return err
+			}
+			return decodeGeneric(coerceToType(doc, reflect.TypeOf(v)), v)
+		}
+	}
+}
+
+// decodeXMLElement returns a string for elements with only text and a map for elements with children. Repeated
+// child elements are combined into a slice
+func decodeXMLElement(decoder *xml.Decoder, start xml.StartElement) (any, error) {
+	var text strings.Builder
+	var children map[string]any
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+
+			if children == nil {
+				children = map[string]any{}
+			}
+
+			name := t.Name.Local
+			switch existing := children[name].(type) {
+			case nil:
+				children[name] = child
+			case []any:
+				children[name] = append(existing, child)
+			default:
+				children[name] = []any{existing, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if children != nil {
+				return children, nil
+			}
+			return text.String(), nil
+		}
+	}
+}
+
+// encodeCSV writes a header row with the JSON field names and one row for each resource. ResourceLists and arrays
+// have a row for each item. Nested objects and arrays are written as JSON in a single cell
+func encodeCSV(w io.Writer, v any) error {
+	doc, err := genericDocument(v)
+	if err != nil {
+		return err
+	}
+
+	rows := []any{doc}
+	switch value := doc.(type) {
+	case []any:
+		rows = value
+	case map[string]any:
+		if items, ok := value["items"].([]any); ok && len(value) == 1 {
+			rows = items
+		}
+	}
+
+	columnSet := map[string]bool{}
+	for _, row := range rows {
+		obj, ok := row.(map[string]any)
+		if !ok {
+			return errors.New("CSV rows must be objects")
+		}
+		for key := range obj {
+			columnSet[key] = true
+		}
+	}
+
+	columns := make([]string, 0, len(columnSet))
+	for column := range columnSet {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	csvWriter := csv.NewWriter(w)
+	err = csvWriter.Write(columns)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		obj := row.(map[string]any)
+
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i], err = csvCell(obj[column])
+			if err != nil {
+				return err
+			}
+		}
+
+		err = csvWriter.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func csvCell(v any) (string, error) {
+	switch value := v.(type) {
+	case nil:
+		return "", nil
+	case map[string]any, []any:
+		data, err := json.Marshal(value)
+		return string(data), err
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+// decodeCSV reads a header row and a single data row into v. Empty cells are treated as missing values
+func decodeCSV(r io.Reader, v any) error {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) != 2 {
+		return fmt.Errorf("expected a header and one row but got %d rows", len(records))
+	}
+
+	doc := map[string]any{}
+	for i, column := range records[0] {
+		if i < len(records[1]) && records[1][i] != "" {
+			doc[column] = records[1][i]
+		}
+	}
+
+	return decodeGeneric(coerceToType(doc, reflect.TypeOf(v)), v)
+}
+
+// coerceToType converts strings from untyped formats like XML and CSV to the JSON type expected by the Go type.
+// Values that can't be converted are left as-is so decoding returns a useful error
+func coerceToType(v any, t reflect.Type) any {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType || t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return v
+	}
+
+	s, isString := v.(string)
+
+	// Empty values for other types are treated as null
+	if isString && s == "" && t.Kind() != reflect.String {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(s); isString && err == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if isString {
+			return json.Number(strings.TrimSpace(s))
+		}
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		// CSV cells and XML text contain nested values as JSON
+		if isString {
+			if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+				return v
+			}
+
+			var nested any
+			if json.Unmarshal([]byte(s), &nested) != nil {
+				return v
+			}
+			v = nested
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			return coerceStruct(v, t)
+		case reflect.Map:
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return v
+			}
+			for key, value := range obj {
+				obj[key] = coerceToType(value, t.Elem())
+			}
+			return obj
+		default:
+			return coerceSlice(v, t)
+		}
+	}
+
+	return v
+}
+
+func coerceStruct(v any, t reflect.Type) any {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		if value, ok := obj[name]; ok {
+			obj[name] = coerceToType(value, f.Type)
+		}
+	}
+
+	return obj
+}
+
+// coerceSlice handles XML arrays, which are decoded as an object with one or more item elements
+func coerceSlice(v any, t reflect.Type) any {
+	if obj, ok := v.(map[string]any); ok && len(obj) == 1 {
+		if items, ok := obj[xmlItemElement]; ok {
+			v = items
+		}
+	}
+
+	items, ok := v.([]any)
+	if !ok {
+		items = []any{v}
+	}
+
+	for i, item := range items {
+		items[i] = coerceToType(item, t.Elem())
+	}
+	return items
+}
@@ -0,0 +1,212 @@
+package babyapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/stretchr/testify/require"
+)
+
+type Plant struct {
+	babyapi.DefaultResource
+	Name    string   `json:"name"`
+	Height  float64  `json:"height"`
+	Count   int      `json:"count"`
+	Indoor  bool     `json:"indoor"`
+	Tags    []string `json:"tags"`
+	Watered *bool    `json:"watered"`
+}
+
+func TestEncoders(t *testing.T) {
+	api := babyapi.NewAPI[*Plant]("Plants", "/plants", func() *Plant { return &Plant{} }).EnableMessagePack()
+
+	plant := &Plant{DefaultResource: babyapi.NewDefaultResource(), Name: "Fern", Height: 1.5, Count: 3, Indoor: true, Tags: []string{"green", "shade"}}
+	require.NoError(t, api.Storage.Set(plant))
+
+	get := func(t *testing.T, path, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		r.Header.Set("Accept", accept)
+		return babytest.TestRequest[*Plant](t, api, r)
+	}
+
+	t.Run("YAML", func(t *testing.T) {
+		w := get(t, "/plants/"+plant.GetID(), "application/yaml")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/yaml; charset=utf-8", w.Header().Get("Content-Type"))
+		require.Equal(t, fmt.Sprintf(`count: 3
+height: 1.5
+id: %s
+indoor: true
+name: Fern
+tags:
+  - green
+  - shade
+watered: null
+`, plant.GetID()), w.Body.String())
+	})
+
+	t.Run("XML", func(t *testing.T) {
+		w := get(t, "/plants/"+plant.GetID(), "application/xml")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, fmt.Sprintf(
+			`<response><count>3</count><height>1.5</height><id>%s</id><indoor>true</indoor><name>Fern</name><tags><item>green</item><item>shade</item></tags><watered></watered></response>`,
+			plant.GetID(),
+		), w.Body.String())
+	})
+
+	t.Run("CSVList", func(t *testing.T) {
+		w := get(t, "/plants", "text/csv")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, fmt.Sprintf("count,height,id,indoor,name,tags,watered\n3,1.5,%s,true,Fern,\"[\"\"green\"\",\"\"shade\"\"]\",\n", plant.GetID()), w.Body.String())
+	})
+
+	t.Run("ErrorsUseNegotiatedFormat", func(t *testing.T) {
+		w := get(t, "/plants/DoesNotExist", "application/yaml")
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Equal(t, "status: Resource not found.\n", w.Body.String())
+	})
+
+	t.Run("QualityValues", func(t *testing.T) {
+		w := get(t, "/plants/"+plant.GetID(), "text/csv;q=0.5, application/yaml")
+		require.Equal(t, "application/yaml; charset=utf-8", w.Header().Get("Content-Type"))
+
+		w = get(t, "/plants/"+plant.GetID(), "application/json, application/yaml")
+		require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		w = get(t, "/plants/"+plant.GetID(), "application/unknown")
+		require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	})
+
+	t.Run("CustomEncoder", func(t *testing.T) {
+		api.SetEncoder("text/plain", func(w io.Writer, v any) error {
+			p, ok := v.(*Plant)
+			if !ok {
+				return fmt.Errorf("unexpected type %T", v)
+			}
+			_, err := fmt.Fprint(w, p.Name)
+			return err
+		})
+		defer api.SetEncoder("text/plain", nil)
+
+		w := get(t, "/plants/"+plant.GetID(), "text/plain")
+		require.Equal(t, "Fern", w.Body.String())
+	})
+
+	post := func(t *testing.T, contentType, body string) *Plant {
+		r := httptest.NewRequest(http.MethodPost, "/plants", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := babytest.TestRequest[*Plant](t, api, r)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var result Plant
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		return &result
+	}
+
+	t.Run("DecodeYAML", func(t *testing.T) {
+		result := post(t, "application/yaml", "name: Cactus\nheight: 0.5\ncount: 2\ntags: [dry]\n")
+		require.Equal(t, "Cactus", result.Name)
+		require.Equal(t, 0.5, result.Height)
+		require.Equal(t, []string{"dry"}, result.Tags)
+		require.NotEmpty(t, result.GetID())
+	})
+
+	t.Run("DecodeXML", func(t *testing.T) {
+		result := post(t, "application/xml", "<response><name>Ivy</name><count>4</count><indoor>true</indoor><tags><item>vine</item></tags><watered>false</watered></response>")
+		require.Equal(t, "Ivy", result.Name)
+		require.Equal(t, 4, result.Count)
+		require.True(t, result.Indoor)
+		require.Equal(t, []string{"vine"}, result.Tags)
+		require.NotNil(t, result.Watered)
+		require.False(t, *result.Watered)
+	})
+
+	t.Run("DecodeCSV", func(t *testing.T) {
+		result := post(t, "text/csv", "name,count,tags\nMoss,7,\"[\"\"damp\"\"]\"\n")
+		require.Equal(t, "Moss", result.Name)
+		require.Equal(t, 7, result.Count)
+		require.Equal(t, []string{"damp"}, result.Tags)
+	})
+
+	t.Run("MessagePackRoundTrip", func(t *testing.T) {
+		w := get(t, "/plants/"+plant.GetID(), "application/msgpack")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/msgpack; charset=utf-8", w.Header().Get("Content-Type"))
+
+		// Send the same document back to make sure it decodes to the original resource
+		r := httptest.NewRequest(http.MethodPut, "/plants/"+plant.GetID(), bytes.NewReader(w.Body.Bytes()))
+		r.Header.Set("Content-Type", "application/msgpack")
+		w = babytest.TestRequest[*Plant](t, api, r)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var result Plant
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		require.Equal(t, plant, &result)
+	})
+
+	t.Run("MessagePackLengthsAreBounded", func(t *testing.T) {
+		for name, body := range map[string][]byte{
+			"LargeArray":  {0xdd, 0xff, 0xff, 0xff, 0xff},
+			"LargeMap":    {0xdf, 0xff, 0xff, 0xff, 0xff},
+			"LargeString": {0xdb, 0xff, 0xff, 0xff, 0xff},
+			"DeepNesting": bytes.Repeat([]byte{0x91}, 10000),
+		} {
+			t.Run(name, func(t *testing.T) {
+				r := httptest.NewRequest(http.MethodPost, "/plants", bytes.NewReader(body))
+				r.Header.Set("Content-Type", "application/msgpack")
+				w := babytest.TestRequest[*Plant](t, api, r)
+				require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			})
+		}
+	})
+}
+
+func TestMessagePackIsOptIn(t *testing.T) {
+	api := babyapi.NewAPI[*Plant]("Plants", "/plants", func() *Plant { return &Plant{} })
+
+	r := httptest.NewRequest(http.MethodPost, "/plants", bytes.NewReader([]byte{0x80}))
+	r.Header.Set("Content-Type", "application/msgpack")
+	w := babytest.TestRequest[*Plant](t, api, r)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+type Seed struct {
+	babyapi.DefaultResource
+	Name string `json:"name"`
+}
+
+// Bind changes the name so running it more than once is visible
+func (s *Seed) Bind(r *http.Request) error {
+	s.Name += "!"
+	return s.DefaultResource.Bind(r)
+}
+
+func TestDecoderCallsBindOnce(t *testing.T) {
+	api := babyapi.NewAPI[*Seed]("Seeds", "/seeds", func() *Seed { return &Seed{} })
+
+	for _, contentType := range []string{"application/json", "application/yaml", "text/csv"} {
+		t.Run(contentType, func(t *testing.T) {
+			body := `{"name": "Sunflower"}`
+			if contentType == "text/csv" {
+				body = "name\nSunflower\n"
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/seeds", strings.NewReader(body))
+			r.Header.Set("Content-Type", contentType)
+			w := babytest.TestRequest[*Seed](t, api, r)
+			require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+			var result Seed
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			require.Equal(t, "Sunflower!", result.Name)
+		})
+	}
+}
@@ -10,6 +10,8 @@ import (
 var ErrNotFoundResponse = &ErrResponse{HTTPStatusCode: http.StatusNotFound, StatusText: "Resource not found."}
 var ErrMethodNotAllowedResponse = &ErrResponse{HTTPStatusCode: http.StatusMethodNotAllowed, StatusText: "Method not allowed."}
 var ErrForbidden = &ErrResponse{HTTPStatusCode: http.StatusForbidden, StatusText: "Forbidden"}
+var ErrUnauthorized = &ErrResponse{HTTPStatusCode: http.StatusUnauthorized, StatusText: "Unauthorized"}
+var ErrUnsupportedMediaTypeResponse = &ErrResponse{HTTPStatusCode: http.StatusUnsupportedMediaType, StatusText: "Unsupported media type."}
 
 // ErrResponse is an error that implements Renderer to be used in HTTP response
 type ErrResponse struct {
@@ -19,6 +21,9 @@ type ErrResponse struct {
 	StatusText string `json:"status"`          // user-level status message
 	AppCode    int64  `json:"code,omitempty"`  // application-specific error code
 	ErrorText  string `json:"error,omitempty"` // application-level error message, for debugging
+
+	ValidationErrors []FieldError `json:"validationErrors,omitempty"` // all fields that failed validation
+	RequestID        string       `json:"requestID,omitempty"`        // request ID for errors that need to be investigated
 }
 
 func (e *ErrResponse) Error() string {
@@ -363,6 +363,15 @@ func createAPI() *API {
 
 	api.Invites.SetCustomResponseCode(http.MethodDelete, http.StatusOK)
 
+	// Only list Invites for the Event in the path. This also allows embedding Invites in an Event
+	// response with ?expand=invites
+	api.Invites.SetGetAllFilter(func(r *http.Request) babyapi.FilterFunc[*Invite] {
+		eventID := api.Events.GetIDParam(r)
+		return func(i *Invite) bool {
+			return i.EventID == eventID
+		}
+	})
+
 	api.Invites.AddCustomRoute(chi.Route{
 		Pattern: "/bulk",
 		Handlers: map[string]http.Handler{
@@ -397,7 +406,7 @@ func createAPI() *API {
 
 	api.Events.GetAll = func(w http.ResponseWriter, r *http.Request) {
 		if render.GetAcceptedContentType(r) != render.ContentTypeHTML {
-			render.Render(w, r, babyapi.ErrForbidden)
+			_ = babyapi.Render(w, r, babyapi.ErrForbidden)
 			return
 		}
 
@@ -219,6 +219,23 @@ func TestAPI(t *testing.T) {
 				BodyRegexp: `{"items":\[{"id":"[0-9a-v]{20}","Name":"Firstname Lastname","Contact":"","EventID":"[0-9a-v]{20}","RSVP":null}]`,
 			},
 		},
+		{
+			Name: "ExportInvitesCSV",
+			Test: babytest.RequestFuncTest[*babyapi.AnyResource](func(getResponse babytest.PreviousResponseGetter, address string) *http.Request {
+				id := getResponse("CreateEvent").Data.GetID()
+				address = fmt.Sprintf("%s/events/%s/invites/export?password=secret", address, id)
+
+				r, err := http.NewRequest(http.MethodGet, address, http.NoBody)
+				require.NoError(t, err)
+
+				return r
+			}),
+			ClientName: "Invite",
+			ExpectedResponse: babytest.ExpectedResponse{
+				Status:     http.StatusOK,
+				BodyRegexp: `ID,Name,Contact,RSVP,Link\n[0-9a-v]{20},Firstname Lastname,,,127.0.0.1:\d+/events/[0-9a-v]{20}/invites/[0-9a-v]{20}`,
+			},
+		},
 		{
 			Name: "GetEventWithInviteIDAsPassword",
 			Test: babytest.RequestTest[*babyapi.AnyResource]{
@@ -48,7 +48,7 @@ const (
 				</tr>
 			</thead>
 
-			<tbody hx-ext="sse" sse-connect="/todos/listen" sse-swap="newTODO" hx-swap="beforeend">
+			<tbody hx-ext="sse" sse-connect="/todos/events?format=html" sse-swap="created" hx-swap="beforeend">
 				<form hx-post="/todos" hx-swap="none" hx-on::after-request="this.reset()">
 					<td>
 						<input class="uk-input" name="Title" type="text">
@@ -136,23 +136,8 @@ func main() {
 	// HTMX requires a 200 response code to do a swap after delete
 	api.SetCustomResponseCode(http.MethodDelete, http.StatusOK)
 
-	// Add SSE handler endpoint which will receive events on the returned channel and write them to the front-end
-	todoChan := api.AddServerSentEventHandler("/listen")
-
-	// Push events onto the SSE channel when new TODOs are created
-	api.SetOnCreateOrUpdate(func(r *http.Request, t *TODO) *babyapi.ErrResponse {
-		if r.Method != http.MethodPost {
-			return nil
-		}
-
-		select {
-		case todoChan <- &babyapi.ServerSentEvent{Event: "newTODO", Data: t.HTML(r)}:
-		default:
-			logger := babyapi.GetLoggerFromContext(r.Context())
-			logger.Info("no listeners for server-sent event")
-		}
-		return nil
-	})
+	// Stream created TODOs as HTML rows to the front-end at /todos/events
+	api.EnableChangeStream()
 
 	err := setupStorage(api)
 	if err != nil {
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/calvinmclean/babyapi"
@@ -68,5 +69,12 @@ func main() {
 		}
 	})
 
+	// Other services can subscribe to changes with POST /todos/webhooks using the X-API-Key from WEBHOOK_API_KEY
+	api.EnableWebhooks(babyapi.WebhookConfig{
+		Authorizer: babyapi.AuthenticationMiddleware(&babyapi.APIKeyAuthenticator{
+			Keys: map[string]string{"webhooks": os.Getenv("WEBHOOK_API_KEY")},
+		}),
+	})
+
 	api.RunCLI()
 }
@@ -0,0 +1,280 @@
+package babyapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+const (
+	expandQueryParam = "expand"
+
+	defaultMaxExpandDepth = 2
+	defaultMaxExpandItems = 100
+)
+
+// SetExpandKey sets the key used to embed this API's resources in the parent's response. It is used in the
+// parent's expand query parameter and as the JSON field name. The default is the base path without slashes
+func (a *API[T]) SetExpandKey(key string) *API[T] {
+	a.expandKeyName = key
+	return a
+}
+
+// SetMaxExpandItems limits the number of resources that are embedded in a parent's response when this API is expanded
+func (a *API[T]) SetMaxExpandItems(max int) *API[T] {
+	a.maxExpandItems = max
+	return a
+}
+
+// SetMaxExpandDepth limits how many levels of nested APIs can be expanded in one request. For example,
+// "?expand=albums.songs" has a depth of 2
+func (a *API[T]) SetMaxExpandDepth(depth int) *API[T] {
+	a.maxExpandDepth = depth
+	return a
+}
+
+func (a *API[T]) expandKey() string {
+	if a.expandKeyName != "" {
+		return a.expandKeyName
+	}
+	return strings.Trim(a.base, "/")
+}
+
+// expandedField is a child API's resources that are embedded in the parent response
+type expandedField struct {
+	key   string
+	items []render.Renderer
+}
+
+// expandedResponse wraps a response to add fields for expanded child resources. The resource is stored as
+// "any" because Render panics when walking into unexported fields implementing render.Renderer
+type expandedResponse struct {
+	resource any
+	expanded []expandedField
+}
+
+func (er *expandedResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return er.resource.(render.Renderer).Render(w, r)
+}
+
+// MarshalJSON appends the expanded fields to the end of the resource's JSON object so the original field order is kept
+func (er *expandedResponse) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(er.resource)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) < 2 || data[0] != '{' || data[len(data)-1] != '}' {
+		return nil, fmt.Errorf("unable to expand non-object response")
+	}
+
+	var result bytes.Buffer
+	result.Write(data[:len(data)-1])
+
+	needsComma := len(bytes.TrimSpace(data[1:len(data)-1])) > 0
+	for _, field := range er.expanded {
+		if needsComma {
+			result.WriteByte(',')
+		}
+		needsComma = true
+
+		key, err := json.Marshal(field.key)
+		if err != nil {
+			return nil, err
+		}
+		items, err := json.Marshal(field.items)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Write(key)
+		result.WriteByte(':')
+		result.Write(items)
+	}
+
+	result.WriteByte('}')
+
+	return result.Bytes(), nil
+}
+
+// expandPaths parses the expand query parameter into paths of nested API keys. Multiple APIs can be expanded
+// by separating with commas or repeating the parameter and nested APIs are separated with dots
+func (a *API[T]) expandPaths(r *http.Request) ([][]string, *ErrResponse) {
+	var paths [][]string
+	for _, param := range r.URL.Query()[expandQueryParam] {
+		for _, p := range strings.Split(param, ",") {
+			p = strings.TrimSpace(p)
+			if p == "" {
+				continue
+			}
+
+			path := strings.Split(p, ".")
+			if len(path) > a.maxExpandDepth {
+				return nil, ErrInvalidRequest(fmt.Errorf("expand %q exceeds maximum depth of %d", p, a.maxExpandDepth))
+			}
+			paths = append(paths, path)
+		}
+	}
+
+	return paths, nil
+}
+
+// expandResponse embeds child resources in the response when requested with the expand query parameter. HTML
+// responses are not modified since they are not able to have additional fields
+func (a *API[T]) expandResponse(w http.ResponseWriter, r *http.Request, resource T, resp render.Renderer) (render.Renderer, *ErrResponse) {
+	if render.GetAcceptedContentType(r) == render.ContentTypeHTML {
+		return resp, nil
+	}
+
+	paths, httpErr := a.expandPaths(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	return a.expand(w, r, resource, resp, paths)
+}
+
+func (a *API[T]) expand(w http.ResponseWriter, r *http.Request, resource T, resp render.Renderer, paths [][]string) (render.Renderer, *ErrResponse) {
+	if len(paths) == 0 {
+		return resp, nil
+	}
+
+	// Group paths by the first key so each child is only read once
+	var keys []string
+	nested := map[string][][]string{}
+	for _, path := range paths {
+		if _, ok := nested[path[0]]; !ok {
+			keys = append(keys, path[0])
+			nested[path[0]] = nil
+		}
+		if len(path) > 1 {
+			nested[path[0]] = append(nested[path[0]], path[1:])
+		}
+	}
+
+	result := &expandedResponse{resource: resp}
+	for _, key := range keys {
+		child := a.expandableChild(key)
+		if child == nil {
+			return nil, ErrInvalidRequest(fmt.Errorf("invalid expand: %q", key))
+		}
+
+		items, httpErr := child.getExpandedItems(w, a.newExpandRequest(r, resource), nested[key])
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		result.expanded = append(result.expanded, expandedField{child.expandKey(), items})
+	}
+
+	return result, nil
+}
+
+func (a *API[T]) expandableChild(key string) relatedAPI {
+	for _, child := range a.subAPIs {
+		if !child.isRoot() && child.expandKey() == key {
+			return child
+		}
+	}
+	return nil
+}
+
+// newExpandRequest creates a request for reading child resources. It has the parent's ID URL param and resource in
+// the context, just like a request to the child's path, so the child's GetAll filter and response wrapper can use them
+func (a *API[T]) newExpandRequest(r *http.Request, resource T) *http.Request {
+	rctx := chi.NewRouteContext()
+	if parentRctx := chi.RouteContext(r.Context()); parentRctx != nil {
+		rctx.URLParams.Keys = append(rctx.URLParams.Keys, parentRctx.URLParams.Keys...)
+		rctx.URLParams.Values = append(rctx.URLParams.Values, parentRctx.URLParams.Values...)
+	}
+	rctx.URLParams.Add(a.IDParamKey(), resource.GetID())
+
+	ctx := context.WithValue(r.Context(), chi.RouteCtxKey, rctx)
+	ctx = a.newContextWithResource(ctx, resource)
+
+	expandReq := r.Clone(ctx)
+	expandReq.URL.RawQuery = ""
+
+	return expandReq
+}
+
+// getExpandedItems reads and renders the API's resources using the GetAll filter and response wrapper, then recursively
+// expands nested APIs for each item
+func (a *API[T]) getExpandedItems(w http.ResponseWriter, r *http.Request, paths [][]string) ([]render.Renderer, *ErrResponse) {
+	logger := GetLoggerFromContext(r.Context())
+
+	r, httpErr := a.authorizeExpand(r)
+	if httpErr != nil {
+		logger.Info("expand is not authorized", "api", a.name, "error", httpErr.Error())
+		return nil, httpErr
+	}
+
+	resources, err := a.Storage.GetAll(a.requestFilter(r))
+	if err != nil {
+		logger.Error("error getting resources to expand", "error", err)
+		return nil, InternalServerError(err)
+	}
+
+	if a.maxExpandItems > 0 && len(resources) > a.maxExpandItems {
+		resources = resources[:a.maxExpandItems]
+	}
+
+	items := []render.Renderer{}
+	for _, item := range resources {
+		resp := a.responseWrapper(item)
+
+		err = resp.Render(w, r)
+		if err != nil {
+			logger.Error("unable to render expanded resource", "error", err)
+			return nil, ErrRender(err)
+		}
+
+		resp, httpErr = a.expand(w, r, item, resp, paths)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		items = append(items, resp)
+	}
+
+	return items, nil
+}
+
+// authorizeExpand runs the API's middleware and checks the authorization policy like a GetAll request to the API's
+// path, so expanding can't be used to read resources that the request can't list. The request from the middleware is
+// returned so it can be used to read the resources
+func (a *API[T]) authorizeExpand(r *http.Request) (*http.Request, *ErrResponse) {
+	var next *http.Request
+	var h http.Handler = http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		next = r
+	})
+	for i := len(a.middlewares) - 1; i >= 0; i-- {
+		h = a.middlewares[i](h)
+	}
+
+	w := &discardResponseWriter{}
+	h.ServeHTTP(w, r)
+	if next == nil {
+		status := w.status
+		if status == 0 {
+			status = http.StatusForbidden
+		}
+		return nil, &ErrResponse{HTTPStatusCode: status, StatusText: http.StatusText(status)}
+	}
+
+	if a.authorization != nil {
+		httpErr := a.authorize(next, VerbGetAll)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+	}
+
+	return next, nil
+}
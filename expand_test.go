@@ -0,0 +1,224 @@
+package babyapi_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/require"
+)
+
+type Author struct {
+	babyapi.DefaultResource
+	Name string `json:"name"`
+}
+
+type Book struct {
+	babyapi.DefaultResource
+	Title    string `json:"title"`
+	AuthorID string `json:"author_id"`
+}
+
+type Chapter struct {
+	babyapi.DefaultResource
+	Title  string `json:"title"`
+	BookID string `json:"book_id"`
+}
+
+type BookResponse struct {
+	*Book
+	AuthorName string `json:"author_name"`
+}
+
+func (br *BookResponse) Render(_ http.ResponseWriter, r *http.Request) error {
+	author, err := babyapi.GetResourceFromContext[*Author](r.Context(), babyapi.ContextKey("Authors"))
+	if err != nil {
+		return err
+	}
+	br.AuthorName = author.Name
+	return nil
+}
+
+func TestExpand(t *testing.T) {
+	authorAPI := babyapi.NewAPI[*Author]("Authors", "/authors", func() *Author { return &Author{} })
+	bookAPI := babyapi.NewAPI[*Book]("Books", "/books", func() *Book { return &Book{} })
+	chapterAPI := babyapi.NewAPI[*Chapter]("Chapters", "/chapters", func() *Chapter { return &Chapter{} })
+
+	bookAPI.SetGetAllFilter(func(r *http.Request) babyapi.FilterFunc[*Book] {
+		authorID := babyapi.GetIDParam(r, "Authors")
+		return func(b *Book) bool {
+			return b.AuthorID == authorID
+		}
+	})
+	bookAPI.SetResponseWrapper(func(b *Book) render.Renderer {
+		return &BookResponse{Book: b}
+	})
+	bookAPI.SetMaxExpandItems(2)
+
+	chapterAPI.SetExpandKey("contents")
+	chapterAPI.SetGetAllFilter(func(r *http.Request) babyapi.FilterFunc[*Chapter] {
+		bookID := babyapi.GetIDParam(r, "Books")
+		return func(c *Chapter) bool {
+			return c.BookID == bookID
+		}
+	})
+
+	authorAPI.AddNestedAPI(bookAPI)
+	bookAPI.AddNestedAPI(chapterAPI)
+
+	author1 := &Author{DefaultResource: babyapi.NewDefaultResource(), Name: "Author1"}
+	author2 := &Author{DefaultResource: babyapi.NewDefaultResource(), Name: "Author2"}
+	require.NoError(t, authorAPI.Storage.Set(author1))
+	require.NoError(t, authorAPI.Storage.Set(author2))
+
+	book1 := &Book{DefaultResource: babyapi.NewDefaultResource(), Title: "Book1", AuthorID: author1.GetID()}
+	require.NoError(t, bookAPI.Storage.Set(book1))
+
+	chapter1 := &Chapter{DefaultResource: babyapi.NewDefaultResource(), Title: "Chapter1", BookID: book1.GetID()}
+	require.NoError(t, chapterAPI.Storage.Set(chapter1))
+
+	t.Run("NoExpand", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/authors/"+author1.GetID(), http.NoBody)
+		w := babytest.TestRequest[*Author](t, authorAPI, r)
+
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		require.Equal(t, `{"id":"`+author1.GetID()+`","name":"Author1"}`, strings.TrimSpace(w.Body.String()))
+	})
+
+	t.Run("ExpandBooks", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/authors/"+author1.GetID()+"?expand=books", http.NoBody)
+		w := babytest.TestRequest[*Author](t, authorAPI, r)
+
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		require.Equal(t,
+			`{"id":"`+author1.GetID()+`","name":"Author1","books":[{"id":"`+book1.GetID()+`","title":"Book1","author_id":"`+author1.GetID()+`","author_name":"Author1"}]}`,
+			strings.TrimSpace(w.Body.String()),
+		)
+	})
+
+	t.Run("ExpandBooksFiltered", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/authors/"+author2.GetID()+"?expand=books", http.NoBody)
+		w := babytest.TestRequest[*Author](t, authorAPI, r)
+
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		require.Equal(t, `{"id":"`+author2.GetID()+`","name":"Author2","books":[]}`, strings.TrimSpace(w.Body.String()))
+	})
+
+	t.Run("ExpandNestedChapters", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/authors/"+author1.GetID()+"?expand=books.contents", http.NoBody)
+		w := babytest.TestRequest[*Author](t, authorAPI, r)
+
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		require.Equal(t,
+			`{"id":"`+author1.GetID()+`","name":"Author1","books":[{"id":"`+book1.GetID()+`","title":"Book1","author_id":"`+author1.GetID()+`","author_name":"Author1","contents":[{"id":"`+chapter1.GetID()+`","title":"Chapter1","book_id":"`+book1.GetID()+`"}]}]}`,
+			strings.TrimSpace(w.Body.String()),
+		)
+	})
+
+	t.Run("ExpandGetAll", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/authors/"+author1.GetID()+"/books?expand=contents", http.NoBody)
+		w := babytest.TestRequest[*Author](t, authorAPI, r)
+
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		require.Equal(t,
+			`{"items":[{"id":"`+book1.GetID()+`","title":"Book1","author_id":"`+author1.GetID()+`","author_name":"Author1","contents":[{"id":"`+chapter1.GetID()+`","title":"Chapter1","book_id":"`+book1.GetID()+`"}]}]}`,
+			strings.TrimSpace(w.Body.String()),
+		)
+	})
+
+	t.Run("MaxItems", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			require.NoError(t, bookAPI.Storage.Set(&Book{DefaultResource: babyapi.NewDefaultResource(), Title: "Book", AuthorID: author2.GetID()}))
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/authors/"+author2.GetID()+"?expand=books", http.NoBody)
+		w := babytest.TestRequest[*Author](t, authorAPI, r)
+
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		require.Equal(t, 2, strings.Count(w.Body.String(), `"title":"Book"`))
+	})
+
+	t.Run("ErrorInvalidKey", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/authors/"+author1.GetID()+"?expand=chapters", http.NoBody)
+		w := babytest.TestRequest[*Author](t, authorAPI, r)
+
+		require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		require.Equal(t, `{"status":"Invalid request.","error":"invalid expand: \"chapters\""}`, strings.TrimSpace(w.Body.String()))
+	})
+
+	t.Run("ErrorMaxDepth", func(t *testing.T) {
+		authorAPI.SetMaxExpandDepth(1)
+		defer authorAPI.SetMaxExpandDepth(2)
+
+		r := httptest.NewRequest(http.MethodGet, "/authors/"+author1.GetID()+"?expand=books.contents", http.NoBody)
+		w := babytest.TestRequest[*Author](t, authorAPI, r)
+
+		require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		require.Equal(t, `{"status":"Invalid request.","error":"expand \"books.contents\" exceeds maximum depth of 1"}`, strings.TrimSpace(w.Body.String()))
+	})
+}
+
+type Attachment struct {
+	babyapi.DefaultResource
+	Secret string `json:"secret"`
+}
+
+func TestExpandAuthorization(t *testing.T) {
+	ticketAPI := babyapi.NewAPI[*Ticket]("Tickets", "/tickets", func() *Ticket { return &Ticket{} })
+	ticketAPI.SetAuthenticators(&babyapi.APIKeyAuthenticator{
+		Keys:  map[string]string{"admin": "admin-key", "viewer": "viewer-key"},
+		Roles: map[string][]string{"admin": {"admin"}},
+	})
+
+	attachmentAPI := babyapi.NewAPI[*Attachment]("Attachments", "/attachments", func() *Attachment { return &Attachment{} })
+	attachmentAPI.SetAuthorizationPolicy(babyapi.AuthorizationPolicy[*Attachment]{
+		Roles: map[babyapi.Verb][]string{babyapi.VerbGetAll: {"admin"}},
+	})
+	ticketAPI.AddNestedAPI(attachmentAPI)
+
+	ticket := &Ticket{DefaultResource: babyapi.NewDefaultResource(), Text: "ticket"}
+	require.NoError(t, ticketAPI.Storage.Set(ticket))
+	require.NoError(t, attachmentAPI.Storage.Set(&Attachment{DefaultResource: babyapi.NewDefaultResource(), Secret: "s3cr3t"}))
+
+	request := func(path, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		r.Header.Set("X-API-Key", key)
+		return babytest.TestRequest[*Ticket](t, ticketAPI, r)
+	}
+
+	t.Run("ViewerCannotList", func(t *testing.T) {
+		w := request("/tickets/"+ticket.GetID()+"/attachments", "viewer-key")
+		require.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+	})
+
+	t.Run("ViewerCannotExpand", func(t *testing.T) {
+		w := request("/tickets/"+ticket.GetID()+"?expand=attachments", "viewer-key")
+		require.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+		require.NotContains(t, w.Body.String(), "s3cr3t")
+	})
+
+	t.Run("AdminCanExpand", func(t *testing.T) {
+		w := request("/tickets/"+ticket.GetID()+"?expand=attachments", "admin-key")
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		require.Contains(t, w.Body.String(), `"secret":"s3cr3t"`)
+	})
+
+	t.Run("ChildMiddlewareIsUsed", func(t *testing.T) {
+		attachmentAPI.AddMiddleware(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-Allow-Attachments") == "" {
+					w.WriteHeader(http.StatusTeapot)
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+		})
+
+		w := request("/tickets/"+ticket.GetID()+"?expand=attachments", "admin-key")
+		require.Equal(t, http.StatusTeapot, w.Result().StatusCode)
+	})
+}
@@ -3,20 +3,22 @@ module github.com/calvinmclean/babyapi
 go 1.21.3
 
 require (
+	github.com/FZambia/sentinel v1.1.1
 	github.com/go-chi/chi/v5 v5.0.10
 	github.com/go-chi/render v1.0.3
+	github.com/gomodule/redigo v1.8.9
+	github.com/gorilla/websocket v1.5.3
 	github.com/madflojo/hord v0.2.2
 	github.com/rs/xid v1.5.0
 	github.com/stretchr/testify v1.8.4
+	golang.org/x/crypto v0.16.0
 	golang.org/x/exp v0.0.0-20231110203233-9a3e6036ecaa
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/FZambia/sentinel v1.1.1 // indirect
 	github.com/ajg/form v1.5.1 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/gomodule/redigo v1.8.9 // indirect
 	github.com/kr/pretty v0.3.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
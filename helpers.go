@@ -103,13 +103,13 @@ func (a *API[T]) GetRequestedResourceAndDoMiddleware(do func(*http.Request, T) (
 				}
 
 				logger.Error("error getting requested resource", "error", httpErr.Error())
-				_ = render.Render(w, r, httpErr)
+				_ = Render(w, r, httpErr)
 				return
 			}
 
 			r, httpErr = do(r, resource)
 			if httpErr != nil {
-				_ = render.Render(w, r, httpErr)
+				_ = Render(w, r, httpErr)
 				return
 			}
 
@@ -143,7 +143,8 @@ func (a *API[T]) ReadRequestBodyAndDo(do func(*http.Request, T) (T, *ErrResponse
 	})
 }
 
-// GetFromRequest will read the API's resource type from the request body or request context
+// GetFromRequest will read the API's resource type from the request body or request context. After binding, the
+// resource is validated using its "validate" struct tags
 func (a *API[T]) GetFromRequest(r *http.Request) (T, *ErrResponse) {
 	resource := a.GetRequestBodyFromContext(r.Context())
 	if resource != *new(T) {
@@ -151,11 +152,16 @@ func (a *API[T]) GetFromRequest(r *http.Request) (T, *ErrResponse) {
 	}
 
 	resource = a.instance()
-	err := render.Bind(r, resource)
+	err := a.bind(r, resource)
 	if err != nil {
 		return *new(T), ErrInvalidRequest(err)
 	}
 
+	httpErr := validateRequestBody(r, resource)
+	if httpErr != nil {
+		return *new(T), httpErr
+	}
+
 	return resource, nil
 }
 
@@ -183,11 +189,11 @@ func Handler(do func(http.ResponseWriter, *http.Request) render.Renderer) http.H
 			return
 		}
 
-		err := render.Render(w, r, response)
+		err := Render(w, r, response)
 		if err != nil {
 			logger := GetLoggerFromContext(r.Context())
 			logger.Error("unable to render response", "error", err)
-			_ = render.Render(w, r, ErrRender(err))
+			_ = Render(w, r, ErrRender(err))
 		}
 	}
 }
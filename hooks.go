@@ -0,0 +1,193 @@
+package babyapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// Hook runs during a resource's lifecycle. previous is the stored resource before the request and resource is the
+// resource from the request. Either one is the zero value when it doesn't apply:
+//   - create: previous is empty and resource is the new resource
+//   - update: previous is the stored resource and resource is the updated resource
+//   - delete: previous is the stored resource and resource is empty
+//   - read: both are empty before reading and resource is the stored resource after reading
+//
+// The returned resource replaces resource for the following hooks and the rest of the request. Returning the zero
+// value keeps the current resource. Returning an ErrResponse stops the request and responds with the error
+type Hook[T Resource] func(r *http.Request, previous, resource T) (T, *ErrResponse)
+
+// ListHook runs before and after getting all resources. Before hooks receive nil resources. The returned slice
+// replaces the resources for the following hooks and the response. Returning an ErrResponse stops the request
+type ListHook[T Resource] func(r *http.Request, resources []T) ([]T, *ErrResponse)
+
+// hooks stores all lifecycle hooks in the order they are added
+type hooks[T Resource] struct {
+	beforeCreate []Hook[T]
+	afterCreate  []Hook[T]
+	beforeUpdate []Hook[T]
+	afterUpdate  []Hook[T]
+	beforeDelete []Hook[T]
+	afterDelete  []Hook[T]
+	beforeRead   []Hook[T]
+	afterRead    []Hook[T]
+	beforeList   []ListHook[T]
+	afterList    []ListHook[T]
+}
+
+// AddBeforeCreateHook adds hooks that run after OnCreateOrUpdate and before a new resource is stored by POST or PUT
+func (a *API[T]) AddBeforeCreateHook(hooks ...Hook[T]) *API[T] {
+	a.hooks.beforeCreate = append(a.hooks.beforeCreate, hooks...)
+	return a
+}
+
+// AddAfterCreateHook adds hooks that run after a new resource is stored by POST or PUT
+func (a *API[T]) AddAfterCreateHook(hooks ...Hook[T]) *API[T] {
+	a.hooks.afterCreate = append(a.hooks.afterCreate, hooks...)
+	return a
+}
+
+// AddBeforeUpdateHook adds hooks that run after OnCreateOrUpdate and before an existing resource is stored by PUT
+// or PATCH
+func (a *API[T]) AddBeforeUpdateHook(hooks ...Hook[T]) *API[T] {
+	a.hooks.beforeUpdate = append(a.hooks.beforeUpdate, hooks...)
+	return a
+}
+
+// AddAfterUpdateHook adds hooks that run after an existing resource is stored by PUT or PATCH
+func (a *API[T]) AddAfterUpdateHook(hooks ...Hook[T]) *API[T] {
+	a.hooks.afterUpdate = append(a.hooks.afterUpdate, hooks...)
+	return a
+}
+
+// AddBeforeDeleteHook adds hooks that run after the function from SetBeforeDelete and before a resource is deleted
+func (a *API[T]) AddBeforeDeleteHook(hooks ...Hook[T]) *API[T] {
+	a.hooks.beforeDelete = append(a.hooks.beforeDelete, hooks...)
+	return a
+}
+
+// AddAfterDeleteHook adds hooks that run after a resource is deleted and the function from SetAfterDelete
+func (a *API[T]) AddAfterDeleteHook(hooks ...Hook[T]) *API[T] {
+	a.hooks.afterDelete = append(a.hooks.afterDelete, hooks...)
+	return a
+}
+
+// AddBeforeReadHook adds hooks that run before a resource is read from storage by GET
+func (a *API[T]) AddBeforeReadHook(hooks ...Hook[T]) *API[T] {
+	a.hooks.beforeRead = append(a.hooks.beforeRead, hooks...)
+	return a
+}
+
+// AddAfterReadHook adds hooks that run after a resource is read from storage by GET and before it is rendered
+func (a *API[T]) AddAfterReadHook(hooks ...Hook[T]) *API[T] {
+	a.hooks.afterRead = append(a.hooks.afterRead, hooks...)
+	return a
+}
+
+// AddBeforeListHook adds hooks that run before resources are read from storage by GetAll
+func (a *API[T]) AddBeforeListHook(hooks ...ListHook[T]) *API[T] {
+	a.hooks.beforeList = append(a.hooks.beforeList, hooks...)
+	return a
+}
+
+// AddAfterListHook adds hooks that run after resources are read from storage by GetAll and before they are rendered
+func (a *API[T]) AddAfterListHook(hooks ...ListHook[T]) *API[T] {
+	a.hooks.afterList = append(a.hooks.afterList, hooks...)
+	return a
+}
+
+func runHooks[T Resource](r *http.Request, hooks []Hook[T], previous, resource T) (T, *ErrResponse) {
+	for _, hook := range hooks {
+		result, httpErr := hook(r, previous, resource)
+		if httpErr != nil {
+			return *new(T), httpErr
+		}
+		if result != *new(T) {
+			resource = result
+		}
+	}
+	return resource, nil
+}
+
+func runListHooks[T Resource](r *http.Request, hooks []ListHook[T], resources []T) ([]T, *ErrResponse) {
+	for _, hook := range hooks {
+		result, httpErr := hook(r, resources)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+		resources = result
+	}
+	return resources, nil
+}
+
+// saveResource runs OnCreateOrUpdate and the create or update hooks around storing the resource. previous is the
+// zero value when the resource is being created
+func (a *API[T]) saveResource(r *http.Request, previous, resource T) (T, *ErrResponse) {
+	logger := GetLoggerFromContext(r.Context())
+
+	before, after := a.hooks.beforeCreate, a.hooks.afterCreate
+	if previous != *new(T) {
+		before, after = a.hooks.beforeUpdate, a.hooks.afterUpdate
+	}
+
+	a.setOwner(r, previous, resource)
+
+	httpErr := a.onCreateOrUpdate(r, resource)
+	if httpErr != nil {
+		return *new(T), httpErr
+	}
+
+	resource, httpErr = runHooks(r, before, previous, resource)
+	if httpErr != nil {
+		logger.Error("error executing before hook", "error", httpErr)
+		return *new(T), httpErr
+	}
+
+	logger.Info("storing resource", "resource", resource)
+	err := a.Storage.Set(resource)
+	if err != nil {
+		logger.Error("error storing resource", "error", err)
+		return *new(T), InternalServerError(err)
+	}
+
+	resource, httpErr = runHooks(r, after, previous, resource)
+	if httpErr != nil {
+		logger.Error("error executing after hook", "error", httpErr)
+		return *new(T), httpErr
+	}
+
+	return resource, nil
+}
+
+// getPreviousResource gets the stored resource for PUT requests. It returns the zero value if the resource doesn't
+// exist because PUT is creating it
+func (a *API[T]) getPreviousResource(id string) (T, *ErrResponse) {
+	previous, err := a.Storage.Get(id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return *new(T), nil
+		}
+		return *new(T), InternalServerError(err)
+	}
+	return previous, nil
+}
+
+// copyResource creates a copy of the resource using its JSON representation so hooks can compare it with the
+// resource after it is modified in place by Patcher
+func (a *API[T]) copyResource(resource T) (T, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return *new(T), fmt.Errorf("error encoding resource: %w", err)
+	}
+
+	result := a.instance()
+	err = json.Unmarshal(data, result)
+	if err != nil {
+		return *new(T), fmt.Errorf("error decoding resource: %w", err)
+	}
+	copyHiddenFields(reflect.ValueOf(result), reflect.ValueOf(resource))
+
+	return result, nil
+}
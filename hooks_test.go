@@ -0,0 +1,131 @@
+package babyapi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooks(t *testing.T) {
+	api := babyapi.NewAPI[*Album]("Albums", "/albums", func() *Album { return &Album{} })
+
+	var events []string
+	record := func(event string) babyapi.Hook[*Album] {
+		return func(_ *http.Request, previous, resource *Album) (*Album, *babyapi.ErrResponse) {
+			title := func(a *Album) string {
+				if a == nil {
+					return "<nil>"
+				}
+				return a.Title
+			}
+			events = append(events, event+":"+title(previous)+"->"+title(resource))
+			return nil, nil
+		}
+	}
+
+	api.AddBeforeCreateHook(record("beforeCreate"), func(_ *http.Request, _, resource *Album) (*Album, *babyapi.ErrResponse) {
+		if resource.Title == "" {
+			return nil, babyapi.ErrInvalidRequest(errors.New("missing title"))
+		}
+		return &Album{DefaultResource: resource.DefaultResource, Title: strings.ToUpper(resource.Title)}, nil
+	}, record("beforeCreate"))
+	api.AddAfterCreateHook(record("afterCreate"))
+	api.AddBeforeUpdateHook(record("beforeUpdate"))
+	api.AddAfterUpdateHook(record("afterUpdate"))
+	api.AddBeforeDeleteHook(record("beforeDelete"))
+	api.AddAfterDeleteHook(record("afterDelete"))
+	api.AddBeforeReadHook(record("beforeRead"))
+	api.AddAfterReadHook(record("afterRead"), func(_ *http.Request, _, resource *Album) (*Album, *babyapi.ErrResponse) {
+		return &Album{DefaultResource: resource.DefaultResource, Title: resource.Title + " (read)"}, nil
+	})
+	api.AddBeforeListHook(func(r *http.Request, resources []*Album) ([]*Album, *babyapi.ErrResponse) {
+		events = append(events, "beforeList")
+		if r.URL.Query().Get("forbidden") != "" {
+			return nil, babyapi.ErrForbidden
+		}
+		return resources, nil
+	})
+	api.AddAfterListHook(func(_ *http.Request, resources []*Album) ([]*Album, *babyapi.ErrResponse) {
+		events = append(events, "afterList")
+		return resources[:1], nil
+	})
+
+	client, stop := babytest.NewTestClient[*Album](t, api)
+	defer stop()
+
+	var album *Album
+	t.Run("Create", func(t *testing.T) {
+		events = nil
+		resp, err := client.Post(context.Background(), &Album{Title: "first"})
+		require.NoError(t, err)
+		album = resp.Data
+
+		require.Equal(t, "FIRST", album.Title)
+		require.Equal(t, []string{"beforeCreate:<nil>->first", "beforeCreate:<nil>->FIRST", "afterCreate:<nil>->FIRST"}, events)
+	})
+
+	t.Run("AbortCreate", func(t *testing.T) {
+		events = nil
+		_, err := client.Post(context.Background(), &Album{})
+		require.Error(t, err)
+
+		var errResp *babyapi.ErrResponse
+		require.True(t, errors.As(err, &errResp))
+		require.Equal(t, http.StatusBadRequest, errResp.HTTPStatusCode)
+		require.Equal(t, []string{"beforeCreate:<nil>->"}, events)
+	})
+
+	t.Run("Read", func(t *testing.T) {
+		events = nil
+		resp, err := client.Get(context.Background(), album.GetID())
+		require.NoError(t, err)
+
+		require.Equal(t, "FIRST (read)", resp.Data.Title)
+		require.Equal(t, []string{"beforeRead:<nil>-><nil>", "afterRead:<nil>->FIRST"}, events)
+	})
+
+	t.Run("UpdateWithPut", func(t *testing.T) {
+		events = nil
+		_, err := client.Put(context.Background(), &Album{DefaultResource: album.DefaultResource, Title: "second"})
+		require.NoError(t, err)
+		require.Equal(t, []string{"beforeUpdate:FIRST->second", "afterUpdate:FIRST->second"}, events)
+	})
+
+	t.Run("UpdateWithPatcher", func(t *testing.T) {
+		events = nil
+		_, err := client.Patch(context.Background(), album.GetID(), &Album{Title: "third"})
+		require.NoError(t, err)
+		require.Equal(t, []string{"beforeUpdate:second->third", "afterUpdate:second->third"}, events)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		_, err := client.Post(context.Background(), &Album{Title: "other"})
+		require.NoError(t, err)
+
+		events = nil
+		resp, err := client.GetAll(context.Background(), "")
+		require.NoError(t, err)
+		require.Len(t, resp.Data.Items, 1)
+		require.Equal(t, []string{"beforeList", "afterList"}, events)
+	})
+
+	t.Run("AbortList", func(t *testing.T) {
+		events = nil
+		_, err := client.GetAll(context.Background(), "forbidden=true")
+		require.Error(t, err)
+		require.Equal(t, []string{"beforeList"}, events)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		events = nil
+		_, err := client.Delete(context.Background(), album.GetID())
+		require.NoError(t, err)
+		require.Equal(t, []string{"beforeDelete:third-><nil>", "afterDelete:third-><nil>"}, events)
+	})
+}
@@ -0,0 +1,506 @@
+package babyapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+const jsonPatchContentType = "application/json-patch+json"
+
+// ErrJSONPatchTestFailed is returned when a JSON Patch test operation does not match the resource
+var ErrJSONPatchTestFailed = errors.New("test operation failed")
+
+// JSONPatchOperation is a single operation in an RFC 6902 JSON Patch document. Value is used by add, replace, and
+// test operations and From is used by move and copy operations
+type JSONPatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// MarshalJSON always includes the value for operations that require it, even if it is null
+func (op JSONPatchOperation) MarshalJSON() ([]byte, error) {
+	type operation JSONPatchOperation
+	if op.Op != "add" && op.Op != "replace" && op.Op != "test" {
+		return json.Marshal(operation(op))
+	}
+
+	return json.Marshal(struct {
+		operation
+		Value any `json:"value"`
+	}{operation(op), op.Value})
+}
+
+// jsonPatchOperation is used to decode operations so a null value can be distinguished from a missing value
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// EnableJSONPatch applies PATCH requests with the application/json-patch+json content type as a JSON Patch (RFC 6902)
+// for resources that don't implement Patcher. Operations use the resource as it is rendered in responses, so fields
+// hidden by the response wrapper can't be read or modified. Like EnableMergePatch, the result is decoded into a new
+// instance, so only enable this if Bind is safe to call on resources that were already bound
+func (a *API[T]) EnableJSONPatch() *API[T] {
+	a.jsonPatch = true
+	return a
+}
+
+// usesJSONPatch returns true if PATCH requests with the JSON Patch content type are handled as a JSON Patch
+func (a *API[T]) usesJSONPatch() bool {
+	return a.jsonPatch && !a.isPatcher()
+}
+
+func isJSONPatchRequest(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == jsonPatchContentType
+}
+
+// defaultJSONPatch applies an RFC 6902 JSON Patch to the rendered JSON representation of the stored resource. The
+// result goes through Bind, validation, OnCreateOrUpdate, and the update hooks. If any operation fails, the resource is
+// not modified
+func (a *API[T]) defaultJSONPatch() http.HandlerFunc {
+	return Handler(func(w http.ResponseWriter, r *http.Request) render.Renderer {
+		logger := GetLoggerFromContext(r.Context())
+
+		resource, httpErr := a.GetRequestedResource(r)
+		if httpErr != nil {
+			logger.Error("error getting requested resource", "error", httpErr.Error())
+			return httpErr
+		}
+
+		var operations []jsonPatchOperation
+		err := json.NewDecoder(r.Body).Decode(&operations)
+		if err != nil {
+			return ErrInvalidRequest(fmt.Errorf("error decoding JSON patch: %w", err))
+		}
+
+		view, hidden, err := a.jsonPatchDocument(r, resource)
+		if err != nil {
+			return InternalServerError(err)
+		}
+
+		err = checkHiddenPaths(operations, hidden)
+		if err != nil {
+			logger.Error("error applying JSON patch", "error", err)
+			return errJSONPatch(err)
+		}
+
+		doc, err := applyJSONPatch(view, operations)
+		if err != nil {
+			logger.Error("error applying JSON patch", "error", err)
+			return errJSONPatch(err)
+		}
+
+		patchedObj, ok := doc.(map[string]any)
+		if !ok {
+			return errJSONPatch(errors.New("patched resource must be a JSON object"))
+		}
+
+		// Fields hidden from the response keep their stored values
+		for key, value := range hidden {
+			patchedObj[key] = value
+		}
+
+		patched, httpErr := a.bindPatchedDocument(r, resource, doc)
+		if httpErr != nil {
+			logger.Error("error binding patched resource", "error", httpErr.Error())
+			return httpErr
+		}
+
+		return a.storePatchedResource(r, resource, patched)
+	})
+}
+
+// jsonPatchDocument gets the resource as it is rendered in responses and the top-level fields of the stored resource
+// that are hidden from the response. Operations only use the rendered resource so hidden fields can't be read
+func (a *API[T]) jsonPatchDocument(r *http.Request, resource T) (any, map[string]any, error) {
+	rendered, err := a.renderJSON(r, resource)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	view, err := resourceDocument(rendered)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored, err := resourceDocument(resource)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	viewObj, _ := view.(map[string]any)
+	storedObj, _ := stored.(map[string]any)
+
+	hidden := map[string]any{}
+	for key, value := range storedObj {
+		if _, ok := viewObj[key]; !ok {
+			hidden[key] = value
+		}
+	}
+
+	return view, hidden, nil
+}
+
+// checkHiddenPaths rejects operations that use fields hidden from the response. They are reported the same way as
+// missing fields so clients can't find out which hidden fields exist
+func checkHiddenPaths(operations []jsonPatchOperation, hidden map[string]any) error {
+	for i, op := range operations {
+		for _, pointer := range []*string{op.Path, op.From} {
+			if pointer == nil {
+				continue
+			}
+
+			path, err := parseJSONPointer(*pointer)
+			if err != nil || len(path) == 0 {
+				continue
+			}
+
+			if _, ok := hidden[path[0]]; ok {
+				return fmt.Errorf("operation %d (%s): path not found: %q", i, op.Op, path[0])
+			}
+		}
+	}
+	return nil
+}
+
+// errJSONPatch returns 409 Conflict when a test operation fails and 422 Unprocessable Entity when an operation can't
+// be applied to the resource
+func errJSONPatch(err error) *ErrResponse {
+	if errors.Is(err, ErrJSONPatchTestFailed) {
+		return &ErrResponse{
+			Err:            err,
+			HTTPStatusCode: http.StatusConflict,
+			StatusText:     "Conflict.",
+			ErrorText:      err.Error(),
+		}
+	}
+
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusUnprocessableEntity,
+		StatusText:     "Unprocessable entity.",
+		ErrorText:      err.Error(),
+	}
+}
+
+// applyJSONPatch applies each operation in order and stops at the first error
+func applyJSONPatch(doc any, operations []jsonPatchOperation) (any, error) {
+	for i, op := range operations {
+		var err error
+		doc, err = applyJSONPatchOperation(doc, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s): %w", i, op.Op, err)
+		}
+	}
+	return doc, nil
+}
+
+func applyJSONPatchOperation(doc any, op jsonPatchOperation) (any, error) {
+	if op.Path == nil {
+		return nil, errors.New("missing path")
+	}
+	path, err := parseJSONPointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, errors.New("missing value")
+		}
+
+		value, err := decodeJSONValue(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value: %w", err)
+		}
+
+		switch op.Op {
+		case "add":
+			return jsonPointerAdd(doc, path, value)
+		case "replace":
+			return jsonPointerReplace(doc, path, value)
+		default:
+			current, err := jsonPointerGet(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !jsonEqual(current, value) {
+				return nil, fmt.Errorf("%w: value at %q does not match", ErrJSONPatchTestFailed, *op.Path)
+			}
+			return doc, nil
+		}
+	case "remove":
+		return jsonPointerRemove(doc, path)
+	case "move", "copy":
+		if op.From == nil {
+			return nil, errors.New("missing from")
+		}
+		from, err := parseJSONPointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := jsonPointerGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "copy" {
+			value, err = copyJSONValue(value)
+			if err != nil {
+				return nil, err
+			}
+			return jsonPointerAdd(doc, path, value)
+		}
+
+		if strings.HasPrefix(*op.Path+"/", *op.From+"/") && *op.Path != *op.From {
+			return nil, errors.New("cannot move a value into one of its children")
+		}
+
+		doc, err = jsonPointerRemove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return jsonPointerAdd(doc, path, value)
+	}
+
+	return nil, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// parseJSONPointer splits an RFC 6901 JSON Pointer into reference tokens. The empty pointer refers to the whole document
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func jsonPointerGet(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path not found: %q", token)
+			}
+			doc = value
+		case []any:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("path not found: %q", token)
+		}
+	}
+	return doc, nil
+}
+
+func jsonPointerAdd(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return updateJSONParent(doc, path, func(parent any, key string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[key] = value
+			return node, nil
+		case []any:
+			if key == "-" {
+				return append(node, value), nil
+			}
+			i, err := arrayIndex(key, len(node))
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		return nil, fmt.Errorf("path not found: %q", key)
+	})
+}
+
+func jsonPointerRemove(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, errors.New("cannot remove the whole resource")
+	}
+
+	return updateJSONParent(doc, path, func(parent any, key string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			if _, ok := node[key]; !ok {
+				return nil, fmt.Errorf("path not found: %q", key)
+			}
+			delete(node, key)
+			return node, nil
+		case []any:
+			i, err := arrayIndex(key, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:i], node[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("path not found: %q", key)
+	})
+}
+
+func jsonPointerReplace(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return updateJSONParent(doc, path, func(parent any, key string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			if _, ok := node[key]; !ok {
+				return nil, fmt.Errorf("path not found: %q", key)
+			}
+			node[key] = value
+			return node, nil
+		case []any:
+			i, err := arrayIndex(key, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			node[i] = value
+			return node, nil
+		}
+		return nil, fmt.Errorf("path not found: %q", key)
+	})
+}
+
+// updateJSONParent walks to the parent of the target location and replaces it with the result of update. This is
+// required because modifying an array can create a new slice that has to be set in its parent
+func updateJSONParent(doc any, path []string, update func(parent any, key string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return update(doc, path[0])
+	}
+
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("path not found: %q", path[0])
+		}
+		newChild, err := updateJSONParent(child, path[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		node[path[0]] = newChild
+		return node, nil
+	case []any:
+		i, err := arrayIndex(path[0], len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		newChild, err := updateJSONParent(node[i], path[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = newChild
+		return node, nil
+	}
+
+	return nil, fmt.Errorf("path not found: %q", path[0])
+}
+
+// arrayIndex parses an array index from a JSON Pointer token and checks that it is not greater than maxIndex
+func arrayIndex(token string, maxIndex int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > maxIndex {
+		return 0, fmt.Errorf("array index out of bounds: %d", i)
+	}
+
+	return i, nil
+}
+
+func decodeJSONValue(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	err := decoder.Decode(&value)
+	return value, err
+}
+
+func copyJSONValue(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSONValue(data)
+}
+
+// jsonEqual compares decoded JSON values. Numbers are compared by value so 1 and 1.0 are equal
+func jsonEqual(a, b any) bool {
+	switch av := a.(type) {
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, aErr := av.Float64()
+		bf, bErr := bv.Float64()
+		return aErr == nil && bErr == nil && af == bf
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key, value := range av {
+			other, ok := bv[key]
+			if !ok || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
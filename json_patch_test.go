@@ -0,0 +1,213 @@
+package babyapi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/require"
+)
+
+type Guest struct {
+	Name string `json:"name"`
+	RSVP *bool  `json:"rsvp"`
+}
+
+type GuestList struct {
+	babyapi.DefaultResource
+	Title  string  `json:"title" validate:"required"`
+	Host   string  `json:"host"`
+	Guests []Guest `json:"guests"`
+}
+
+func TestJSONPatch(t *testing.T) {
+	api := babyapi.NewAPI[*GuestList]("GuestLists", "/guest_lists", func() *GuestList { return &GuestList{} })
+	api.EnableJSONPatch()
+
+	yes := true
+	list := &GuestList{
+		DefaultResource: babyapi.NewDefaultResource(),
+		Title:           "Party",
+		Guests:          []Guest{{Name: "Alice", RSVP: &yes}, {Name: "Bob"}},
+	}
+	require.NoError(t, api.Storage.Set(list))
+
+	client, stop := babytest.NewTestClient[*GuestList](t, api)
+	defer stop()
+
+	t.Run("AddAndReplace", func(t *testing.T) {
+		resp, err := client.JSONPatch(context.Background(), list.GetID(), []babyapi.JSONPatchOperation{
+			{Op: "add", Path: "/guests/-", Value: map[string]any{"name": "Carol"}},
+			{Op: "add", Path: "/guests/0", Value: map[string]any{"name": "Zed"}},
+			{Op: "replace", Path: "/title", Value: "Big Party"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, "Big Party", resp.Data.Title)
+		require.Equal(t, []Guest{{Name: "Zed"}, {Name: "Alice", RSVP: &yes}, {Name: "Bob"}, {Name: "Carol"}}, resp.Data.Guests)
+	})
+
+	t.Run("TestThenRemove", func(t *testing.T) {
+		resp, err := client.JSONPatch(context.Background(), list.GetID(), []babyapi.JSONPatchOperation{
+			{Op: "test", Path: "/guests/0/name", Value: "Zed"},
+			{Op: "remove", Path: "/guests/0"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []Guest{{Name: "Alice", RSVP: &yes}, {Name: "Bob"}, {Name: "Carol"}}, resp.Data.Guests)
+	})
+
+	t.Run("FailedTestReturnsConflict", func(t *testing.T) {
+		_, err := client.JSONPatch(context.Background(), list.GetID(), []babyapi.JSONPatchOperation{
+			{Op: "test", Path: "/guests/0/name", Value: "Zed"},
+			{Op: "remove", Path: "/guests/0"},
+		})
+		require.Error(t, err)
+
+		var errResp *babyapi.ErrResponse
+		require.True(t, errors.As(err, &errResp))
+		require.Equal(t, http.StatusConflict, errResp.HTTPStatusCode)
+
+		stored, err := api.Storage.Get(list.GetID())
+		require.NoError(t, err)
+		require.Len(t, stored.Guests, 3)
+	})
+
+	t.Run("TestNullValue", func(t *testing.T) {
+		_, err := client.JSONPatch(context.Background(), list.GetID(), []babyapi.JSONPatchOperation{
+			{Op: "test", Path: "/guests/1/rsvp", Value: nil},
+			{Op: "replace", Path: "/guests/1/rsvp", Value: false},
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("MoveAndCopy", func(t *testing.T) {
+		resp, err := client.JSONPatch(context.Background(), list.GetID(), []babyapi.JSONPatchOperation{
+			{Op: "copy", From: "/guests/0/name", Path: "/host"},
+			{Op: "move", From: "/guests/2", Path: "/guests/0"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, "Alice", resp.Data.Host)
+		require.Equal(t, "Carol", resp.Data.Guests[0].Name)
+		require.Equal(t, "Alice", resp.Data.Guests[1].Name)
+	})
+
+	t.Run("MissingPathIsUnprocessable", func(t *testing.T) {
+		_, err := client.JSONPatch(context.Background(), list.GetID(), []babyapi.JSONPatchOperation{
+			{Op: "remove", Path: "/guests/10"},
+		})
+		require.Error(t, err)
+
+		var errResp *babyapi.ErrResponse
+		require.True(t, errors.As(err, &errResp))
+		require.Equal(t, http.StatusUnprocessableEntity, errResp.HTTPStatusCode)
+	})
+
+	t.Run("ValidatedAfterPatch", func(t *testing.T) {
+		_, err := client.JSONPatch(context.Background(), list.GetID(), []babyapi.JSONPatchOperation{
+			{Op: "remove", Path: "/title"},
+		})
+		require.Error(t, err)
+
+		var errResp *babyapi.ErrResponse
+		require.True(t, errors.As(err, &errResp))
+		require.Equal(t, http.StatusBadRequest, errResp.HTTPStatusCode)
+		require.Len(t, errResp.ValidationErrors, 1)
+	})
+
+	t.Run("PatcherIsNotOverridden", func(t *testing.T) {
+		albumAPI := babyapi.NewAPI[*Album]("Albums", "/albums", func() *Album { return &Album{} }).EnableJSONPatch()
+		album := &Album{DefaultResource: babyapi.NewDefaultResource(), Title: "Old"}
+		require.NoError(t, albumAPI.Storage.Set(album))
+
+		albumClient, stop := babytest.NewTestClient[*Album](t, albumAPI)
+		defer stop()
+
+		_, err := albumClient.JSONPatch(context.Background(), album.GetID(), []babyapi.JSONPatchOperation{
+			{Op: "replace", Path: "/title", Value: "New"},
+		})
+		require.Error(t, err)
+
+		var errResp *babyapi.ErrResponse
+		require.True(t, errors.As(err, &errResp))
+		require.Equal(t, http.StatusUnsupportedMediaType, errResp.HTTPStatusCode)
+
+		stored, err := albumAPI.Storage.Get(album.GetID())
+		require.NoError(t, err)
+		require.Equal(t, "Old", stored.Title)
+	})
+}
+
+func TestJSONPatchIsOptIn(t *testing.T) {
+	api := babyapi.NewAPI[*GuestList]("GuestLists", "/guest_lists", func() *GuestList { return &GuestList{} })
+
+	list := &GuestList{DefaultResource: babyapi.NewDefaultResource(), Title: "Party"}
+	require.NoError(t, api.Storage.Set(list))
+
+	client, stop := babytest.NewTestClient[*GuestList](t, api)
+	defer stop()
+
+	_, err := client.JSONPatch(context.Background(), list.GetID(), []babyapi.JSONPatchOperation{
+		{Op: "replace", Path: "/title", Value: "Changed"},
+	})
+	require.Error(t, err)
+
+	var errResp *babyapi.ErrResponse
+	require.True(t, errors.As(err, &errResp))
+	require.Equal(t, http.StatusMethodNotAllowed, errResp.HTTPStatusCode)
+
+	stored, err := api.Storage.Get(list.GetID())
+	require.NoError(t, err)
+	require.Equal(t, "Party", stored.Title)
+}
+
+type Account struct {
+	babyapi.DefaultResource
+	Name     string `json:"name"`
+	APIToken string `json:"apiToken,omitempty"`
+}
+
+func TestJSONPatchHiddenFields(t *testing.T) {
+	api := babyapi.NewAPI[*Account]("Accounts", "/accounts", func() *Account { return &Account{} }).EnableJSONPatch()
+	api.SetResponseWrapper(func(account *Account) render.Renderer {
+		result := *account
+		result.APIToken = ""
+		return &result
+	})
+
+	account := &Account{DefaultResource: babyapi.NewDefaultResource(), Name: "alice", APIToken: "s3cr3t-value"}
+	require.NoError(t, api.Storage.Set(account))
+
+	client, stop := babytest.NewTestClient[*Account](t, api)
+	defer stop()
+
+	for _, operations := range [][]babyapi.JSONPatchOperation{
+		{{Op: "copy", From: "/apiToken", Path: "/name"}},
+		{{Op: "test", Path: "/apiToken", Value: "s3cr3t-value"}},
+		{{Op: "replace", Path: "/apiToken", Value: "changed"}},
+		{{Op: "add", Path: "/apiToken", Value: "changed"}},
+	} {
+		_, err := client.JSONPatch(context.Background(), account.GetID(), operations)
+		require.Error(t, err)
+
+		var errResp *babyapi.ErrResponse
+		require.True(t, errors.As(err, &errResp))
+		require.Equal(t, http.StatusUnprocessableEntity, errResp.HTTPStatusCode)
+		require.NotContains(t, errResp.ErrorText, "s3cr3t-value")
+	}
+
+	t.Run("HiddenFieldsAreKept", func(t *testing.T) {
+		resp, err := client.JSONPatch(context.Background(), account.GetID(), []babyapi.JSONPatchOperation{
+			{Op: "replace", Path: "/name", Value: "bob"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, "bob", resp.Data.Name)
+		require.Empty(t, resp.Data.APIToken)
+
+		stored, err := api.Storage.Get(account.GetID())
+		require.NoError(t, err)
+		require.Equal(t, "s3cr3t-value", stored.APIToken)
+	})
+}
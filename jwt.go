@@ -0,0 +1,200 @@
+package babyapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+var jwtAlgorithms = map[string]func() hash.Hash{
+	"HS256": sha256.New,
+	"HS384": sha512.New384,
+	"HS512": sha512.New,
+}
+
+const defaultJWTAlgorithm = "HS256"
+
+// JWTAuthenticator authenticates requests with an HMAC-signed JWT bearer token. The "exp" and "nbf" claims are
+// checked when they are set
+type JWTAuthenticator struct {
+	// Secret is the HMAC key used to sign tokens. It is required
+	Secret []byte
+	// Algorithm is the HMAC algorithm that tokens have to use: HS256, HS384, or HS512. It defaults to HS256 and tokens
+	// using any other algorithm are rejected
+	Algorithm string
+	// Issuer is required to match the "iss" claim when it is set
+	Issuer string
+	// Audience is required to be in the "aud" claim when it is set
+	Audience string
+	// Leeway allows for clock differences when checking "exp" and "nbf"
+	Leeway time.Duration
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// Authenticate verifies the token's signature and claims. The Principal ID is the "sub" claim
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := a.verify(strings.TrimSpace(token), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	subject, _ := claims["sub"].(string)
+	return &Principal{ID: subject, Method: "jwt", Roles: jwtRoles(claims["roles"]), Claims: claims}, nil
+}
+
+// jwtRoles reads the "roles" claim, which can be a string or an array of strings
+func jwtRoles(roles any) []string {
+	switch roles := roles.(type) {
+	case string:
+		return []string{roles}
+	case []any:
+		result := []string{}
+		for _, role := range roles {
+			if role, ok := role.(string); ok {
+				result = append(result, role)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+func (a *JWTAuthenticator) challenge() string {
+	return "Bearer"
+}
+
+// validate checks the configuration so mistakes are found when the authenticator is used with the API instead of
+// allowing tokens signed with an empty key
+func (a *JWTAuthenticator) validate() error {
+	if len(a.Secret) == 0 {
+		return errors.New("JWTAuthenticator requires a Secret")
+	}
+	if _, ok := jwtAlgorithms[a.algorithm()]; !ok {
+		return fmt.Errorf("unsupported JWT algorithm %q", a.Algorithm)
+	}
+	return nil
+}
+
+func (a *JWTAuthenticator) algorithm() string {
+	if a.Algorithm == "" {
+		return defaultJWTAlgorithm
+	}
+	return a.Algorithm
+}
+
+func (a *JWTAuthenticator) verify(token string, now time.Time) (map[string]any, error) {
+	err := a.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token must have three parts")
+	}
+
+	var header jwtHeader
+	err = decodeJWTPart(parts[0], &header)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding header: %w", err)
+	}
+
+	if header.Algorithm != a.algorithm() {
+		return nil, fmt.Errorf("unexpected algorithm %q", header.Algorithm)
+	}
+	newHash := jwtAlgorithms[header.Algorithm]
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("error decoding signature: %w", err)
+	}
+
+	mac := hmac.New(newHash, a.Secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errors.New("invalid signature")
+	}
+
+	var claims map[string]any
+	err = decodeJWTPart(parts[1], &claims)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding claims: %w", err)
+	}
+
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(a.Leeway)) {
+		return nil, errors.New("token is expired")
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(a.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token is not valid yet")
+	}
+
+	if a.Issuer != "" && claims["iss"] != a.Issuer {
+		return nil, errors.New("invalid issuer")
+	}
+
+	if a.Audience != "" && !jwtAudienceContains(claims["aud"], a.Audience) {
+		return nil, errors.New("invalid audience")
+	}
+
+	return claims, nil
+}
+
+// jwtAudienceContains checks the "aud" claim, which can be a string or an array of strings
+func jwtAudienceContains(aud any, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		return slices.Contains(aud, any(audience))
+	default:
+		return false
+	}
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// SignJWT creates an HS256 JWT with the claims. It is useful for tests and for services that issue their own tokens
+func SignJWT(secret []byte, claims map[string]any) (string, error) {
+	header, err := json.Marshal(jwtHeader{Algorithm: "HS256", Type: "JWT"})
+	if err != nil {
+		return "", fmt.Errorf("error encoding header: %w", err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("error encoding claims: %w", err)
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
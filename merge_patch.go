@@ -0,0 +1,204 @@
+package babyapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+// EnableMergePatch applies PATCH requests for resources that don't implement Patcher as a JSON Merge Patch (RFC 7386).
+// The patched resource is decoded into a new instance, so Bind runs again with the stored resource's fields. Only
+// enable this if Bind is safe to call on resources that were already bound. Otherwise, these PATCH requests respond
+// with 405 Method Not Allowed.
+//
+// Exported fields with the `json:"-"` tag are copied from the stored resource, including fields of embedded structs.
+// Unexported fields can't be copied, so they have their zero value after a PATCH request unless Bind sets them
+func (a *API[T]) EnableMergePatch() *API[T] {
+	a.mergePatch = true
+	return a
+}
+
+// usesMergePatch returns true if PATCH requests are handled as a JSON Merge Patch
+func (a *API[T]) usesMergePatch() bool {
+	return a.mergePatch && !a.isPatcher()
+}
+
+// isPatcher returns true if the resource type implements Patcher and uses custom PATCH logic
+func (a *API[T]) isPatcher() bool {
+	_, ok := any(*new(T)).(Patcher[T])
+	return ok
+}
+
+// defaultMergePatch handles PATCH requests for resources that don't implement Patcher when EnableMergePatch is used.
+// It applies the request body to the stored resource as an RFC 7386 JSON Merge Patch. The result is decoded into a
+// new instance and goes through Bind, validation, and onCreateOrUpdate just like a PUT request
+func (a *API[T]) defaultMergePatch() http.HandlerFunc {
+	return Handler(func(w http.ResponseWriter, r *http.Request) render.Renderer {
+		logger := GetLoggerFromContext(r.Context())
+
+		resource, httpErr := a.GetRequestedResource(r)
+		if httpErr != nil {
+			logger.Error("error getting requested resource", "error", httpErr.Error())
+			return httpErr
+		}
+
+		var patch any
+		err := json.NewDecoder(r.Body).Decode(&patch)
+		if err != nil {
+			return ErrInvalidRequest(fmt.Errorf("error decoding merge patch: %w", err))
+		}
+
+		patched, httpErr := a.applyMergePatch(r, resource, patch)
+		if httpErr != nil {
+			logger.Error("error applying merge patch", "error", httpErr.Error())
+			return httpErr
+		}
+
+		return a.storePatchedResource(r, resource, patched)
+	})
+}
+
+// applyMergePatch merges the patch document with the JSON representation of the resource
+func (a *API[T]) applyMergePatch(r *http.Request, resource T, patch any) (T, *ErrResponse) {
+	if _, ok := patch.(map[string]any); !ok {
+		return *new(T), ErrInvalidRequest(errors.New("merge patch must be a JSON object"))
+	}
+
+	original, err := resourceDocument(resource)
+	if err != nil {
+		return *new(T), InternalServerError(err)
+	}
+
+	return a.bindPatchedDocument(r, resource, mergePatch(original, patch))
+}
+
+// storePatchedResource runs OnCreateOrUpdate and the update hooks and saves the result of a generic PATCH
+func (a *API[T]) storePatchedResource(r *http.Request, original, resource T) render.Renderer {
+	resource, httpErr := a.saveResource(r, original, resource)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	render.Status(r, a.responseCodes[http.MethodPatch])
+
+	return a.responseWrapper(resource)
+}
+
+// bindPatchedDocument decodes the patched document into a new instance and calls Bind and validation. Fields that
+// are excluded from JSON are copied from the original resource so they aren't lost
+func (a *API[T]) bindPatchedDocument(r *http.Request, original T, doc any) (T, *ErrResponse) {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return *new(T), InternalServerError(err)
+	}
+
+	bindRequest := r.Clone(newContextWithPatchedID(r.Context(), original.GetID()))
+	bindRequest.Body = io.NopCloser(bytes.NewReader(body))
+	bindRequest.ContentLength = int64(len(body))
+	bindRequest.Header.Set("Content-Type", "application/json")
+
+	resource := a.instance()
+	copyHiddenFields(reflect.ValueOf(resource), reflect.ValueOf(original))
+
+	err = render.Bind(bindRequest, resource)
+	if err != nil {
+		return *new(T), ErrInvalidRequest(err)
+	}
+
+	if resource.GetID() != original.GetID() {
+		return *new(T), ErrInvalidRequest(errors.New("updating ID is not allowed"))
+	}
+
+	httpErr := validateResource(resource, false)
+	if httpErr != nil {
+		return *new(T), httpErr
+	}
+
+	return resource, nil
+}
+
+// resourceDocument gets the generic JSON representation of a resource so it can be patched
+func resourceDocument(resource any) (any, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding resource: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc any
+	err = decoder.Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding resource: %w", err)
+	}
+
+	return doc, nil
+}
+
+// mergePatch implements the MergePatch algorithm from RFC 7386. Null values in the patch remove the member from the
+// target and objects are merged recursively. Any other value replaces the target
+func mergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+
+	return targetObj
+}
+
+// copyHiddenFields copies exported fields with the `json:"-"` tag since they are not part of the patched document.
+// Embedded structs and pointers to structs are copied recursively. Unexported fields can't be set with reflection
+func copyHiddenFields(dst, src reflect.Value) {
+	dst, src = derefValue(dst), derefValue(src)
+	if !dst.IsValid() || !src.IsValid() || dst.Kind() != reflect.Struct || dst.Type() != src.Type() {
+		return
+	}
+
+	for i := 0; i < dst.NumField(); i++ {
+		f := dst.Type().Field(i)
+
+		jsonName, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch {
+		case jsonName == "-" && f.IsExported():
+			dst.Field(i).Set(src.Field(i))
+		case f.Anonymous && jsonName == "" && f.Type.Kind() == reflect.Struct:
+			copyHiddenFields(dst.Field(i), src.Field(i))
+		case f.Anonymous && jsonName == "" && f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct:
+			if !dst.Field(i).IsNil() || src.Field(i).IsNil() || !dst.Field(i).CanSet() {
+				copyHiddenFields(dst.Field(i), src.Field(i))
+				continue
+			}
+
+			// Decoding only allocates the embedded struct if the document has one of its fields, so it is allocated
+			// here if it has hidden values
+			embedded := reflect.New(f.Type.Elem())
+			copyHiddenFields(embedded, src.Field(i))
+			if !embedded.Elem().IsZero() {
+				dst.Field(i).Set(embedded)
+			}
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package babyapi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/stretchr/testify/require"
+)
+
+type Settings struct {
+	Theme    string `json:"theme"`
+	Language string `json:"language"`
+}
+
+type Profile struct {
+	babyapi.DefaultResource
+	Name     string    `json:"name" validate:"required"`
+	Nickname *string   `json:"nickname"`
+	Settings *Settings `json:"settings"`
+	Secret   string    `json:"-"`
+}
+
+func TestMergePatch(t *testing.T) {
+	api := babyapi.NewAPI[*Profile]("Profiles", "/profiles", func() *Profile { return &Profile{} }).EnableMergePatch()
+	api.SetOnCreateOrUpdate(func(r *http.Request, p *Profile) *babyapi.ErrResponse {
+		if p.Name == "admin" {
+			return babyapi.ErrInvalidRequest(errors.New("reserved name"))
+		}
+		return nil
+	})
+
+	nickname := "Bobby"
+	profile := &Profile{
+		DefaultResource: babyapi.NewDefaultResource(),
+		Name:            "Bob",
+		Nickname:        &nickname,
+		Settings:        &Settings{Theme: "dark", Language: "en"},
+		Secret:          "hidden",
+	}
+	require.NoError(t, api.Storage.Set(profile))
+
+	client, stop := babytest.NewTestClient[*Profile](t, api)
+	defer stop()
+
+	t.Run("MergeNestedObject", func(t *testing.T) {
+		resp, err := client.PatchRaw(context.Background(), profile.GetID(), `{"settings": {"theme": "light"}}`)
+		require.NoError(t, err)
+		require.Equal(t, &Settings{Theme: "light", Language: "en"}, resp.Data.Settings)
+		require.Equal(t, "Bob", resp.Data.Name)
+		require.Equal(t, "Bobby", *resp.Data.Nickname)
+	})
+
+	t.Run("NullClearsField", func(t *testing.T) {
+		resp, err := client.PatchRaw(context.Background(), profile.GetID(), `{"nickname": null, "settings": {"language": null}}`)
+		require.NoError(t, err)
+		require.Nil(t, resp.Data.Nickname)
+		require.Equal(t, &Settings{Theme: "light"}, resp.Data.Settings)
+	})
+
+	t.Run("HiddenFieldsArePreserved", func(t *testing.T) {
+		stored, err := api.Storage.Get(profile.GetID())
+		require.NoError(t, err)
+		require.Equal(t, "hidden", stored.Secret)
+	})
+
+	t.Run("ValidationAfterMerge", func(t *testing.T) {
+		_, err := client.PatchRaw(context.Background(), profile.GetID(), `{"name": null}`)
+		require.Error(t, err)
+
+		var errResp *babyapi.ErrResponse
+		require.True(t, errors.As(err, &errResp))
+		require.Equal(t, []babyapi.FieldError{{Field: "name", Rule: "required", Message: "is required"}}, errResp.ValidationErrors)
+	})
+
+	t.Run("OnCreateOrUpdate", func(t *testing.T) {
+		_, err := client.PatchRaw(context.Background(), profile.GetID(), `{"name": "admin"}`)
+		require.Error(t, err)
+		require.Equal(t, "error patching resource: unexpected response with text: Invalid request.", err.Error())
+
+		stored, err := api.Storage.Get(profile.GetID())
+		require.NoError(t, err)
+		require.Equal(t, "Bob", stored.Name)
+	})
+
+	t.Run("CannotChangeID", func(t *testing.T) {
+		_, err := client.PatchRaw(context.Background(), profile.GetID(), `{"id": "`+babyapi.NewID().String()+`"}`)
+		require.Error(t, err)
+	})
+
+	t.Run("MustBeObject", func(t *testing.T) {
+		_, err := client.PatchRaw(context.Background(), profile.GetID(), `["name"]`)
+		require.Error(t, err)
+	})
+
+	t.Run("MergePatchContentType", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPatch, "/profiles/"+profile.GetID(), strings.NewReader(`{"name": "Robert"}`))
+		r.Header.Set("Content-Type", "application/merge-patch+json")
+
+		w := babytest.TestRequest[*Profile](t, api, r)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		require.Contains(t, w.Body.String(), `"name":"Robert"`)
+	})
+}
+
+func TestMergePatchIsOptIn(t *testing.T) {
+	api := babyapi.NewAPI[*Profile]("Profiles", "/profiles", func() *Profile { return &Profile{} })
+
+	profile := &Profile{DefaultResource: babyapi.NewDefaultResource(), Name: "Bob"}
+	require.NoError(t, api.Storage.Set(profile))
+
+	r := httptest.NewRequest(http.MethodPatch, "/profiles/"+profile.GetID(), strings.NewReader(`{"name": "Robert"}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	w := babytest.TestRequest[*Profile](t, api, r)
+	require.Equal(t, http.StatusMethodNotAllowed, w.Result().StatusCode)
+
+	stored, err := api.Storage.Get(profile.GetID())
+	require.NoError(t, err)
+	require.Equal(t, "Bob", stored.Name)
+}
+
+type Audit struct {
+	CreatedBy string `json:"createdBy"`
+	Token     string `json:"-"`
+}
+
+type Document struct {
+	babyapi.DefaultResource
+	*Audit
+	Title string `json:"title"`
+
+	revision int
+}
+
+func TestMergePatchEmbeddedAndUnexportedFields(t *testing.T) {
+	api := babyapi.NewAPI[*Document]("Documents", "/documents", func() *Document { return &Document{} }).EnableMergePatch()
+
+	document := &Document{
+		DefaultResource: babyapi.NewDefaultResource(),
+		Audit:           &Audit{CreatedBy: "alice", Token: "hidden"},
+		Title:           "Draft",
+		revision:        3,
+	}
+	require.NoError(t, api.Storage.Set(document))
+
+	client, stop := babytest.NewTestClient[*Document](t, api)
+	defer stop()
+
+	_, err := client.PatchRaw(context.Background(), document.GetID(), `{"title": "Final", "createdBy": null}`)
+	require.NoError(t, err)
+
+	stored, err := api.Storage.Get(document.GetID())
+	require.NoError(t, err)
+	require.Equal(t, "Final", stored.Title)
+
+	// Hidden fields in embedded pointers are kept even if the document doesn't have any of the embedded fields
+	require.Equal(t, &Audit{Token: "hidden"}, stored.Audit)
+
+	// Unexported fields can't be copied, as documented by EnableMergePatch
+	require.Zero(t, stored.revision)
+}
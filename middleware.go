@@ -7,14 +7,13 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/render"
 )
 
 func (a *API[T]) DefaultMiddleware(r chi.Router) {
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Recoverer)
 	r.Use(a.logMiddleware)
+	r.Use(a.recoverMiddleware)
 }
 
 func (a *API[T]) logMiddleware(next http.Handler) http.Handler {
@@ -48,9 +47,15 @@ func (a *API[T]) logMiddleware(next http.Handler) http.Handler {
 
 func (a *API[T]) requestBodyMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Generic PATCH requests need to read the raw patch document
+		if r.Method == http.MethodPatch && (a.usesMergePatch() || isJSONPatchRequest(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		body, httpErr := a.GetFromRequest(r)
 		if httpErr != nil {
-			_ = render.Render(w, r, httpErr)
+			_ = Render(w, r, httpErr)
 			return
 		}
 
@@ -70,7 +75,7 @@ func (a *API[T]) resourceExistsMiddleware(next http.Handler) http.Handler {
 				next.ServeHTTP(w, r)
 				return
 			}
-			_ = render.Render(w, r, httpErr)
+			_ = Render(w, r, httpErr)
 			return
 		}
 
@@ -84,3 +89,22 @@ func (a *API[T]) resourceExistsMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// headMiddleware discards the response body for HEAD requests. This allows using GET handlers for HEAD requests
+func headMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w = &headResponseWriter{w}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// headResponseWriter is used for HEAD requests to write headers without a body
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
@@ -0,0 +1,322 @@
+package babyapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// encodeMessagePack writes the JSON structure of v using the MessagePack format. Map keys are sorted so the output
+// is consistent
+func encodeMessagePack(w io.Writer, v any) error {
+	doc, err := genericDocument(v)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	err = writeMessagePack(bw, doc)
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeMessagePack(w *bufio.Writer, v any) error {
+	switch value := v.(type) {
+	case nil:
+		return w.WriteByte(0xc0)
+	case bool:
+		if value {
+			return w.WriteByte(0xc3)
+		}
+		return w.WriteByte(0xc2)
+	case int64:
+		return writeMessagePackInt(w, value)
+	case float64:
+		err := w.WriteByte(0xcb)
+		if err != nil {
+			return err
+		}
+		return binary.Write(w, binary.BigEndian, math.Float64bits(value))
+	case string:
+		err := writeMessagePackHeader(w, len(value), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		if err != nil {
+			return err
+		}
+		_, err = w.WriteString(value)
+		return err
+	case []any:
+		err := writeMessagePackHeader(w, len(value), 0x90, 15, 0, 0xdc, 0xdd)
+		if err != nil {
+			return err
+		}
+		for _, item := range value {
+			err = writeMessagePack(w, item)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]any:
+		err := writeMessagePackHeader(w, len(value), 0x80, 15, 0, 0xde, 0xdf)
+		if err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			err = writeMessagePack(w, key)
+			if err != nil {
+				return err
+			}
+			err = writeMessagePack(w, value[key])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unsupported type for MessagePack: %T", v)
+}
+
+func writeMessagePackInt(w *bufio.Writer, i int64) error {
+	switch {
+	case i >= 0 && i <= 127:
+		return w.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		return w.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return writeMessagePackValue(w, 0xd0, int8(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		return writeMessagePackValue(w, 0xd1, int16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return writeMessagePackValue(w, 0xd2, int32(i))
+	default:
+		return writeMessagePackValue(w, 0xd3, i)
+	}
+}
+
+// writeMessagePackHeader writes the type and length for strings, arrays, and maps. The fixed format is used for
+// short values and the 8-bit format is skipped if it is 0 since arrays and maps don't have one
+func writeMessagePackHeader(w *bufio.Writer, length int, fixed byte, fixedMax int, format8, format16, format32 byte) error {
+	switch {
+	case length <= fixedMax:
+		return w.WriteByte(fixed | byte(length))
+	case format8 != 0 && length <= math.MaxUint8:
+		return writeMessagePackValue(w, format8, uint8(length))
+	case length <= math.MaxUint16:
+		return writeMessagePackValue(w, format16, uint16(length))
+	default:
+		return writeMessagePackValue(w, format32, uint32(length))
+	}
+}
+
+func writeMessagePackValue(w *bufio.Writer, format byte, value any) error {
+	err := w.WriteByte(format)
+	if err != nil {
+		return err
+	}
+	return binary.Write(w, binary.BigEndian, value)
+}
+
+// maxMessagePackDepth limits nesting of arrays and maps so a small body can't exhaust the stack
+const maxMessagePackDepth = 100
+
+// decodeMessagePack reads a MessagePack document and decodes it into v using JSON field names. The body is read
+// before decoding so declared lengths can be checked against the remaining bytes
+func decodeMessagePack(r io.Reader, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error reading MessagePack: %w", err)
+	}
+
+	reader := &messagePackReader{data: data}
+	doc, err := reader.read(0)
+	if err != nil {
+		return fmt.Errorf("error reading MessagePack: %w", err)
+	}
+	return decodeGeneric(doc, v)
+}
+
+// messagePackReader decodes from a byte slice. Lengths are never trusted: strings and binary values must fit in the
+// remaining bytes and every array item or map entry uses at least one byte, so allocations are bounded by the body size
+type messagePackReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *messagePackReader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+// next returns the next n bytes or io.ErrUnexpectedEOF if the body is too short
+func (r *messagePackReader) next(n int) ([]byte, error) {
+	if n < 0 || n > r.remaining() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	result := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return result, nil
+}
+
+// uint reads a big-endian unsigned integer with size bytes
+func (r *messagePackReader) uint(size int) (uint64, error) {
+	data, err := r.next(size)
+	if err != nil {
+		return 0, err
+	}
+
+	var result uint64
+	for _, b := range data {
+		result = result<<8 | uint64(b)
+	}
+	return result, nil
+}
+
+// length reads an 8, 16, or 32-bit length and makes sure the body has at least that many bytes left
+func (r *messagePackReader) length(size int) (int, error) {
+	length, err := r.uint(size)
+	if err != nil {
+		return 0, err
+	}
+	if length > uint64(r.remaining()) {
+		return 0, fmt.Errorf("length %d exceeds the remaining %d bytes", length, r.remaining())
+	}
+	return int(length), nil
+}
+
+func (r *messagePackReader) read(depth int) (any, error) {
+	if depth > maxMessagePackDepth {
+		return nil, errors.New("MessagePack document is nested too deeply")
+	}
+
+	format, err := r.uint(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case format <= 0x7f:
+		return int64(format), nil
+	case format >= 0xe0:
+		return int64(int8(format)), nil
+	case format&0xf0 == 0x80:
+		return r.readMap(int(format&0x0f), depth)
+	case format&0xf0 == 0x90:
+		return r.readArray(int(format&0x0f), depth)
+	case format&0xe0 == 0xa0:
+		return r.readString(int(format & 0x1f))
+	}
+
+	switch format {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		length, err := r.length(1 << (format - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.next(length)
+		return bytes.Clone(data), err
+	case 0xca:
+		bits, err := r.uint(4)
+		return float64(math.Float32frombits(uint32(bits))), err
+	case 0xcb:
+		bits, err := r.uint(8)
+		return math.Float64frombits(bits), err
+	case 0xcc, 0xcd, 0xce:
+		i, err := r.uint(1 << (format - 0xcc))
+		return int64(i), err
+	case 0xcf:
+		return r.uint(8)
+	case 0xd0:
+		i, err := r.uint(1)
+		return int64(int8(i)), err
+	case 0xd1:
+		i, err := r.uint(2)
+		return int64(int16(i)), err
+	case 0xd2:
+		i, err := r.uint(4)
+		return int64(int32(i)), err
+	case 0xd3:
+		i, err := r.uint(8)
+		return int64(i), err
+	case 0xd9, 0xda, 0xdb:
+		length, err := r.length(1 << (format - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return r.readString(length)
+	case 0xdc, 0xdd:
+		length, err := r.length(2 << (format - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return r.readArray(length, depth)
+	case 0xde, 0xdf:
+		length, err := r.length(2 << (format - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return r.readMap(length, depth)
+	}
+
+	return nil, fmt.Errorf("unsupported MessagePack format: 0x%x", format)
+}
+
+func (r *messagePackReader) readString(length int) (string, error) {
+	data, err := r.next(length)
+	return string(data), err
+}
+
+func (r *messagePackReader) readArray(length, depth int) ([]any, error) {
+	var result []any
+	for i := 0; i < length; i++ {
+		item, err := r.read(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	if result == nil {
+		result = []any{}
+	}
+	return result, nil
+}
+
+func (r *messagePackReader) readMap(length, depth int) (map[string]any, error) {
+	result := map[string]any{}
+	for i := 0; i < length; i++ {
+		key, err := r.read(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		keyString, ok := key.(string)
+		if !ok {
+			return nil, errors.New("MessagePack map keys must be strings")
+		}
+
+		result[keyString], err = r.read(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,633 @@
+package babyapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+const (
+	openAPIVersion = "3.1.0"
+	openAPIPath    = "/openapi.json"
+
+	jsonContentType = "application/json"
+)
+
+// OpenAPIDocument is the root of an OpenAPI 3.1 document generated from the API tree
+type OpenAPIDocument struct {
+	OpenAPI    string                      `json:"openapi"`
+	Info       OpenAPIInfo                 `json:"info"`
+	Paths      map[string]*OpenAPIPathItem `json:"paths"`
+	Components OpenAPIComponents           `json:"components"`
+}
+
+// OpenAPIInfo provides metadata about the API
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIComponents holds schemas which are referenced from operations
+type OpenAPIComponents struct {
+	Schemas map[string]*OpenAPISchema `json:"schemas,omitempty"`
+
+	// schemaTypes is the Go type for each schema name so types with the same name from different packages are
+	// given different names
+	schemaTypes map[string]reflect.Type
+}
+
+// OpenAPIPathItem describes the operations available on a single path
+type OpenAPIPathItem struct {
+	Parameters []*OpenAPIParameter `json:"parameters,omitempty"`
+
+	Get    *OpenAPIOperation `json:"get,omitempty"`
+	Put    *OpenAPIOperation `json:"put,omitempty"`
+	Post   *OpenAPIOperation `json:"post,omitempty"`
+	Delete *OpenAPIOperation `json:"delete,omitempty"`
+	Head   *OpenAPIOperation `json:"head,omitempty"`
+	Patch  *OpenAPIOperation `json:"patch,omitempty"`
+}
+
+// OpenAPIOperation describes a single API operation on a path
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter describes a path or query parameter
+type OpenAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *OpenAPISchema `json:"schema,omitempty"`
+}
+
+// OpenAPIRequestBody describes the request body for an operation
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a single response from an operation
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType has the schema for a request or response content type
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema,omitempty"`
+}
+
+// OpenAPISchema is a JSON Schema used to describe request and response bodies
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 any                       `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+}
+
+// RouteDocs adds OpenAPI metadata for a custom route. Request and Response are example values that are used to
+// create the schemas for the request and response bodies. Leave them nil if the route has no body
+type RouteDocs struct {
+	Summary      string
+	Description  string
+	Request      any
+	Response     any
+	ResponseCode int
+}
+
+// openAPIConfig holds the user-provided OpenAPI metadata for an API
+type openAPIConfig struct {
+	route       bool
+	info        *OpenAPIInfo
+	routeDocs   map[string]RouteDocs
+	idRouteDocs map[string]RouteDocs
+}
+
+// EnableOpenAPI adds the GET /openapi.json endpoint to serve the generated OpenAPI document. It uses the same
+// middleware as the API's routes, so it requires the same authentication. Panics if the API is already a child
+func (a *API[T]) EnableOpenAPI() *API[T] {
+	if a.parent != nil {
+		panic("cannot be applied to child APIs")
+	}
+	a.openAPI.route = true
+	return a
+}
+
+// SetOpenAPIInfo sets the info section of the OpenAPI document. By default, the title is the API name
+func (a *API[T]) SetOpenAPIInfo(info OpenAPIInfo) *API[T] {
+	a.openAPI.info = &info
+	return a
+}
+
+// SetCustomRouteDocs adds OpenAPI metadata to a route added with AddCustomRoute
+func (a *API[T]) SetCustomRouteDocs(method, pattern string, docs RouteDocs) *API[T] {
+	if a.openAPI.routeDocs == nil {
+		a.openAPI.routeDocs = map[string]RouteDocs{}
+	}
+	a.openAPI.routeDocs[routeDocsKey(method, pattern)] = docs
+	return a
+}
+
+// SetCustomIDRouteDocs adds OpenAPI metadata to a route added with AddCustomIDRoute
+func (a *API[T]) SetCustomIDRouteDocs(method, pattern string, docs RouteDocs) *API[T] {
+	if a.openAPI.idRouteDocs == nil {
+		a.openAPI.idRouteDocs = map[string]RouteDocs{}
+	}
+	a.openAPI.idRouteDocs[routeDocsKey(method, pattern)] = docs
+	return a
+}
+
+func routeDocsKey(method, pattern string) string {
+	return method + " " + pattern
+}
+
+// OpenAPI generates an OpenAPI document from this API and all of its nested APIs. PATCH is only documented when the
+// resource is a Patcher or merge patch or JSON Patch is enabled, with a request content type for each of them
+func (a *API[T]) OpenAPI() *OpenAPIDocument {
+	info := OpenAPIInfo{Title: a.name, Version: "1.0.0"}
+	if a.openAPI.info != nil {
+		info = *a.openAPI.info
+	}
+
+	doc := &OpenAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info:    info,
+		Paths:   map[string]*OpenAPIPathItem{},
+		Components: OpenAPIComponents{
+			Schemas: map[string]*OpenAPISchema{},
+		},
+	}
+
+	for _, cr := range a.rootRoutes {
+		a.addCustomRouteOperations(doc, cr, "", nil)
+	}
+
+	a.addOpenAPIPaths(doc, "")
+
+	return doc
+}
+
+func (a *API[T]) defaultOpenAPI(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, a.OpenAPI())
+}
+
+// addOpenAPIPaths adds operations for this API to the document and recursively adds nested APIs. The prefix is the
+// full path of the parent resource
+func (a *API[T]) addOpenAPIPaths(doc *OpenAPIDocument, prefix string) {
+	collectionPath := joinOpenAPIPath(prefix, a.base)
+
+	if a.rootAPI {
+		a.addOperation(doc, collectionPath, http.MethodGet, a.Get, a.customOperation(doc, "Get", RouteDocs{}))
+		a.addOperation(doc, collectionPath, http.MethodPost, a.Post, a.customOperation(doc, "Post", RouteDocs{}))
+		a.addOperation(doc, collectionPath, http.MethodPut, a.Put, a.customOperation(doc, "Put", RouteDocs{}))
+		a.addOperation(doc, collectionPath, http.MethodPatch, a.Patch, a.customOperation(doc, "Patch", RouteDocs{}))
+		a.addOperation(doc, collectionPath, http.MethodDelete, a.Delete, a.customOperation(doc, "Delete", RouteDocs{}))
+
+		for _, cr := range a.customRoutes {
+			a.addCustomRouteOperations(doc, cr, collectionPath, a.openAPI.routeDocs)
+		}
+
+		for _, child := range a.sortedSubAPIs() {
+			child.addOpenAPIPaths(doc, strings.TrimSuffix(collectionPath, "/"))
+		}
+		return
+	}
+
+	gen := &schemaGenerator{&doc.Components}
+	resourceSchema := gen.schema(reflect.TypeOf(new(T)).Elem())
+	errSchema := gen.schema(reflect.TypeOf(ErrResponse{}))
+
+	listSchema := &OpenAPISchema{
+		Type:       "object",
+		Properties: map[string]*OpenAPISchema{"items": {Type: "array", Items: resourceSchema}},
+	}
+	if a.getAllResponseWrapper != nil {
+		listSchema = &OpenAPISchema{Description: "custom response"}
+	}
+
+	a.addOperation(doc, collectionPath, http.MethodGet, a.GetAll, &OpenAPIOperation{
+		OperationID: "list" + a.name,
+		Summary:     fmt.Sprintf("Get all %s", a.name),
+		Parameters: []*OpenAPIParameter{{
+			Name:        expandQueryParam,
+			In:          "query",
+			Description: "Nested resources to embed in the response",
+			Schema:      &OpenAPISchema{Type: "string"},
+		}},
+		Responses: a.responses(http.MethodGet, listSchema, errSchema),
+	})
+
+	a.addOperation(doc, collectionPath, http.MethodPost, a.Post, &OpenAPIOperation{
+		OperationID: "create" + a.name,
+		Summary:     fmt.Sprintf("Create a new %s resource", a.name),
+		RequestBody: jsonRequestBody(resourceSchema),
+		Responses:   a.responses(http.MethodPost, resourceSchema, errSchema),
+	})
+
+	// Count and Aggregate are only routed with GetAll
+	if a.GetAll != nil {
+		a.addOperation(doc, collectionPath+"/_count", http.MethodGet, a.Count, &OpenAPIOperation{
+			OperationID: "count" + a.name,
+			Summary:     fmt.Sprintf("Count %s", a.name),
+			Responses:   a.responses(http.MethodGet, gen.schema(reflect.TypeOf(CountResponse{})), errSchema),
+		})
+
+		a.addOperation(doc, collectionPath+"/_aggregate", http.MethodGet, a.Aggregate, &OpenAPIOperation{
+			OperationID: "aggregate" + a.name,
+			Summary:     fmt.Sprintf("Count %s grouped by the values of a field", a.name),
+			Parameters: []*OpenAPIParameter{{
+				Name:     groupByQueryParam,
+				In:       "query",
+				Required: true,
+				Schema:   &OpenAPISchema{Type: "string"},
+			}},
+			Responses: a.responses(http.MethodGet, gen.schema(reflect.TypeOf(AggregateResponse{})), errSchema),
+		})
+	}
+
+	if a.searchIndex != nil {
+		a.addOperation(doc, collectionPath+"/_search", http.MethodGet, a.defaultSearch(), &OpenAPIOperation{
+			OperationID: "search" + a.name,
+			Summary:     fmt.Sprintf("Search %s", a.name),
+			Parameters: []*OpenAPIParameter{{
+				Name:     searchQueryParam,
+				In:       "query",
+				Required: true,
+				Schema:   &OpenAPISchema{Type: "string"},
+			}},
+			Responses: a.responses(http.MethodGet, listSchema, errSchema),
+		})
+	}
+
+	idPath := fmt.Sprintf("%s/{%s}", collectionPath, a.IDParamKey())
+
+	a.addOperation(doc, idPath, http.MethodGet, a.Get, &OpenAPIOperation{
+		OperationID: "get" + a.name,
+		Summary:     fmt.Sprintf("Get a %s resource by ID", a.name),
+		Responses:   a.responses(http.MethodGet, resourceSchema, errSchema),
+	})
+
+	a.addOperation(doc, idPath, http.MethodPut, a.Put, &OpenAPIOperation{
+		OperationID: "put" + a.name,
+		Summary:     fmt.Sprintf("Create or update a %s resource by ID", a.name),
+		RequestBody: jsonRequestBody(resourceSchema),
+		Responses:   a.responses(http.MethodPut, resourceSchema, errSchema),
+	})
+
+	// The default PATCH handler responds with 405 if there aren't any supported patch formats
+	if patchBody := a.patchRequestBody(gen, resourceSchema); patchBody != nil {
+		a.addOperation(doc, idPath, http.MethodPatch, a.Patch, &OpenAPIOperation{
+			OperationID: "patch" + a.name,
+			Summary:     fmt.Sprintf("Modify a %s resource by ID", a.name),
+			RequestBody: patchBody,
+			Responses:   a.responses(http.MethodPatch, resourceSchema, errSchema),
+		})
+	}
+
+	a.addOperation(doc, idPath, http.MethodDelete, a.Delete, &OpenAPIOperation{
+		OperationID: "delete" + a.name,
+		Summary:     fmt.Sprintf("Delete a %s resource by ID", a.name),
+		Responses:   a.responses(http.MethodDelete, nil, errSchema),
+	})
+
+	for _, cr := range a.customRoutes {
+		a.addCustomRouteOperations(doc, cr, collectionPath, a.openAPI.routeDocs)
+	}
+
+	for _, cr := range a.customIDRoutes {
+		a.addCustomRouteOperations(doc, cr, idPath, a.openAPI.idRouteDocs)
+	}
+
+	for _, child := range a.sortedSubAPIs() {
+		child.addOpenAPIPaths(doc, idPath)
+	}
+}
+
+// sortedSubAPIs returns child APIs ordered by name so the generated document is consistent
+func (a *API[T]) sortedSubAPIs() []relatedAPI {
+	children := make([]relatedAPI, 0, len(a.subAPIs))
+	for _, child := range a.subAPIs {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name() < children[j].Name()
+	})
+	return children
+}
+
+// responses creates the successful response using the API's response codes and a default error response
+func (a *API[T]) responses(method string, schema, errSchema *OpenAPISchema) map[string]*OpenAPIResponse {
+	code := a.responseCodes[method]
+
+	success := &OpenAPIResponse{Description: http.StatusText(code)}
+	if schema != nil && code != http.StatusNoContent {
+		success.Content = map[string]*OpenAPIMediaType{jsonContentType: {Schema: schema}}
+	}
+
+	return map[string]*OpenAPIResponse{
+		strconv.Itoa(code): success,
+		"default": {
+			Description: "Error response",
+			Content:     map[string]*OpenAPIMediaType{jsonContentType: {Schema: errSchema}},
+		},
+	}
+}
+
+// addOperation adds the operation to the path if the handler is not nil
+func (a *API[T]) addOperation(doc *OpenAPIDocument, p, method string, handler http.HandlerFunc, op *OpenAPIOperation) {
+	if handler == nil {
+		return
+	}
+
+	pathItem, ok := doc.Paths[p]
+	if !ok {
+		pathItem = &OpenAPIPathItem{Parameters: pathParameters(p)}
+		doc.Paths[p] = pathItem
+	}
+
+	if op.Tags == nil {
+		op.Tags = []string{a.name}
+	}
+
+	switch method {
+	case http.MethodGet:
+		pathItem.Get = op
+	case http.MethodPut:
+		pathItem.Put = op
+	case http.MethodPost:
+		pathItem.Post = op
+	case http.MethodDelete:
+		pathItem.Delete = op
+	case http.MethodHead:
+		pathItem.Head = op
+	case http.MethodPatch:
+		pathItem.Patch = op
+	}
+}
+
+// addCustomRouteOperations adds an operation for each method in a custom route using the RouteDocs if they exist
+func (a *API[T]) addCustomRouteOperations(doc *OpenAPIDocument, cr chi.Route, prefix string, docs map[string]RouteDocs) {
+	p := joinOpenAPIPath(prefix, cr.Pattern)
+
+	methods := make([]string, 0, len(cr.Handlers))
+	for method := range cr.Handlers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	for _, method := range methods {
+		routeDocs := docs[routeDocsKey(method, cr.Pattern)]
+		op := a.customOperation(doc, "", routeDocs)
+		a.addOperation(doc, p, method, cr.Handlers[method].ServeHTTP, op)
+	}
+}
+
+// customOperation creates an operation from RouteDocs by reflecting the schemas from the example values
+func (a *API[T]) customOperation(doc *OpenAPIDocument, operationID string, docs RouteDocs) *OpenAPIOperation {
+	gen := &schemaGenerator{&doc.Components}
+
+	op := &OpenAPIOperation{
+		Summary:     docs.Summary,
+		Description: docs.Description,
+		Responses:   map[string]*OpenAPIResponse{},
+	}
+	if operationID != "" {
+		op.OperationID = strings.ToLower(operationID[:1]) + operationID[1:] + a.name
+	}
+
+	if docs.Request != nil {
+		op.RequestBody = jsonRequestBody(gen.schema(reflect.TypeOf(docs.Request)))
+	}
+
+	code := docs.ResponseCode
+	if code == 0 {
+		code = http.StatusOK
+	}
+
+	response := &OpenAPIResponse{Description: http.StatusText(code)}
+	if docs.Response != nil {
+		response.Content = map[string]*OpenAPIMediaType{
+			jsonContentType: {Schema: gen.schema(reflect.TypeOf(docs.Response))},
+		}
+	}
+	op.Responses[strconv.Itoa(code)] = response
+
+	return op
+}
+
+func jsonRequestBody(schema *OpenAPISchema) *OpenAPIRequestBody {
+	return &OpenAPIRequestBody{
+		Required: true,
+		Content:  map[string]*OpenAPIMediaType{jsonContentType: {Schema: schema}},
+	}
+}
+
+// patchRequestBody creates the PATCH request body with a media type for each content type from acceptPatch. It
+// returns nil if PATCH isn't supported
+func (a *API[T]) patchRequestBody(gen *schemaGenerator, resourceSchema *OpenAPISchema) *OpenAPIRequestBody {
+	acceptPatch := a.acceptPatch()
+	if acceptPatch == "" {
+		return nil
+	}
+
+	content := map[string]*OpenAPIMediaType{}
+	for _, contentType := range strings.Split(acceptPatch, ", ") {
+		schema := resourceSchema
+		if contentType == jsonPatchContentType {
+			// JSONPatchOperation has a custom MarshalJSON, so its properties are used directly
+			operation := gen.structProperties(reflect.TypeOf(JSONPatchOperation{}))
+			operation.Required = []string{"op", "path"}
+			schema = &OpenAPISchema{Type: "array", Items: operation}
+		}
+		content[contentType] = &OpenAPIMediaType{Schema: schema}
+	}
+
+	return &OpenAPIRequestBody{Required: true, Content: content}
+}
+
+var pathParamRegexp = regexp.MustCompile(`{([^}:]+)(:[^}]+)?}`)
+
+// pathParameters creates required path parameters for each URL parameter in the path
+func pathParameters(p string) []*OpenAPIParameter {
+	var params []*OpenAPIParameter
+	for _, match := range pathParamRegexp.FindAllStringSubmatch(p, -1) {
+		params = append(params, &OpenAPIParameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &OpenAPISchema{Type: "string"},
+		})
+	}
+	return params
+}
+
+// joinOpenAPIPath joins paths and removes regular expressions from URL parameters since OpenAPI doesn't allow them
+func joinOpenAPIPath(prefix, p string) string {
+	joined := path.Join("/", prefix, p)
+	return pathParamRegexp.ReplaceAllString(joined, "{$1}")
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemaGenerator creates schemas from Go types using JSON tags. Named structs are added to the components
+// so they can be referenced and reused
+type schemaGenerator struct {
+	components *OpenAPIComponents
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) *OpenAPISchema {
+	switch {
+	case t == timeType:
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &OpenAPISchema{Type: "string"}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return &OpenAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schema(t.Elem())
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &OpenAPISchema{Type: "number"}
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &OpenAPISchema{Type: "string", Format: "byte"}
+		}
+		return &OpenAPISchema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.structSchema(t)
+	default:
+		return &OpenAPISchema{}
+	}
+}
+
+var schemaNameReplacer = strings.NewReplacer("[", "_", "]", "", "*", "", "/", "_", ".", "_")
+
+// structSchema adds named structs to the components and returns a reference. Anonymous structs are inlined
+func (g *schemaGenerator) structSchema(t reflect.Type) *OpenAPISchema {
+	if t.Name() == "" {
+		return g.structProperties(t)
+	}
+
+	name, exists := g.schemaName(t)
+	ref := &OpenAPISchema{Ref: "#/components/schemas/" + name}
+
+	if exists {
+		return ref
+	}
+
+	// Add a placeholder first so recursive types reference the schema instead of looping
+	g.components.Schemas[name] = &OpenAPISchema{}
+	g.components.Schemas[name] = g.structProperties(t)
+
+	return ref
+}
+
+// schemaName returns the component name for a named type and whether it is already in the components. The type name
+// is used unless another type already has it, then it is qualified with the package name or the full package path
+func (g *schemaGenerator) schemaName(t reflect.Type) (string, bool) {
+	if g.components.schemaTypes == nil {
+		g.components.schemaTypes = map[string]reflect.Type{}
+	}
+
+	candidates := []string{
+		t.Name(),
+		path.Base(t.PkgPath()) + "." + t.Name(),
+		t.PkgPath() + "." + t.Name(),
+	}
+
+	var name string
+	for _, candidate := range candidates {
+		name = schemaNameReplacer.Replace(candidate)
+
+		existing, ok := g.components.schemaTypes[name]
+		if !ok {
+			break
+		}
+		if existing == t {
+			return name, true
+		}
+	}
+
+	g.components.schemaTypes[name] = t
+	return name, false
+}
+
+func (g *schemaGenerator) structProperties(t reflect.Type) *OpenAPISchema {
+	result := &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{}}
+	g.addStructFields(result, t)
+	return result
+}
+
+// addStructFields adds properties for each field, following the same rules as encoding/json. Fields of embedded
+// structs without a JSON name are added to the parent
+func (g *schemaGenerator) addStructFields(result *OpenAPISchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		fieldType := f.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		if f.Anonymous && name == "" && fieldType.Kind() == reflect.Struct && fieldType != timeType &&
+			!fieldType.Implements(textMarshalerType) && !reflect.PointerTo(fieldType).Implements(textMarshalerType) {
+			g.addStructFields(result, fieldType)
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		result.Properties[name] = g.schema(f.Type)
+	}
+}
@@ -0,0 +1,218 @@
+package babyapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/calvinmclean/babyapi"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/require"
+)
+
+type Publisher struct {
+	babyapi.DefaultResource
+	Name    string   `json:"name"`
+	Founded int      `json:"founded,omitempty"`
+	Tags    []string `json:"tags"`
+	Secret  string   `json:"-"`
+}
+
+type Magazine struct {
+	babyapi.DefaultResource
+	Title  string  `json:"title"`
+	Issues *int    `json:"issues"`
+	Price  float64 `json:"price"`
+}
+
+type restockRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+// URL has the same name as url.URL to test schema names
+type URL struct {
+	Link string `json:"link"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	publishers := babyapi.NewAPI[*Publisher]("Publishers", "/publishers", func() *Publisher { return &Publisher{} }).EnableCount()
+	magazines := babyapi.NewAPI[*Magazine]("Magazines", "/magazines", func() *Magazine { return &Magazine{} }).EnableMergePatch().EnableJSONPatch()
+	magazines.Delete = nil
+	magazines.SetCustomResponseCode(http.MethodPut, http.StatusAccepted)
+	magazines.AddCustomIDRoute(chi.Route{
+		Pattern: "/restock",
+		Handlers: map[string]http.Handler{
+			http.MethodPost: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				render.NoContent(w, r)
+			}),
+		},
+	})
+	magazines.SetCustomIDRouteDocs(http.MethodPost, "/restock", babyapi.RouteDocs{
+		Summary:      "Restock a magazine",
+		Request:      restockRequest{},
+		ResponseCode: http.StatusNoContent,
+	})
+
+	publishers.AddNestedAPI(magazines)
+
+	doc := publishers.OpenAPI()
+
+	t.Run("Info", func(t *testing.T) {
+		require.Equal(t, "3.1.0", doc.OpenAPI)
+		require.Equal(t, "Publishers", doc.Info.Title)
+	})
+
+	t.Run("Paths", func(t *testing.T) {
+		paths := []string{}
+		for p := range doc.Paths {
+			paths = append(paths, p)
+		}
+		require.ElementsMatch(t, []string{
+			"/publishers",
+			"/publishers/_count",
+			"/publishers/{PublishersID}",
+			"/publishers/{PublishersID}/magazines",
+			"/publishers/{PublishersID}/magazines/{MagazinesID}",
+			"/publishers/{PublishersID}/magazines/{MagazinesID}/restock",
+		}, paths)
+	})
+
+	t.Run("NestedPathParameters", func(t *testing.T) {
+		params := doc.Paths["/publishers/{PublishersID}/magazines/{MagazinesID}"].Parameters
+		require.Len(t, params, 2)
+		require.Equal(t, "PublishersID", params[0].Name)
+		require.Equal(t, "MagazinesID", params[1].Name)
+		require.Equal(t, "path", params[1].In)
+		require.True(t, params[1].Required)
+	})
+
+	t.Run("EnabledMethods", func(t *testing.T) {
+		magazine := doc.Paths["/publishers/{PublishersID}/magazines/{MagazinesID}"]
+		require.NotNil(t, magazine.Get)
+		require.NotNil(t, magazine.Put)
+		require.NotNil(t, magazine.Patch)
+		require.Nil(t, magazine.Delete)
+
+		publisher := doc.Paths["/publishers/{PublishersID}"]
+		require.NotNil(t, publisher.Delete)
+
+		// PATCH responds with 405 when the resource isn't a Patcher and no patch formats are enabled
+		require.Nil(t, publisher.Patch)
+	})
+
+	t.Run("PatchContentTypes", func(t *testing.T) {
+		body := doc.Paths["/publishers/{PublishersID}/magazines/{MagazinesID}"].Patch.RequestBody
+		require.Len(t, body.Content, 2)
+		require.Equal(t, "#/components/schemas/Magazine", body.Content["application/merge-patch+json"].Schema.Ref)
+
+		jsonPatch := body.Content["application/json-patch+json"].Schema
+		require.Equal(t, "array", jsonPatch.Type)
+		require.Equal(t, "object", jsonPatch.Items.Type)
+		require.Len(t, jsonPatch.Items.Properties, 4)
+		require.Equal(t, "string", jsonPatch.Items.Properties["op"].Type)
+		require.Equal(t, []string{"op", "path"}, jsonPatch.Items.Required)
+	})
+
+	t.Run("ResponseCodes", func(t *testing.T) {
+		require.Contains(t, doc.Paths["/publishers"].Post.Responses, "201")
+		require.Contains(t, doc.Paths["/publishers/{PublishersID}"].Delete.Responses, "204")
+		require.Contains(t, doc.Paths["/publishers/{PublishersID}/magazines/{MagazinesID}"].Put.Responses, "202")
+	})
+
+	t.Run("Schemas", func(t *testing.T) {
+		publisher := doc.Components.Schemas["Publisher"]
+		require.NotNil(t, publisher)
+		require.Equal(t, "object", publisher.Type)
+		require.Len(t, publisher.Properties, 4)
+		require.Equal(t, "string", publisher.Properties["id"].Type)
+		require.Equal(t, "string", publisher.Properties["name"].Type)
+		require.Equal(t, "integer", publisher.Properties["founded"].Type)
+		require.Equal(t, "array", publisher.Properties["tags"].Type)
+		require.Equal(t, "string", publisher.Properties["tags"].Items.Type)
+
+		magazine := doc.Components.Schemas["Magazine"]
+		require.Equal(t, "integer", magazine.Properties["issues"].Type)
+		require.Equal(t, "number", magazine.Properties["price"].Type)
+
+		body := doc.Paths["/publishers"].Post.RequestBody
+		require.Equal(t, "#/components/schemas/Publisher", body.Content["application/json"].Schema.Ref)
+	})
+
+	t.Run("CustomRouteMetadata", func(t *testing.T) {
+		restock := doc.Paths["/publishers/{PublishersID}/magazines/{MagazinesID}/restock"].Post
+		require.Equal(t, "Restock a magazine", restock.Summary)
+		require.Equal(t, "#/components/schemas/restockRequest", restock.RequestBody.Content["application/json"].Schema.Ref)
+		require.Contains(t, restock.Responses, "204")
+	})
+
+	t.Run("EndpointIsOptIn", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		publishers.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", http.NoBody))
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		publishers.EnableOpenAPI()
+
+		w := httptest.NewRecorder()
+		publishers.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", http.NoBody))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var served babyapi.OpenAPIDocument
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &served))
+		require.Equal(t, len(doc.Paths), len(served.Paths))
+	})
+
+	t.Run("EndpointUsesMiddleware", func(t *testing.T) {
+		root := babyapi.NewRootAPI("root", "/").EnableOpenAPI()
+		root.SetAuthenticators(&babyapi.APIKeyAuthenticator{Keys: map[string]string{"docs": "docs-key"}})
+		root.AddNestedAPI(babyapi.NewAPI[*Publisher]("Publishers", "/publishers", func() *Publisher { return &Publisher{} }))
+
+		w := httptest.NewRecorder()
+		root.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", http.NoBody))
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("CLI", func(t *testing.T) {
+		var out bytes.Buffer
+		err := publishers.RunWithArgs(&out, []string{"openapi"}, "", "", false, nil, "")
+		require.NoError(t, err)
+
+		var printed babyapi.OpenAPIDocument
+		require.NoError(t, json.Unmarshal(out.Bytes(), &printed))
+		require.Equal(t, "Publishers", printed.Info.Title)
+	})
+
+	t.Run("SchemaNamesFromDifferentPackages", func(t *testing.T) {
+		links := babyapi.NewAPI[*Publisher]("Publishers", "/publishers", func() *Publisher { return &Publisher{} })
+		links.AddCustomRoute(chi.Route{
+			Pattern:  "/links",
+			Handlers: map[string]http.Handler{http.MethodPost: http.NotFoundHandler()},
+		})
+		links.SetCustomRouteDocs(http.MethodPost, "/links", babyapi.RouteDocs{
+			Request:  URL{},
+			Response: url.URL{},
+		})
+
+		linksDoc := links.OpenAPI()
+		op := linksDoc.Paths["/publishers/links"].Post
+		require.Equal(t, "#/components/schemas/URL", op.RequestBody.Content["application/json"].Schema.Ref)
+		require.Equal(t, "#/components/schemas/url_URL", op.Responses["200"].Content["application/json"].Schema.Ref)
+		require.Contains(t, linksDoc.Components.Schemas["URL"].Properties, "link")
+		require.Contains(t, linksDoc.Components.Schemas["url_URL"].Properties, "Host")
+	})
+
+	t.Run("RootAPI", func(t *testing.T) {
+		root := babyapi.NewRootAPI("root", "/")
+		root.AddNestedAPI(babyapi.NewAPI[*Publisher]("Publishers", "/publishers", func() *Publisher { return &Publisher{} }))
+
+		rootDoc := root.OpenAPI()
+		require.Contains(t, rootDoc.Paths, "/publishers")
+		require.Contains(t, rootDoc.Paths, "/publishers/{PublishersID}")
+		require.NotContains(t, rootDoc.Paths, "/")
+	})
+}
@@ -0,0 +1,143 @@
+package babyapi
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// allowCandidates are the methods that are checked when creating the Allow header
+var allowCandidates = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// allowedMethods finds all methods that have a route matching the request's path. It walks the top-level router from
+// the request context so it works for all routes, including custom and nested routes. Like chi, routes with static
+// segments are preferred over URL parameters, so only the methods of the most specific matching routes are allowed
+func allowedMethods(r *http.Request) []string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return nil
+	}
+
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+
+	var best []int
+	methods := map[string]bool{}
+	_ = chi.Walk(rctx.Routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		segments, ok := matchRoute(route, path)
+		if !ok {
+			return nil
+		}
+
+		switch cmp := slices.Compare(segments, best); {
+		case best == nil || cmp < 0:
+			best = segments
+			methods = map[string]bool{method: true}
+		case cmp == 0:
+			methods[method] = true
+		}
+		return nil
+	})
+
+	var allowed []string
+	for _, method := range allowCandidates {
+		if methods[method] || methods["*"] {
+			allowed = append(allowed, method)
+		}
+	}
+
+	return allowed
+}
+
+// Kinds of route segments, in order of priority
+const (
+	staticSegment = iota
+	paramSegment
+	wildcardSegment
+)
+
+// matchRoute checks if the path matches a route pattern from chi and returns the kind of each segment used to match
+// it. URL parameters match any non-empty path segment and a wildcard matches the rest of the path
+func matchRoute(pattern, path string) ([]int, bool) {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+
+	segments := make([]int, 0, len(patternParts))
+	for i, part := range patternParts {
+		if part == "*" {
+			return append(segments, wildcardSegment), true
+		}
+		if i >= len(pathParts) {
+			return nil, false
+		}
+
+		isParam := strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}")
+		switch {
+		case isParam && pathParts[i] != "":
+			segments = append(segments, paramSegment)
+		case part == pathParts[i]:
+			segments = append(segments, staticSegment)
+		default:
+			return nil, false
+		}
+	}
+
+	return segments, len(patternParts) == len(pathParts)
+}
+
+// acceptPatch returns the value for the Accept-Patch header, which lists the content types that can be used for
+// PATCH requests. Resources that implement Patcher use their own JSON format and others use JSON Merge Patch and JSON
+// Patch if they are enabled. It is empty if the resource can't be patched
+func (a *API[T]) acceptPatch() string {
+	if a.isPatcher() {
+		return "application/json"
+	}
+
+	var contentTypes []string
+	if a.usesMergePatch() {
+		contentTypes = append(contentTypes, mergePatchContentType)
+	}
+	if a.usesJSONPatch() {
+		contentTypes = append(contentTypes, jsonPatchContentType)
+	}
+	return strings.Join(contentTypes, ", ")
+}
+
+// setAllowHeaders sets the Allow header and the Accept-Patch header if PATCH is allowed
+func (a *API[T]) setAllowHeaders(w http.ResponseWriter, r *http.Request) {
+	allowed := allowedMethods(r)
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+
+	for _, method := range allowed {
+		if method != http.MethodPatch {
+			continue
+		}
+		if acceptPatch := a.acceptPatch(); acceptPatch != "" {
+			w.Header().Set("Accept-Patch", acceptPatch)
+		}
+	}
+}
+
+// defaultOptions responds to OPTIONS requests with the allowed methods for the path
+func (a *API[T]) defaultOptions(w http.ResponseWriter, r *http.Request) {
+	a.setAllowHeaders(w, r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// methodNotAllowed responds with an ErrResponse and sets the Allow header so clients know which methods to use
+func (a *API[T]) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	a.setAllowHeaders(w, r)
+	_ = Render(w, r, ErrMethodNotAllowedResponse)
+}
@@ -0,0 +1,119 @@
+package babyapi_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsHeadAndAllow(t *testing.T) {
+	albumAPI := babyapi.NewAPI[*Album]("Albums", "/albums", func() *Album { return &Album{} })
+	songAPI := babyapi.NewAPI[*Song]("Songs", "/songs", func() *Song { return &Song{} })
+	albumAPI.AddNestedAPI(songAPI)
+
+	songAPI.Put = nil
+	songAPI.EnableJSONPatch()
+	albumAPI.EnableCount()
+
+	albumAPI.AddCustomRoute(chi.Route{
+		Pattern: "/teapot",
+		Handlers: map[string]http.Handler{
+			http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+				_, _ = w.Write([]byte("teapot"))
+			}),
+		},
+	})
+
+	album := &Album{DefaultResource: babyapi.NewDefaultResource(), Title: "Album"}
+	require.NoError(t, albumAPI.Storage.Set(album))
+	song := &Song{DefaultResource: babyapi.NewDefaultResource(), Title: "Song"}
+	require.NoError(t, songAPI.Storage.Set(song))
+
+	tests := []struct {
+		name                string
+		method              string
+		path                string
+		expectedStatus      int
+		expectedAllow       string
+		expectedAcceptPatch string
+		expectedBody        string
+	}{
+		{
+			"OptionsCollection",
+			http.MethodOptions, "/albums",
+			http.StatusNoContent, "GET, HEAD, POST, OPTIONS", "", "",
+		},
+		{
+			"OptionsID",
+			http.MethodOptions, "/albums/" + album.GetID(),
+			http.StatusNoContent, "GET, HEAD, PUT, PATCH, DELETE, OPTIONS", "application/json", "",
+		},
+		{
+			"OptionsNestedIDWithoutPatcherOrPut",
+			http.MethodOptions, "/albums/" + album.GetID() + "/songs/" + song.GetID(),
+			http.StatusNoContent, "GET, HEAD, PATCH, DELETE, OPTIONS", "application/json-patch+json", "",
+		},
+		{
+			"OptionsCustomRoute",
+			http.MethodOptions, "/albums/teapot",
+			// The static route is preferred over the ID route
+			http.StatusNoContent, "GET, HEAD, OPTIONS", "", "",
+		},
+		{
+			"OptionsCount",
+			http.MethodOptions, "/albums/_count",
+			http.StatusNoContent, "GET, OPTIONS", "", "",
+		},
+		{
+			"MethodNotAllowedCollection",
+			http.MethodDelete, "/albums",
+			http.StatusMethodNotAllowed, "GET, HEAD, POST, OPTIONS", "", `{"status":"Method not allowed."}`,
+		},
+		{
+			"MethodNotAllowedNestedID",
+			http.MethodPut, "/albums/" + album.GetID() + "/songs/" + song.GetID(),
+			http.StatusMethodNotAllowed, "GET, HEAD, PATCH, DELETE, OPTIONS", "application/json-patch+json", `{"status":"Method not allowed."}`,
+		},
+		{
+			"HeadID",
+			http.MethodHead, "/albums/" + album.GetID(),
+			http.StatusOK, "", "", "",
+		},
+		{
+			"HeadIDNotFound",
+			http.MethodHead, "/albums/DoesNotExist",
+			http.StatusNotFound, "", "", "",
+		},
+		{
+			"HeadCustomRoute",
+			http.MethodHead, "/albums/teapot",
+			http.StatusTeapot, "", "", "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, http.NoBody)
+			w := babytest.TestRequest[*Album](t, albumAPI, r)
+
+			require.Equal(t, tt.expectedStatus, w.Result().StatusCode)
+			require.Equal(t, tt.expectedAllow, w.Result().Header.Get("Allow"))
+			require.Equal(t, tt.expectedAcceptPatch, w.Result().Header.Get("Accept-Patch"))
+			require.Equal(t, tt.expectedBody, strings.TrimSpace(w.Body.String()))
+		})
+	}
+
+	t.Run("HeadHasSameHeadersAsGet", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodHead, "/albums/"+album.GetID(), http.NoBody)
+		w := babytest.TestRequest[*Album](t, albumAPI, r)
+
+		require.Equal(t, "application/json", w.Result().Header.Get("Content-Type"))
+	})
+}
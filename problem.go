@@ -0,0 +1,159 @@
+package babyapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	problemJSONContentType = "application/problem+json"
+	defaultProblemType     = "about:blank"
+)
+
+// ProblemDetails is an RFC 7807 error response. Extensions contains any additional members, like validation errors
+type ProblemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	Extensions map[string]any `json:"-"`
+}
+
+// MarshalJSON writes the extensions as top-level members alongside the standard members
+func (p ProblemDetails) MarshalJSON() ([]byte, error) {
+	type problemDetails ProblemDetails
+	data, err := json.Marshal(problemDetails(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+
+	result := map[string]any{}
+	for key, value := range p.Extensions {
+		result[key] = value
+	}
+
+	var members map[string]any
+	err = json.Unmarshal(data, &members)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range members {
+		result[key] = value
+	}
+
+	return json.Marshal(result)
+}
+
+// UnmarshalJSON reads the standard members and stores any other members in Extensions
+func (p *ProblemDetails) UnmarshalJSON(data []byte) error {
+	type problemDetails ProblemDetails
+	err := json.Unmarshal(data, (*problemDetails)(p))
+	if err != nil {
+		return err
+	}
+
+	var members map[string]json.RawMessage
+	err = json.Unmarshal(data, &members)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(members, key)
+	}
+	if len(members) == 0 {
+		p.Extensions = nil
+		return nil
+	}
+
+	p.Extensions = make(map[string]any, len(members))
+	for key, value := range members {
+		var v any
+		err = json.Unmarshal(value, &v)
+		if err != nil {
+			return err
+		}
+		p.Extensions[key] = v
+	}
+
+	return nil
+}
+
+// ErrResponse converts the ProblemDetails back into an ErrResponse. The "code" and "validationErrors" extensions are
+// used for AppCode and ValidationErrors
+func (p ProblemDetails) ErrResponse() *ErrResponse {
+	errResp := &ErrResponse{
+		HTTPStatusCode: p.Status,
+		StatusText:     p.Title,
+		ErrorText:      p.Detail,
+		RequestID:      p.Instance,
+	}
+
+	if code, ok := p.Extensions["code"].(float64); ok {
+		errResp.AppCode = int64(code)
+	}
+
+	if fieldErrors, ok := p.Extensions["validationErrors"]; ok {
+		data, err := json.Marshal(fieldErrors)
+		if err == nil {
+			_ = json.Unmarshal(data, &errResp.ValidationErrors)
+		}
+	}
+
+	return errResp
+}
+
+// ProblemDetails converts the ErrResponse to an RFC 7807 ProblemDetails. The instance is the request ID, if the
+// request has one
+func (e *ErrResponse) ProblemDetails(r *http.Request) ProblemDetails {
+	problem := ProblemDetails{
+		Type:     defaultProblemType,
+		Title:    e.StatusText,
+		Status:   e.HTTPStatusCode,
+		Detail:   e.ErrorText,
+		Instance: e.RequestID,
+	}
+	if problem.Instance == "" {
+		problem.Instance = middleware.GetReqID(r.Context())
+	}
+
+	if e.AppCode != 0 || len(e.ValidationErrors) > 0 {
+		problem.Extensions = map[string]any{}
+	}
+	if e.AppCode != 0 {
+		problem.Extensions["code"] = e.AppCode
+	}
+	if len(e.ValidationErrors) > 0 {
+		problem.Extensions["validationErrors"] = e.ValidationErrors
+	}
+
+	return problem
+}
+
+// EnableProblemDetails renders all errors from this API and its child APIs as RFC 7807 application/problem+json
+// instead of the default ErrResponse JSON
+func (a *API[T]) EnableProblemDetails() *API[T] {
+	a.problemDetails = true
+	return a
+}
+
+func (a *API[T]) useProblemDetails() bool {
+	return a.problemDetails || (a.parent != nil && a.parent.useProblemDetails())
+}
+
+func respondWithProblemDetails(w http.ResponseWriter, r *http.Request, errResp *ErrResponse) {
+	data, err := json.Marshal(errResp.ProblemDetails(r))
+	if err != nil {
+		GetLoggerFromContext(r.Context()).Error("error encoding problem details", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", problemJSONContentType)
+	w.WriteHeader(errResp.HTTPStatusCode)
+	_, _ = w.Write(append(data, '\n'))
+}
@@ -0,0 +1,118 @@
+package babyapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblemDetails(t *testing.T) {
+	api := babyapi.NewAPI[*Member]("Members", "/members", func() *Member { return &Member{} }).EnableProblemDetails()
+	api.AddNestedAPI(babyapi.NewAPI[*Song]("Songs", "/songs", func() *Song { return &Song{} }))
+
+	member := &Member{DefaultResource: babyapi.NewDefaultResource(), Name: "Alice", Email: "alice@example.com"}
+	require.NoError(t, api.Storage.Set(member))
+
+	t.Run("NotFound", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/members/DoesNotExist", http.NoBody)
+		r.Header.Set("X-Request-Id", "request-1")
+		w := babytest.TestRequest[*Member](t, api, r)
+
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		require.JSONEq(t, `{
+			"type": "about:blank",
+			"title": "Resource not found.",
+			"status": 404,
+			"instance": "request-1"
+		}`, w.Body.String())
+	})
+
+	t.Run("ValidationErrorsAreExtensions", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/members", strings.NewReader(`{"name": "Bob", "email": "bob", "plan": "free"}`))
+		r.Header.Set("Content-Type", "application/json")
+		w := babytest.TestRequest[*Member](t, api, r)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		var problem babyapi.ProblemDetails
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		require.Equal(t, "Invalid request.", problem.Title)
+		require.Equal(t, http.StatusBadRequest, problem.Status)
+		require.NotEmpty(t, problem.Instance)
+		require.Len(t, problem.Extensions["validationErrors"], 1)
+	})
+
+	t.Run("ChildAPIsInheritProblemDetails", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/members/"+member.GetID()+"/songs/DoesNotExist", http.NoBody)
+		w := babytest.TestRequest[*Member](t, api, r)
+
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	})
+
+	t.Run("ClientDecodesErrResponse", func(t *testing.T) {
+		client, stop := babytest.NewTestClient[*Member](t, api)
+		defer stop()
+
+		_, err := client.Post(context.Background(), &Member{Name: "Bob", Email: "bob", Plan: "free"})
+		require.Error(t, err)
+
+		var errResp *babyapi.ErrResponse
+		require.True(t, errors.As(err, &errResp))
+		require.Equal(t, http.StatusBadRequest, errResp.HTTPStatusCode)
+		require.Equal(t, "Invalid request.", errResp.StatusText)
+		require.Equal(t, []babyapi.FieldError{{Field: "email", Rule: "email", Message: "must be a valid email address"}}, errResp.ValidationErrors)
+	})
+
+	t.Run("DefaultFormatIsUnchanged", func(t *testing.T) {
+		defaultAPI := babyapi.NewAPI[*Member]("Members", "/members", func() *Member { return &Member{} })
+
+		r := httptest.NewRequest(http.MethodGet, "/members/DoesNotExist", http.NoBody)
+		w := babytest.TestRequest[*Member](t, defaultAPI, r)
+
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		require.Equal(t, `{"status":"Resource not found."}`+"\n", w.Body.String())
+	})
+}
+
+func TestProblemDetailsFromMiddleware(t *testing.T) {
+	api := babyapi.NewAPI[*Member]("Members", "/members", func() *Member { return &Member{} }).EnableProblemDetails()
+	api.SetAuthenticators(&babyapi.APIKeyAuthenticator{Keys: map[string]string{"alice": "alice-key"}})
+
+	songAPI := babyapi.NewAPI[*Song]("Songs", "/songs", func() *Song { return &Song{} })
+	songAPI.AddMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = babyapi.Render(w, r, babyapi.ErrForbidden)
+		})
+	})
+	api.AddNestedAPI(songAPI)
+
+	member := &Member{DefaultResource: babyapi.NewDefaultResource(), Name: "Alice", Email: "alice@example.com"}
+	require.NoError(t, api.Storage.Set(member))
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/members", http.NoBody)
+		w := babytest.TestRequest[*Member](t, api, r)
+
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		require.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	})
+
+	t.Run("ChildMiddleware", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/members/"+member.GetID()+"/songs", http.NoBody)
+		r.Header.Set(babyapi.APIKeyHeader, "alice-key")
+		w := babytest.TestRequest[*Member](t, api, r)
+
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	})
+}
@@ -0,0 +1,229 @@
+package babyapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// PubSub delivers messages published to a topic to every subscriber of the topic. Implementations backed by an
+// external service, like storage.NewRedisPubSub, allow BroadcastChannels in different instances of an application to
+// share values
+type PubSub interface {
+	// Publish sends the message to all subscribers of the topic
+	Publish(ctx context.Context, topic string, message []byte) error
+	// Subscribe calls the handler with each message published to the topic until the context is done. Messages are
+	// handled one at a time
+	Subscribe(ctx context.Context, topic string, handler func(message []byte)) error
+}
+
+// MemoryPubSub is an in-process PubSub. Publish calls each subscriber's handler before returning. Sharing one
+// MemoryPubSub between multiple APIs in a single process behaves like running multiple instances with a shared
+// external PubSub
+type MemoryPubSub struct {
+	subscribers map[string][]*memorySubscriber
+	lock        sync.RWMutex
+}
+
+type memorySubscriber struct {
+	handler func([]byte)
+	lock    sync.Mutex
+}
+
+// NewMemoryPubSub creates an in-process PubSub
+func NewMemoryPubSub() *MemoryPubSub {
+	return &MemoryPubSub{subscribers: map[string][]*memorySubscriber{}}
+}
+
+// Publish calls the handler of every subscriber of the topic with a copy of the message
+func (ps *MemoryPubSub) Publish(_ context.Context, topic string, message []byte) error {
+	ps.lock.RLock()
+	subscribers := slices.Clone(ps.subscribers[topic])
+	ps.lock.RUnlock()
+
+	for _, subscriber := range subscribers {
+		subscriber.lock.Lock()
+		subscriber.handler(slices.Clone(message))
+		subscriber.lock.Unlock()
+	}
+
+	return nil
+}
+
+// Subscribe adds the handler for the topic and removes it when the context is done
+func (ps *MemoryPubSub) Subscribe(ctx context.Context, topic string, handler func([]byte)) error {
+	subscriber := &memorySubscriber{handler: handler}
+
+	ps.lock.Lock()
+	ps.subscribers[topic] = append(ps.subscribers[topic], subscriber)
+	ps.lock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		ps.lock.Lock()
+		defer ps.lock.Unlock()
+		ps.subscribers[topic] = slices.DeleteFunc(ps.subscribers[topic], func(s *memorySubscriber) bool {
+			return s == subscriber
+		})
+	}()
+
+	return nil
+}
+
+// SetPubSub shares change stream events through the PubSub so listeners connected to any instance of the API receive
+// changes made in every instance. It also applies to handlers added later with AddServerSentEventHandler. The API
+// subscribes to one topic named using the API's name, so each instance of the API must use the same name. An error is
+// returned if the subscription fails. Calling it again replaces the previous subscription, and the subscription is
+// cancelled when the API stops
+func (a *API[T]) SetPubSub(ps PubSub) error {
+	shared := a.pubSub
+	if shared == nil {
+		shared = &apiPubSub{
+			topic:       fmt.Sprintf("babyapi:%s", a.name),
+			subscribers: map[string]*apiPubSubSubscriber{},
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := ps.Subscribe(ctx, shared.topic, shared.handle)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("error subscribing to topic %q: %w", shared.topic, err)
+	}
+
+	shared.setPubSub(ps, cancel)
+
+	if a.pubSub == nil {
+		a.pubSub = shared
+		if a.changes != nil {
+			connectPubSub(a.changes, shared, changeStreamPath)
+		}
+	}
+	return nil
+}
+
+// closePubSub cancels the PubSub subscriptions of this API and its nested APIs when the API stops
+func (a *API[T]) closePubSub() {
+	if a.pubSub != nil {
+		a.pubSub.close()
+	}
+
+	for _, child := range a.subAPIs {
+		child.closePubSub()
+	}
+}
+
+// apiPubSub shares an API's single PubSub subscription with each of its BroadcastChannels. Messages are published to
+// the API's topic along with the BroadcastChannel's path and only handled by the BroadcastChannel with the same path.
+// This allows adding BroadcastChannels after SetPubSub without creating subscriptions that could fail. The
+// BroadcastChannels keep using the same apiPubSub when SetPubSub is called again
+type apiPubSub struct {
+	pubSub      PubSub
+	topic       string
+	unsubscribe context.CancelFunc
+
+	subscribers map[string]*apiPubSubSubscriber
+	lock        sync.RWMutex
+}
+
+// errPubSubClosed is returned by Publish after the API stops, so BroadcastChannels only send values locally
+var errPubSubClosed = errors.New("pubsub is closed")
+
+type apiPubSubSubscriber struct {
+	handler func([]byte)
+}
+
+// apiPubSubMessage is published to the API's topic so the message can be handled by the BroadcastChannel for the path
+type apiPubSubMessage struct {
+	Path    string          `json:"path"`
+	Message json.RawMessage `json:"message"`
+}
+
+// setPubSub uses the new PubSub and subscription and cancels the previous subscription
+func (ps *apiPubSub) setPubSub(pubSub PubSub, unsubscribe context.CancelFunc) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if ps.unsubscribe != nil {
+		ps.unsubscribe()
+	}
+	ps.pubSub, ps.unsubscribe = pubSub, unsubscribe
+}
+
+// close cancels the subscription. Messages can't be published afterwards
+func (ps *apiPubSub) close() {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if ps.unsubscribe != nil {
+		ps.unsubscribe()
+	}
+	ps.pubSub, ps.unsubscribe = nil, nil
+}
+
+// Publish sends the message to the BroadcastChannels using the path in every instance of the API
+func (ps *apiPubSub) Publish(ctx context.Context, path string, message []byte) error {
+	ps.lock.RLock()
+	pubSub := ps.pubSub
+	ps.lock.RUnlock()
+
+	if pubSub == nil {
+		return errPubSubClosed
+	}
+
+	data, err := json.Marshal(apiPubSubMessage{path, message})
+	if err != nil {
+		return fmt.Errorf("error encoding message: %w", err)
+	}
+	return pubSub.Publish(ctx, ps.topic, data)
+}
+
+// Subscribe sets the handler for messages published with the path and removes it when the context is done. It uses the
+// API's existing subscription, so it doesn't return an error
+func (ps *apiPubSub) Subscribe(ctx context.Context, path string, handler func([]byte)) error {
+	subscriber := &apiPubSubSubscriber{handler}
+
+	ps.lock.Lock()
+	ps.subscribers[path] = subscriber
+	ps.lock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		ps.lock.Lock()
+		defer ps.lock.Unlock()
+		if ps.subscribers[path] == subscriber {
+			delete(ps.subscribers, path)
+		}
+	}()
+
+	return nil
+}
+
+func (ps *apiPubSub) handle(data []byte) {
+	var message apiPubSubMessage
+	err := json.Unmarshal(data, &message)
+	if err != nil {
+		slog.Default().Error("error decoding pubsub message", "error", err, "topic", ps.topic)
+		return
+	}
+
+	ps.lock.RLock()
+	subscriber, ok := ps.subscribers[message.Path]
+	ps.lock.RUnlock()
+
+	if ok {
+		subscriber.handler(message.Message)
+	}
+}
+
+// connectPubSub sends the BroadcastChannel's values through the API's PubSub. The error is ignored because apiPubSub
+// doesn't create a new subscription
+func connectPubSub[E any](bc *BroadcastChannel[E], ps *apiPubSub, path string) {
+	_ = bc.SetPubSub(ps, path)
+}
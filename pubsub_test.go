@@ -0,0 +1,205 @@
+package babyapi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPubSubInstances(t *testing.T) {
+	pubSub := babytest.NewPubSub()
+
+	newInstance := func() (*babyapi.API[*Memo], chan *babyapi.ServerSentEvent) {
+		api := babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} })
+		require.NoError(t, api.SetPubSub(pubSub))
+		api.EnableChangeStream()
+		return api, api.AddServerSentEventHandler("/notifications")
+	}
+
+	apiA, _ := newInstance()
+	// SetPubSub also works after EnableChangeStream
+	apiB := babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} }).EnableChangeStream()
+	require.NoError(t, apiB.SetPubSub(pubSub))
+	notificationsB := apiB.AddServerSentEventHandler("/notifications")
+
+	clientA, stopA := babytest.NewTestClient[*Memo](t, apiA)
+	defer stopA()
+	clientB, stopB := babytest.NewTestClient[*Memo](t, apiB)
+	defer stopB()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("ChangeStream", func(t *testing.T) {
+		changesA, err := clientA.Listen(ctx, "/events")
+		require.NoError(t, err)
+		changesB, err := clientB.Listen(ctx, "/events")
+		require.NoError(t, err)
+
+		created, err := clientB.Post(ctx, &Memo{Text: "from B"})
+		require.NoError(t, err)
+
+		for _, changes := range []<-chan *babyapi.ListenEvent[*Memo]{changesA, changesB} {
+			event := babytest.RequireEvent(t, changes, time.Second)
+			require.Equal(t, babyapi.ChangeEventCreated, event.Event)
+			require.Equal(t, created.Data.GetID(), event.Data.GetID())
+			require.Equal(t, "from B", event.Data.Text)
+		}
+
+		require.Len(t, pubSub.Published("babyapi:Memos"), 1)
+	})
+
+	t.Run("ReplayFromAnotherInstance", func(t *testing.T) {
+		eventsA := listenForEvents(t, ctx, clientA.Address+"/memos/events", nil)
+
+		_, err := clientA.Post(ctx, &Memo{Text: "first"})
+		require.NoError(t, err)
+		first := nextEvent(t, eventsA)
+		require.Contains(t, first, `"text":"first"`)
+
+		_, err = clientA.Post(ctx, &Memo{Text: "second"})
+		require.NoError(t, err)
+		second := nextEvent(t, eventsA)
+
+		// IDs are created by the publishing instance, so instance B replays the events after instance A's ID
+		_, lastEventID, _ := strings.Cut(first, "id: ")
+		eventsB := listenForEvents(t, ctx, clientB.Address+"/memos/events", http.Header{"Last-Event-ID": {lastEventID}})
+		require.Equal(t, second, nextEvent(t, eventsB))
+	})
+
+	t.Run("ServerSentEvents", func(t *testing.T) {
+		notificationsA, err := clientA.Listen(ctx, "/notifications")
+		require.NoError(t, err)
+
+		notificationsB <- &babyapi.ServerSentEvent{Event: "notification", Data: `{"text": "hello"}`}
+
+		event := babytest.RequireEvent(t, notificationsA, time.Second)
+		require.Equal(t, "notification", event.Event)
+		require.Equal(t, "hello", event.Data.Text)
+	})
+
+	t.Run("PublishErrorOnlySendsLocally", func(t *testing.T) {
+		pubSub.SetPublishError(errors.New("unavailable"))
+		defer pubSub.SetPublishError(nil)
+
+		changesA, err := clientA.Listen(ctx, "/events")
+		require.NoError(t, err)
+		changesB, err := clientB.Listen(ctx, "/events")
+		require.NoError(t, err)
+
+		_, err = clientB.Post(ctx, &Memo{Text: "local"})
+		require.NoError(t, err)
+
+		event := babytest.RequireEvent(t, changesB, time.Second)
+		require.Equal(t, "local", event.Data.Text)
+		babytest.RequireNoEvent(t, changesA, 50*time.Millisecond)
+	})
+}
+
+// failingPubSub is a PubSub that can't subscribe
+type failingPubSub struct {
+	babyapi.PubSub
+}
+
+func (failingPubSub) Subscribe(context.Context, string, func([]byte)) error {
+	return errors.New("unavailable")
+}
+
+func TestSetPubSubError(t *testing.T) {
+	api := babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} }).EnableChangeStream()
+
+	err := api.SetPubSub(failingPubSub{babyapi.NewMemoryPubSub()})
+	require.EqualError(t, err, `error subscribing to topic "babyapi:Memos": unavailable`)
+
+	// The API still works without the PubSub
+	client, stop := babytest.NewTestClient[*Memo](t, api)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes, err := client.Listen(ctx, "/events")
+	require.NoError(t, err)
+
+	_, err = client.Post(ctx, &Memo{Text: "local"})
+	require.NoError(t, err)
+
+	event := babytest.RequireEvent(t, changes, time.Second)
+	require.Equal(t, "local", event.Data.Text)
+}
+
+func TestBroadcastChannelPubSub(t *testing.T) {
+	pubSub := babyapi.NewMemoryPubSub()
+
+	instanceA := &babyapi.BroadcastChannel[int]{}
+	require.NoError(t, instanceA.SetPubSub(pubSub, "numbers"))
+	instanceB := &babyapi.BroadcastChannel[int]{}
+	require.NoError(t, instanceB.SetPubSub(pubSub, "numbers"))
+
+	listenerA := instanceA.GetListener()
+	listenerB := instanceB.GetListener()
+
+	instanceA.SendToAll(1)
+	instanceB.SendToAll(2)
+
+	require.Equal(t, []int{1, 2}, readAll(listenerA))
+	require.Equal(t, []int{1, 2}, readAll(listenerB))
+
+	t.Run("Close", func(t *testing.T) {
+		instanceB.Close()
+
+		// Unsubscribing happens in the background after Close
+		require.Eventually(t, func() bool {
+			instanceA.SendToAll(3)
+			return len(readAll(listenerB)) == 0
+		}, time.Second, 10*time.Millisecond)
+
+		readAll(listenerA)
+		instanceA.SendToAll(4)
+		require.Equal(t, []int{4}, readAll(listenerA))
+		require.Empty(t, readAll(listenerB))
+
+		// Values are only sent locally after closing
+		instanceB.SendToAll(5)
+		require.Equal(t, []int{5}, readAll(listenerB))
+		require.Empty(t, readAll(listenerA))
+	})
+}
+
+func TestSetPubSubSubscription(t *testing.T) {
+	pubSub := babytest.NewPubSub()
+
+	api := babyapi.NewAPI[*Album]("Albums", "/albums", func() *Album { return &Album{} })
+	songAPI := babyapi.NewAPI[*Song]("Songs", "/songs", func() *Song { return &Song{} }).EnableChangeStream()
+	api.AddNestedAPI(songAPI)
+
+	require.NoError(t, api.SetPubSub(pubSub))
+	require.NoError(t, songAPI.SetPubSub(pubSub))
+
+	t.Run("SetAgainReplacesSubscription", func(t *testing.T) {
+		require.NoError(t, songAPI.SetPubSub(pubSub))
+
+		require.Eventually(t, func() bool {
+			return pubSub.Subscribers("babyapi:Songs") == 1
+		}, time.Second, time.Millisecond)
+		require.Equal(t, 1, pubSub.Subscribers("babyapi:Albums"))
+	})
+
+	t.Run("StopCancelsSubscriptions", func(t *testing.T) {
+		go api.Serve("localhost:8092")
+		waitForAPI("http://localhost:8092")
+
+		api.Stop()
+
+		require.Eventually(t, func() bool {
+			return pubSub.Subscribers("babyapi:Albums") == 0 && pubSub.Subscribers("babyapi:Songs") == 0
+		}, time.Second, time.Millisecond)
+	})
+}
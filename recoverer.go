@@ -0,0 +1,84 @@
+package babyapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// CrashReporter is called with the request, the recovered value, and the stack trace when a handler panics. It can
+// be used to send panics to an external error reporting service
+type CrashReporter func(r *http.Request, recovered any, stack []byte)
+
+// SetCrashReporter sets a function that is called after a panic is recovered. Panics are recovered by the root API,
+// so this should be set on the root API
+func (a *API[T]) SetCrashReporter(reporter CrashReporter) *API[T] {
+	a.crashReporter = reporter
+	return a
+}
+
+// ErrPanicResponse creates the response for a recovered panic. The panic value is only logged so it is not exposed
+// to clients
+func ErrPanicResponse(r *http.Request, recovered any) *ErrResponse {
+	return &ErrResponse{
+		Err:            fmt.Errorf("panic: %v", recovered),
+		HTTPStatusCode: http.StatusInternalServerError,
+		StatusText:     "Server Error.",
+		RequestID:      middleware.GetReqID(r.Context()),
+	}
+}
+
+// recoverMiddleware recovers from panics in handlers. The panic is logged with the request's logger and then it is
+// rendered as an ErrResponse using the API's responder
+func (a *API[T]) recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			// http.ErrAbortHandler is used to intentionally abort a response, so it is not handled here
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(recovered)
+			}
+
+			stack := debug.Stack()
+
+			logger := GetLoggerFromContext(r.Context())
+			if logger != nil {
+				logger.Error("recovered from panic", "panic", fmt.Sprint(recovered), "stack", string(stack))
+			}
+
+			if a.crashReporter != nil {
+				a.crashReporter(r, recovered, stack)
+			}
+
+			// The connection is hijacked for upgrade requests so a response can't be written
+			if headerContainsToken(r.Header, "Connection", "upgrade") {
+				return
+			}
+
+			_ = Render(w, r, ErrPanicResponse(r, recovered))
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// headerContainsToken checks the comma-separated values of the header for the token. Tokens are case-insensitive,
+// so this matches "Connection: keep-alive, Upgrade" and "Connection: upgrade"
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
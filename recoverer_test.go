@@ -0,0 +1,90 @@
+package babyapi_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverer(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	var reported any
+	var reportedStack []byte
+
+	api := babyapi.NewAPI[*Album]("Albums", "/albums", func() *Album { return &Album{} })
+	api.AddCustomRoute(chi.Route{
+		Pattern: "/panic",
+		Handlers: map[string]http.Handler{
+			http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				panic("something went wrong")
+			}),
+		},
+	})
+	api.SetCrashReporter(func(_ *http.Request, recovered any, stack []byte) {
+		reported = recovered
+		reportedStack = stack
+	})
+
+	t.Run("ErrResponse", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/albums/panic", http.NoBody)
+		r.Header.Set("X-Request-Id", "request-1")
+		w := babytest.TestRequest[*Album](t, api, r)
+
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		require.Equal(t, `{"status":"Server Error.","requestID":"request-1"}`+"\n", w.Body.String())
+
+		require.Equal(t, "something went wrong", reported)
+		require.Contains(t, string(reportedStack), "recoverer_test.go")
+
+		require.Contains(t, logs.String(), `"msg":"recovered from panic"`)
+		require.Contains(t, logs.String(), `"panic":"something went wrong"`)
+		require.Contains(t, logs.String(), `"request_id":"request-1"`)
+	})
+
+	t.Run("NegotiatedFormat", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/albums/panic", http.NoBody)
+		r.Header.Set("X-Request-Id", "request-2")
+		r.Header.Set("Accept", "application/yaml")
+		w := babytest.TestRequest[*Album](t, api, r)
+
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Equal(t, "requestID: request-2\nstatus: Server Error.\n", w.Body.String())
+	})
+
+	t.Run("ProblemDetails", func(t *testing.T) {
+		api.EnableProblemDetails()
+
+		r := httptest.NewRequest(http.MethodGet, "/albums/panic", http.NoBody)
+		r.Header.Set("X-Request-Id", "request-3")
+		w := babytest.TestRequest[*Album](t, api, r)
+
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		require.JSONEq(t, `{"type":"about:blank","title":"Server Error.","status":500,"instance":"request-3"}`, w.Body.String())
+	})
+
+	t.Run("UpgradeRequestsAreNotRendered", func(t *testing.T) {
+		for _, connection := range []string{"Upgrade", "upgrade", "keep-alive, Upgrade"} {
+			t.Run(connection, func(t *testing.T) {
+				r := httptest.NewRequest(http.MethodGet, "/albums/panic", http.NoBody)
+				r.Header.Set("Connection", connection)
+				r.Header.Set("Upgrade", "websocket")
+				w := babytest.TestRequest[*Album](t, api, r)
+
+				require.Empty(t, w.Body.String())
+			})
+		}
+	})
+}
@@ -3,8 +3,10 @@ package babyapi
 import (
 	"fmt"
 	"net/http"
+	"os"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
 )
 
 // RelatedAPI declares a subset of methods from the API struct that are required to enable
@@ -25,6 +27,13 @@ type relatedAPI interface {
 	setParent(relatedAPI)
 	getCustomResponseCodeMap() map[string]int
 	isRoot() bool
+	expandKey() string
+	getExpandedItems(http.ResponseWriter, *http.Request, [][]string) ([]render.Renderer, *ErrResponse)
+	addOpenAPIPaths(*OpenAPIDocument, string)
+	useProblemDetails() bool
+	authorizeResource(*http.Request, bool) *ErrResponse
+	closePubSub()
+	Done() <-chan os.Signal
 }
 
 // Parent returns the API's parent API
@@ -24,8 +24,9 @@ type Resource interface {
 	GetID() string
 }
 
-// Patcher is used to optionally-enable PATCH endpoint. Since the library cannot generically modify resources without using
-// reflection, implement Patch function to use the input to modify the receiver
+// Patcher is used to implement PATCH requests by using the input to modify the receiver. Resources that don't implement
+// it can use EnableMergePatch or EnableJSONPatch to apply PATCH requests to the stored resource as a JSON Merge Patch
+// (RFC 7386) or JSON Patch (RFC 6902)
 type Patcher[T Resource] interface {
 	Patch(T) *ErrResponse
 }
@@ -110,7 +111,8 @@ func (id *ID) Bind(r *http.Request) error {
 			return errors.New("missing required id field")
 		}
 	case http.MethodPatch:
-		if !id.ID.IsNil() {
+		// A generic PATCH binds the full patched resource, so the ID is allowed if it is unchanged
+		if !id.ID.IsNil() && id.String() != getPatchedIDFromContext(r.Context()) {
 			return errors.New("updating ID is not allowed")
 		}
 	}
@@ -0,0 +1,161 @@
+package babyapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/go-chi/render"
+)
+
+// HTMLer allows for easily represending reponses as HTML strings when accepted content
+// type is text/html
+type HTMLer interface {
+	HTML(*http.Request) string
+}
+
+// responder writes a response value. Each API stores its own responder in the request context so render.Respond
+// is never modified
+type responder func(http.ResponseWriter, *http.Request, any)
+
+// respond writes the response as HTML when it is accepted and the value implements HTMLer. Otherwise, it uses the
+// API's encoder for the accepted content type or falls back to render.DefaultResponder. Errors are written as
+// problem details when they are enabled
+func (a *API[T]) respond(w http.ResponseWriter, r *http.Request, v any) {
+	if errResp, ok := v.(*ErrResponse); ok && a.useProblemDetails() {
+		respondWithProblemDetails(w, r, errResp)
+		return
+	}
+
+	if render.GetAcceptedContentType(r) == render.ContentTypeHTML {
+		htmler, ok := v.(HTMLer)
+		if ok {
+			render.HTML(w, r, injectCSRFToken(r, htmler.HTML(r)))
+			return
+		}
+	}
+
+	if mediaType, encoder, ok := a.negotiateEncoder(r); ok {
+		respondWithEncoder(w, r, mediaType, encoder, v)
+		return
+	}
+
+	render.DefaultResponder(w, r, v)
+}
+
+// responderMiddleware sets the API's responder in the request context so it is used by Render and Respond
+func (a *API[T]) responderMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), responderCtxKey, responder(a.respond))))
+	})
+}
+
+// Respond writes the response using the API that is handling the request. This supports HTML, content negotiation,
+// and custom encoders. Outside of an API's routes, it uses render.Respond
+func Respond(w http.ResponseWriter, r *http.Request, v any) {
+	respond, ok := r.Context().Value(responderCtxKey).(responder)
+	if !ok {
+		respond = render.Respond
+	}
+	respond(w, r, v)
+}
+
+// Render works like render.Render, but uses Respond so the response is written by the API handling the request
+// instead of the global render.Respond
+func Render(w http.ResponseWriter, r *http.Request, v render.Renderer) error {
+	err := renderer(w, r, v)
+	if err != nil {
+		return err
+	}
+
+	Respond(w, r, v)
+	return nil
+}
+
+// renderer calls Render on v and then on each of its fields that implement render.Renderer, which matches the
+// behavior of render.Render
+func renderer(w http.ResponseWriter, r *http.Request, v render.Renderer) error {
+	err := v.Render(w, r)
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Field(i)
+		if !f.Type().Implements(rendererType) || isNilValue(f) {
+			continue
+		}
+
+		err = renderer(w, r, f.Interface().(render.Renderer))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renderJSON encodes a resource outside of a response, like for webhooks, using the response wrapper and Render so
+// it matches the API's JSON responses
+func (a *API[T]) renderJSON(r *http.Request, resource T) (json.RawMessage, error) {
+	resp := a.responseWrapper(resource)
+
+	err := renderer(&discardResponseWriter{}, r, resp)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering resource: %w", err)
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding resource: %w", err)
+	}
+	return data, nil
+}
+
+// discardResponseWriter is used to call Render when there is no response to write to. It keeps the status code so
+// errors written by middleware can be returned
+type discardResponseWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = http.Header{}
+	}
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+var rendererType = reflect.TypeOf((*render.Renderer)(nil)).Elem()
+
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
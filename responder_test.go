@@ -0,0 +1,85 @@
+package babyapi_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/calvinmclean/babyapi"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPerAPIResponder(t *testing.T) {
+	plainText := func(prefix string) babyapi.Encoder {
+		return func(w io.Writer, v any) error {
+			_, err := fmt.Fprintf(w, "%s: %T", prefix, v)
+			return err
+		}
+	}
+
+	artistAPI := babyapi.NewAPI[*Artist]("Artists", "/artists", func() *Artist { return &Artist{} })
+	artistAPI.SetEncoder("text/plain", plainText("artists"))
+
+	albumAPI := babyapi.NewAPI[*Album]("Albums", "/albums", func() *Album { return &Album{} })
+	albumAPI.SetEncoder("text/plain", plainText("albums"))
+
+	songAPI := babyapi.NewAPI[*Song]("Songs", "/songs", func() *Song { return &Song{} })
+	albumAPI.AddNestedAPI(songAPI)
+
+	album := &Album{DefaultResource: babyapi.NewDefaultResource(), Title: "Album"}
+	require.NoError(t, albumAPI.Storage.Set(album))
+
+	r := chi.NewRouter()
+	artistAPI.Route(r)
+	albumAPI.Route(r)
+	r.Get("/other", func(w http.ResponseWriter, r *http.Request) {
+		_ = babyapi.Render(w, r, babyapi.ErrForbidden)
+	})
+
+	require.Equal(t,
+		reflect.ValueOf(render.DefaultResponder).Pointer(),
+		reflect.ValueOf(render.Respond).Pointer(),
+		"render.Respond should not be modified",
+	)
+
+	request := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		req.Header.Set("Accept", "text/plain")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("EachAPIUsesItsOwnEncoders", func(t *testing.T) {
+		w := request("/artists")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "artists: *babyapi.ResourceList[github.com/go-chi/render.Renderer]", w.Body.String())
+
+		w = request("/albums/" + album.GetID())
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "albums: *babyapi_test.Album", w.Body.String())
+	})
+
+	t.Run("ErrorsUseTheAPIResponder", func(t *testing.T) {
+		w := request("/artists/DoesNotExist")
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Equal(t, "artists: *babyapi.ErrResponse", w.Body.String())
+	})
+
+	t.Run("NestedAPIUsesItsOwnEncoders", func(t *testing.T) {
+		w := request("/albums/" + album.GetID() + "/songs")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	})
+
+	t.Run("RenderOutsideAPIUsesRenderRespond", func(t *testing.T) {
+		w := request("/other")
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.Equal(t, `{"status":"Forbidden"}`+"\n", w.Body.String())
+	})
+}
@@ -4,14 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"sync"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 )
 
-var respondMtx sync.Mutex
-
 func defaultResponseCodes() map[string]int {
 	return map[string]int{
 		http.MethodGet:    http.StatusOK,
@@ -22,59 +20,92 @@ func defaultResponseCodes() map[string]int {
 	}
 }
 
-// HTMLer allows for easily represending reponses as HTML strings when accepted content
-// type is text/html
-type HTMLer interface {
-	HTML(*http.Request) string
-}
-
 // Create API routes on the given router
 func (a *API[T]) Route(r chi.Router) {
-	respondMtx.Lock()
-	render.Respond = func(w http.ResponseWriter, r *http.Request, v interface{}) {
-		if render.GetAcceptedContentType(r) == render.ContentTypeHTML {
-			htmler, ok := v.(HTMLer)
-			if ok {
-				render.HTML(w, r, htmler.HTML(r))
-				return
-			}
-		}
-
-		render.DefaultResponder(w, r, v)
-	}
-	respondMtx.Unlock()
-
-	for _, m := range a.middlewares {
-		r.Use(m)
-	}
-
 	if a.parent == nil {
+		// The responder is set before other middleware so their errors use the API's format, like problem details. An
+		// inline router is used so other APIs and routes on the same router aren't affected
+		r = r.With(a.responderMiddleware)
+		a.useMiddlewares(r)
+
+		if a.openAPI.route {
+			r.Group(func(r chi.Router) {
+				a.DefaultMiddleware(r)
+				r.Get(openAPIPath, a.defaultOpenAPI)
+			})
+		}
 		a.doCustomRoutes(r, a.rootRoutes)
 	}
 
+	a.routeBase(r, a.parent == nil)
+}
+
+// routeBase creates the routes at the API's base path. The default middleware is only used for top-level APIs.
+// Otherwise, the router already has it, like the ID route of a parent API
+func (a *API[T]) routeBase(r chi.Router, topLevel bool) {
 	r.Route(a.base, func(r chi.Router) {
-		// Only set these middleware for root-level API
-		if a.parent == nil {
+		// Each API, including child APIs, sets its own responder so they can use different encoders
+		r.Use(a.responderMiddleware)
+
+		if topLevel {
 			a.DefaultMiddleware(r)
+			r.Use(headMiddleware)
+		} else {
+			// Child APIs are routed inside the parent's ID route, so their middleware is added to their own routes
+			a.useMiddlewares(r)
 		}
 
+		r.MethodNotAllowed(a.methodNotAllowed)
+
 		if a.rootAPI {
 			a.rootAPIRoutes(r)
 			return
 		}
 
-		routeIfNotNil(r.With(a.requestBodyMiddleware).Post, "/", a.Post)
-		routeIfNotNil(r.Get, "/", a.GetAll)
+		routeIfNotNil(r.With(a.requestBodyMiddleware).Post, "/", a.authorized(VerbPost, a.Post))
+		routeIfNotNil(r.Get, "/", a.authorized(VerbGetAll, a.GetAll))
+		routeIfNotNil(r.Head, "/", a.authorized(VerbGetAll, a.GetAll))
+		if a.GetAll != nil {
+			a.routeGet(r, "/_count", a.authorized(VerbGetAll, a.Count))
+			a.routeGet(r, "/_aggregate", a.authorized(VerbGetAll, a.Aggregate))
+		}
+		r.Options("/", a.defaultOptions)
+
+		if a.searchIndex != nil {
+			a.checkSearchStorage()
+			a.routeGet(r, "/_search", a.authorized(VerbGetAll, a.defaultSearch()))
+		}
+
+		if a.webhooks != nil {
+			// The webhook API isn't a child API since it isn't nested under the ID route, so it uses this API's error
+			// format and is routed without the default middleware that is already used here
+			a.webhooks.api.problemDetails = a.useProblemDetails()
+			a.webhooks.api.routeBase(r.With(a.webhooks.config.Authorizer), false)
+		}
+
+		if a.changes != nil {
+			a.routeGet(r, changeStreamPath, a.authorized(VerbGetAll, a.handleChangeStream(false)))
+		}
+
+		if a.webSocket {
+			a.routeGet(r, webSocketPath, a.authorized(VerbGetAll, a.handleWebSocket))
+		}
 
 		r.With(a.resourceExistsMiddleware).Route(fmt.Sprintf("/{%s}", a.IDParamKey()), func(r chi.Router) {
 			for _, m := range a.idMiddlewares {
 				r.Use(m)
 			}
 
-			routeIfNotNil(r.Get, "/", a.Get)
-			routeIfNotNil(r.Delete, "/", a.Delete)
-			routeIfNotNil(r.With(a.requestBodyMiddleware).Put, "/", a.Put)
-			routeIfNotNil(r.With(a.requestBodyMiddleware).Patch, "/", a.Patch)
+			routeIfNotNil(r.Get, "/", a.authorized(VerbGet, a.Get))
+			routeIfNotNil(r.Head, "/", a.authorized(VerbGet, a.Get))
+			r.Options("/", a.defaultOptions)
+			routeIfNotNil(r.Delete, "/", a.authorized(VerbDelete, a.Delete))
+			routeIfNotNil(r.With(a.requestBodyMiddleware).Put, "/", a.authorized(VerbPut, a.Put))
+			routeIfNotNil(r.With(a.requestBodyMiddleware).Patch, "/", a.authorized(VerbPatch, a.Patch))
+
+			if a.changes != nil {
+				a.routeGet(r, changeStreamPath, a.authorized(VerbGet, a.handleChangeStream(true)))
+			}
 
 			for _, subAPI := range a.subAPIs {
 				subAPI.Route(r)
@@ -87,13 +118,21 @@ func (a *API[T]) Route(r chi.Router) {
 	})
 }
 
+func (a *API[T]) useMiddlewares(r chi.Router) {
+	for _, m := range a.middlewares {
+		r.Use(m)
+	}
+}
+
 // rootAPIRoutes creates different routes for a root API that doesn't deal with any resources
 func (a *API[T]) rootAPIRoutes(r chi.Router) {
 	routeIfNotNil(r.Post, "/", a.Post)
 	routeIfNotNil(r.Get, "/", a.Get)
+	routeIfNotNil(r.Head, "/", a.Get)
 	routeIfNotNil(r.Delete, "/", a.Delete)
 	routeIfNotNil(r.Put, "/", a.Put)
 	routeIfNotNil(r.Patch, "/", a.Patch)
+	r.Options("/", a.defaultOptions)
 
 	for _, subAPI := range a.subAPIs {
 		subAPI.Route(r)
@@ -111,11 +150,34 @@ func (a *API[T]) Router() chi.Router {
 	return r
 }
 
+// routeGet adds a GET route and OPTIONS for the same pattern. Otherwise, chi routes OPTIONS requests for paths like
+// /base/_count to the ID route
+func (a *API[T]) routeGet(r chi.Router, pattern string, h http.HandlerFunc) {
+	if h == nil {
+		return
+	}
+
+	r.Get(pattern, h)
+	r.Options(pattern, a.defaultOptions)
+}
+
+// doCustomRoutes adds the custom routes to the router. HEAD and OPTIONS are automatically handled for custom
+// routes unless they have their own handlers for these methods. HEAD uses the GET handler and headMiddleware
+// discards the response body
 func (a *API[T]) doCustomRoutes(r chi.Router, routes []chi.Route) {
 	for _, cr := range routes {
 		for method, handler := range cr.Handlers {
 			r.MethodFunc(method, cr.Pattern, handler.ServeHTTP)
 		}
+
+		getHandler, hasGet := cr.Handlers[http.MethodGet]
+		if _, hasHead := cr.Handlers[http.MethodHead]; hasGet && !hasHead {
+			r.Head(cr.Pattern, getHandler.ServeHTTP)
+		}
+
+		if _, hasOptions := cr.Handlers[http.MethodOptions]; !hasOptions {
+			r.Options(cr.Pattern, a.defaultOptions)
+		}
 	}
 }
 
@@ -123,15 +185,33 @@ func (a *API[T]) defaultGet() http.HandlerFunc {
 	return Handler(func(w http.ResponseWriter, r *http.Request) render.Renderer {
 		logger := GetLoggerFromContext(r.Context())
 
+		_, httpErr := runHooks(r, a.hooks.beforeRead, *new(T), *new(T))
+		if httpErr != nil {
+			logger.Error("error executing before hook", "error", httpErr)
+			return httpErr
+		}
+
 		resource, httpErr := a.GetRequestedResource(r)
 		if httpErr != nil {
 			logger.Error("error getting requested resource", "error", httpErr.Error())
 			return httpErr
 		}
 
+		resource, httpErr = runHooks(r, a.hooks.afterRead, *new(T), resource)
+		if httpErr != nil {
+			logger.Error("error executing after hook", "error", httpErr)
+			return httpErr
+		}
+
+		resp, httpErr := a.expandResponse(w, r, resource, a.responseWrapper(resource))
+		if httpErr != nil {
+			logger.Error("error expanding resource", "error", httpErr.Error())
+			return httpErr
+		}
+
 		render.Status(r, a.responseCodes[http.MethodGet])
 
-		return a.responseWrapper(resource)
+		return resp
 	})
 }
 
@@ -139,20 +219,39 @@ func (a *API[T]) defaultGetAll() http.HandlerFunc {
 	return Handler(func(w http.ResponseWriter, r *http.Request) render.Renderer {
 		logger := GetLoggerFromContext(r.Context())
 
-		resources, err := a.Storage.GetAll(a.getAllFilter(r))
+		_, httpErr := runListHooks(r, a.hooks.beforeList, nil)
+		if httpErr != nil {
+			logger.Error("error executing before hook", "error", httpErr)
+			return httpErr
+		}
+
+		resources, err := a.Storage.GetAll(a.requestFilter(r))
 		if err != nil {
 			logger.Error("error getting resources", "error", err)
 			return InternalServerError(err)
 		}
+
+		resources, httpErr = runListHooks(r, a.hooks.afterList, resources)
+		if httpErr != nil {
+			logger.Error("error executing after hook", "error", httpErr)
+			return httpErr
+		}
 		logger.Debug("responding with resources", "count", len(resources))
 
+		w.Header().Set(TotalCountHeader, strconv.Itoa(len(resources)))
+
 		var resp render.Renderer
 		if a.getAllResponseWrapper != nil {
 			resp = a.getAllResponseWrapper(resources)
 		} else {
 			items := []render.Renderer{}
 			for _, item := range resources {
-				items = append(items, a.responseWrapper(item))
+				itemResp, httpErr := a.expandResponse(w, r, item, a.responseWrapper(item))
+				if httpErr != nil {
+					logger.Error("error expanding resource", "error", httpErr.Error())
+					return httpErr
+				}
+				items = append(items, itemResp)
 			}
 			resp = &ResourceList[render.Renderer]{Items: items}
 		}
@@ -165,20 +264,11 @@ func (a *API[T]) defaultGetAll() http.HandlerFunc {
 
 func (a *API[T]) defaultPost() http.HandlerFunc {
 	return a.ReadRequestBodyAndDo(func(r *http.Request, resource T) (T, *ErrResponse) {
-		logger := GetLoggerFromContext(r.Context())
-
-		httpErr := a.onCreateOrUpdate(r, resource)
+		resource, httpErr := a.saveResource(r, *new(T), resource)
 		if httpErr != nil {
 			return *new(T), httpErr
 		}
 
-		logger.Info("storing resource", "resource", resource)
-		err := a.Storage.Set(resource)
-		if err != nil {
-			logger.Error("error storing resource", "error", err)
-			return *new(T), InternalServerError(err)
-		}
-
 		render.Status(r, a.responseCodes[http.MethodPost])
 
 		return resource, nil
@@ -187,22 +277,18 @@ func (a *API[T]) defaultPost() http.HandlerFunc {
 
 func (a *API[T]) defaultPut() http.HandlerFunc {
 	return a.ReadRequestBodyAndDo(func(r *http.Request, resource T) (T, *ErrResponse) {
-		logger := GetLoggerFromContext(r.Context())
-
 		if resource.GetID() != a.GetIDParam(r) {
 			return *new(T), ErrInvalidRequest(fmt.Errorf("id must match URL path"))
 		}
 
-		httpErr := a.onCreateOrUpdate(r, resource)
+		previous, httpErr := a.getPreviousResource(resource.GetID())
 		if httpErr != nil {
 			return *new(T), httpErr
 		}
 
-		logger.Info("storing resource", "resource", resource)
-		err := a.Storage.Set(resource)
-		if err != nil {
-			logger.Error("error storing resource", "error", err)
-			return *new(T), InternalServerError(err)
+		resource, httpErr = a.saveResource(r, previous, resource)
+		if httpErr != nil {
+			return *new(T), httpErr
 		}
 
 		render.Status(r, a.responseCodes[http.MethodPut])
@@ -211,7 +297,41 @@ func (a *API[T]) defaultPut() http.HandlerFunc {
 	})
 }
 
+// defaultPatch uses the resource's Patcher implementation if it has one. Otherwise, it uses JSON Patch for requests
+// with the JSON Patch content type or JSON Merge Patch if they are enabled
 func (a *API[T]) defaultPatch() http.HandlerFunc {
+	patch := a.patcherPatch()
+	mergePatch := a.defaultMergePatch()
+	jsonPatch := a.defaultJSONPatch()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case isJSONPatchRequest(r) && a.usesJSONPatch():
+			jsonPatch(w, r)
+		case isJSONPatchRequest(r):
+			a.unsupportedPatch(w, r)
+		case a.usesMergePatch():
+			mergePatch(w, r)
+		default:
+			patch(w, r)
+		}
+	}
+}
+
+// unsupportedPatch responds to PATCH requests with a format that isn't enabled. If the resource can't be patched at all,
+// it responds with 405 Method Not Allowed like other PATCH requests
+func (a *API[T]) unsupportedPatch(w http.ResponseWriter, r *http.Request) {
+	acceptPatch := a.acceptPatch()
+	if acceptPatch == "" {
+		_ = Render(w, r, ErrMethodNotAllowedResponse)
+		return
+	}
+
+	w.Header().Set("Accept-Patch", acceptPatch)
+	_ = Render(w, r, ErrUnsupportedMediaTypeResponse)
+}
+
+func (a *API[T]) patcherPatch() http.HandlerFunc {
 	return a.ReadRequestBodyAndDo(func(r *http.Request, patchRequest T) (T, *ErrResponse) {
 		logger := GetLoggerFromContext(r.Context())
 
@@ -226,25 +346,23 @@ func (a *API[T]) defaultPatch() http.HandlerFunc {
 			return *new(T), ErrMethodNotAllowedResponse
 		}
 
+		// Patch modifies the resource in place, so a copy is needed for the update hooks
+		previous, err := a.copyResource(resource)
+		if err != nil {
+			return *new(T), InternalServerError(err)
+		}
+
 		httpErr = patcher.Patch(patchRequest)
 		if httpErr != nil {
 			logger.Error("error patching resource", "error", httpErr.Error())
 			return *new(T), httpErr
 		}
 
-		httpErr = a.onCreateOrUpdate(r, resource)
+		resource, httpErr = a.saveResource(r, previous, resource)
 		if httpErr != nil {
 			return *new(T), httpErr
 		}
 
-		logger.Info("storing updated resource", "resource", resource)
-
-		err := a.Storage.Set(resource)
-		if err != nil {
-			logger.Error("error storing updated resource", "error", err)
-			return *new(T), InternalServerError(err)
-		}
-
 		render.Status(r, a.responseCodes[http.MethodPatch])
 
 		return resource, nil
@@ -260,6 +378,18 @@ func (a *API[T]) defaultDelete() http.HandlerFunc {
 			return httpErr
 		}
 
+		previous, httpErr := a.GetRequestedResource(r)
+		if httpErr != nil {
+			logger.Error("error getting requested resource", "error", httpErr.Error())
+			return httpErr
+		}
+
+		_, httpErr = runHooks(r, a.hooks.beforeDelete, previous, *new(T))
+		if httpErr != nil {
+			logger.Error("error executing before hook", "error", httpErr)
+			return httpErr
+		}
+
 		id := a.GetIDParam(r)
 
 		logger.Info("deleting resource", "id", id)
@@ -281,6 +411,12 @@ func (a *API[T]) defaultDelete() http.HandlerFunc {
 			return httpErr
 		}
 
+		_, httpErr = runHooks(r, a.hooks.afterDelete, previous, *new(T))
+		if httpErr != nil {
+			logger.Error("error executing after hook", "error", httpErr)
+			return httpErr
+		}
+
 		w.WriteHeader(a.responseCodes[http.MethodDelete])
 		return nil
 	})
@@ -0,0 +1,285 @@
+package babyapi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/go-chi/render"
+)
+
+const searchQueryParam = "q"
+
+// EnableSearch creates an in-memory full-text search index for the provided string fields of the resource and adds
+// the /base/_search endpoint. It wraps the API's Storage so every write updates the index and builds the index from
+// the existing resources, so it must be used after setting Storage. Panics if any of the fields is not a string field
+// on the resource or if the index can't be built
+func (a *API[T]) EnableSearch(fields ...string) *API[T] {
+	if a.rootAPI {
+		panic("search cannot be used with a root API")
+	}
+
+	resourceType := reflect.TypeOf(new(T)).Elem()
+	for resourceType.Kind() == reflect.Ptr {
+		resourceType = resourceType.Elem()
+	}
+
+	for _, field := range fields {
+		f, ok := resourceType.FieldByName(field)
+		if !ok || f.Type.Kind() != reflect.String {
+			panic(fmt.Sprintf("invalid search field %q: must be a string field of %s", field, resourceType.Name()))
+		}
+	}
+
+	index := newSearchIndex[T](fields)
+
+	// Storage is wrapped before building the index so writes during the rebuild also update the index
+	storage := a.Storage
+	if wrapped, ok := storage.(*searchStorage[T]); ok {
+		storage = wrapped.Storage
+	}
+	a.Storage = &searchStorage[T]{storage, index}
+	a.searchIndex = index
+
+	err := index.rebuild(storage)
+	if err != nil {
+		panic(fmt.Sprintf("error building search index: %v", err))
+	}
+
+	return a
+}
+
+// checkSearchStorage panics if Storage was replaced after EnableSearch since writes would not update the index
+func (a *API[T]) checkSearchStorage() {
+	if s, ok := a.Storage.(*searchStorage[T]); !ok || s.index != a.searchIndex {
+		panic("search requires Storage to be set before EnableSearch")
+	}
+}
+
+func (a *API[T]) defaultSearch() http.HandlerFunc {
+	return Handler(func(w http.ResponseWriter, r *http.Request) render.Renderer {
+		logger := GetLoggerFromContext(r.Context())
+
+		query := r.URL.Query().Get(searchQueryParam)
+		if query == "" {
+			return ErrInvalidRequest(fmt.Errorf("missing required %q query parameter", searchQueryParam))
+		}
+
+		ids := a.searchIndex.search(query)
+		logger.Debug("search matched resources", "query", query, "count", len(ids))
+
+		filter := a.requestFilter(r)
+
+		items := []render.Renderer{}
+		for _, id := range ids {
+			resource, err := a.Storage.Get(id)
+			if err != nil {
+				if errors.Is(err, ErrNotFound) {
+					continue
+				}
+				logger.Error("error getting resource", "error", err)
+				return InternalServerError(err)
+			}
+
+			if filter != nil && !filter(resource) {
+				continue
+			}
+
+			items = append(items, a.responseWrapper(resource))
+		}
+
+		render.Status(r, a.responseCodes[http.MethodGet])
+
+		return &ResourceList[render.Renderer]{Items: items}
+	})
+}
+
+// searchStorage wraps another Storage implementation to keep the search index up to date
+type searchStorage[T Resource] struct {
+	Storage[T]
+	index *searchIndex[T]
+}
+
+func (s *searchStorage[T]) Set(resource T) error {
+	err := s.Storage.Set(resource)
+	if err != nil {
+		return err
+	}
+
+	s.index.add(resource)
+	return nil
+}
+
+func (s *searchStorage[T]) Delete(id string) error {
+	err := s.Storage.Delete(id)
+	if err != nil {
+		return err
+	}
+
+	// Storage might soft-delete so the index is only updated if the resource is really gone
+	_, err = s.Storage.Get(id)
+	if errors.Is(err, ErrNotFound) {
+		s.index.remove(id)
+	}
+
+	return nil
+}
+
+// searchIndex is an inverted index that maps terms to the IDs of resources containing them and the number
+// of times they occur
+type searchIndex[T Resource] struct {
+	fields []string
+
+	terms   map[string]map[string]int
+	idTerms map[string][]string
+	lock    sync.RWMutex
+}
+
+func newSearchIndex[T Resource](fields []string) *searchIndex[T] {
+	return &searchIndex[T]{
+		fields:  fields,
+		terms:   map[string]map[string]int{},
+		idTerms: map[string][]string{},
+	}
+}
+
+// rebuild replaces the index with a new one built from all resources in storage. The lock is held while reading
+// from storage, so writes that happen during the rebuild wait and are added to the new index
+func (si *searchIndex[T]) rebuild(storage Storage[T]) error {
+	si.lock.Lock()
+	defer si.lock.Unlock()
+
+	resources, err := storage.GetAll(nil)
+	if err != nil {
+		return err
+	}
+
+	terms, idTerms := map[string]map[string]int{}, map[string][]string{}
+	for _, resource := range resources {
+		addTerms(terms, idTerms, resource.GetID(), si.termCounts(resource))
+	}
+
+	si.terms, si.idTerms = terms, idTerms
+	return nil
+}
+
+func (si *searchIndex[T]) add(resource T) {
+	counts := si.termCounts(resource)
+
+	si.lock.Lock()
+	defer si.lock.Unlock()
+
+	si.removeLocked(resource.GetID())
+	addTerms(si.terms, si.idTerms, resource.GetID(), counts)
+}
+
+func (si *searchIndex[T]) termCounts(resource T) map[string]int {
+	counts := map[string]int{}
+	for _, text := range si.fieldValues(resource) {
+		for _, term := range tokenize(text) {
+			counts[term]++
+		}
+	}
+	return counts
+}
+
+func addTerms(terms map[string]map[string]int, idTerms map[string][]string, id string, counts map[string]int) {
+	for term, count := range counts {
+		if terms[term] == nil {
+			terms[term] = map[string]int{}
+		}
+		terms[term][id] = count
+		idTerms[id] = append(idTerms[id], term)
+	}
+}
+
+func (si *searchIndex[T]) remove(id string) {
+	si.lock.Lock()
+	defer si.lock.Unlock()
+
+	si.removeLocked(id)
+}
+
+func (si *searchIndex[T]) removeLocked(id string) {
+	for _, term := range si.idTerms[id] {
+		delete(si.terms[term], id)
+		if len(si.terms[term]) == 0 {
+			delete(si.terms, term)
+		}
+	}
+	delete(si.idTerms, id)
+}
+
+// search returns IDs of resources matching any of the query's terms. Results are ranked using TF-IDF so
+// resources matching more, or less common, terms are first
+func (si *searchIndex[T]) search(query string) []string {
+	si.lock.RLock()
+	defer si.lock.RUnlock()
+
+	total := float64(len(si.idTerms))
+	scores := map[string]float64{}
+	for _, term := range tokenize(query) {
+		matches := si.terms[term]
+		if len(matches) == 0 {
+			continue
+		}
+
+		idf := math.Log(1 + total/float64(len(matches)))
+		for id, count := range matches {
+			scores[id] += float64(count) * idf
+		}
+	}
+
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	return ids
+}
+
+func (si *searchIndex[T]) fieldValues(resource T) []string {
+	v := reflect.ValueOf(resource)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	var values []string
+	for _, field := range si.fields {
+		f, err := v.FieldByIndexErr(fieldIndex(v.Type(), field))
+		if err != nil {
+			continue
+		}
+		values = append(values, f.String())
+	}
+
+	return values
+}
+
+func fieldIndex(t reflect.Type, name string) []int {
+	f, _ := t.FieldByName(name)
+	return f.Index
+}
+
+// tokenize splits text into lowercase terms made of letters and numbers
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
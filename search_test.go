@@ -0,0 +1,136 @@
+package babyapi_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/stretchr/testify/require"
+)
+
+type Note struct {
+	babyapi.DefaultResource
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Count int    `json:"count"`
+}
+
+func (n *Note) Patch(newNote *Note) *babyapi.ErrResponse {
+	if newNote.Body != "" {
+		n.Body = newNote.Body
+	}
+	return nil
+}
+
+func TestSearch(t *testing.T) {
+	t.Run("PanicsForInvalidField", func(t *testing.T) {
+		api := babyapi.NewAPI[*Note]("Notes", "/notes", func() *Note { return &Note{} })
+		require.Panics(t, func() { api.EnableSearch("Missing") })
+		require.Panics(t, func() { api.EnableSearch("Count") })
+	})
+
+	t.Run("PanicsIfStorageIsReplaced", func(t *testing.T) {
+		api := babyapi.NewAPI[*Note]("Notes", "/notes", func() *Note { return &Note{} }).EnableSearch("Title")
+		api.Storage = babyapi.MapStorage[*Note]{}
+		require.PanicsWithValue(t, "search requires Storage to be set before EnableSearch", func() { api.Router() })
+	})
+
+	api := babyapi.NewAPI[*Note]("Notes", "/notes", func() *Note { return &Note{} })
+
+	// Existing resources are indexed when search is enabled
+	existing := &Note{DefaultResource: babyapi.NewDefaultResource(), Title: "Groceries", Body: "milk, eggs and bread"}
+	require.NoError(t, api.Storage.Set(existing))
+
+	api.EnableSearch("Title", "Body")
+
+	// Writes are indexed before the routes are created
+	unrouted := &Note{DefaultResource: babyapi.NewDefaultResource(), Title: "Unrouted", Body: "written before routing"}
+	require.NoError(t, api.Storage.Set(unrouted))
+
+	client, stop := babytest.NewTestClient[*Note](t, api)
+	defer stop()
+
+	var note1, note2 *Note
+	t.Run("CreateNotes", func(t *testing.T) {
+		resp, err := client.Post(context.Background(), &Note{Title: "Bread recipe", Body: "flour, water, salt. bake the bread"})
+		require.NoError(t, err)
+		note1 = resp.Data
+
+		resp, err = client.Post(context.Background(), &Note{Title: "Chores", Body: "take out the trash"})
+		require.NoError(t, err)
+		note2 = resp.Data
+	})
+
+	t.Run("RankedResults", func(t *testing.T) {
+		resp, err := client.Search(context.Background(), "bread")
+		require.NoError(t, err)
+		require.Equal(t, []*Note{note1, existing}, resp.Data.Items)
+	})
+
+	t.Run("IndexedBeforeRouting", func(t *testing.T) {
+		resp, err := client.Search(context.Background(), "routing")
+		require.NoError(t, err)
+		require.Equal(t, []*Note{unrouted}, resp.Data.Items)
+	})
+
+	t.Run("CaseInsensitive", func(t *testing.T) {
+		resp, err := client.Search(context.Background(), "TRASH")
+		require.NoError(t, err)
+		require.Equal(t, []*Note{note2}, resp.Data.Items)
+	})
+
+	t.Run("NoResults", func(t *testing.T) {
+		resp, err := client.Search(context.Background(), "nothing")
+		require.NoError(t, err)
+		require.Len(t, resp.Data.Items, 0)
+	})
+
+	t.Run("UpdatedOnPatch", func(t *testing.T) {
+		_, err := client.Patch(context.Background(), note2.GetID(), &Note{Body: "vacuum the floor"})
+		require.NoError(t, err)
+
+		resp, err := client.Search(context.Background(), "trash")
+		require.NoError(t, err)
+		require.Len(t, resp.Data.Items, 0)
+
+		resp, err = client.Search(context.Background(), "vacuum")
+		require.NoError(t, err)
+		require.Len(t, resp.Data.Items, 1)
+	})
+
+	t.Run("UpdatedOnDelete", func(t *testing.T) {
+		_, err := client.Delete(context.Background(), note1.GetID())
+		require.NoError(t, err)
+
+		resp, err := client.Search(context.Background(), "bread")
+		require.NoError(t, err)
+		require.Equal(t, []*Note{existing}, resp.Data.Items)
+	})
+
+	t.Run("MissingQuery", func(t *testing.T) {
+		_, err := client.Search(context.Background(), "")
+		require.Error(t, err)
+		require.Equal(t, "error searching resources: unexpected response with text: Invalid request.", err.Error())
+	})
+
+	t.Run("CLI", func(t *testing.T) {
+		var out bytes.Buffer
+		err := api.RunWithArgs(&out, []string{"search", "Notes", "groceries"}, "", client.Address, false, nil, "")
+		require.NoError(t, err)
+		require.Equal(t, `{"items":[{"body":"milk, eggs and bread","count":0,"id":"`+existing.GetID()+`","title":"Groceries"}]}`, strings.TrimSpace(out.String()))
+	})
+
+	t.Run("RespectsGetAllFilter", func(t *testing.T) {
+		api.SetGetAllFilter(func(r *http.Request) babyapi.FilterFunc[*Note] {
+			return func(n *Note) bool { return n.Title != "Groceries" }
+		})
+
+		resp, err := client.Search(context.Background(), "bread")
+		require.NoError(t, err)
+		require.Len(t, resp.Data.Items, 0)
+	})
+}
@@ -1,45 +1,377 @@
 package babyapi
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
 	"net/http"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
+// DropPolicy decides what happens when a BroadcastChannel listener's buffer is full
+type DropPolicy int
+
+const (
+	// DropOldest discards the oldest buffered value to make room for the new value
+	DropOldest DropPolicy = iota
+	// DropNewest discards the new value and keeps the buffered values
+	DropNewest
+	// DisconnectSlowListener removes and closes the listener's channel
+	DisconnectSlowListener
+)
+
+const (
+	defaultBroadcastBufferSize = 16
+	defaultReplayBufferSize    = 64
+	defaultHeartbeatInterval   = 15 * time.Second
+
+	lastEventIDHeader = "Last-Event-ID"
+)
+
+// BroadcastMetrics counts what happened to values sent with SendToAll. Each listener counts separately, so one
+// value sent to three listeners is three sends
+type BroadcastMetrics struct {
+	Sent         uint64
+	Dropped      uint64
+	Disconnected uint64
+}
+
+// BroadcastChannel sends values to all listeners without blocking. Each listener has a buffered channel and the
+// DropPolicy is used when a listener can't keep up. Every value gets an increasing ID and the most recent values
+// are kept so reconnecting listeners can replay what they missed. The zero value uses a buffer of 16, DropOldest,
+// and keeps the last 64 values. Use SetPubSub to share values with BroadcastChannels in other instances
 type BroadcastChannel[T any] struct {
-	listeners []chan T
-	lock      sync.RWMutex
+	listeners []*broadcastListener[T]
+	lock      sync.Mutex
+
+	bufferSize int
+	policy     DropPolicy
+
+	lastID       uint64
+	replaySize   int
+	history      []replayEntry[T]
+	historyStart int
+
+	sent         atomic.Uint64
+	dropped      atomic.Uint64
+	disconnected atomic.Uint64
+
+	pubSub      PubSub
+	topic       string
+	unsubscribe context.CancelFunc
+	// instanceID is a random value in the lower bits of IDs for published values so instances create different IDs
+	instanceID uint64
+}
+
+// pubSubMessage is the encoded form of a value sent through a PubSub. The ID is created by the publishing instance
+// so every instance uses the same ID for the value
+type pubSubMessage[T any] struct {
+	ID    uint64 `json:"id"`
+	Value T      `json:"value"`
+}
+
+// pubSubInstanceBits is the number of lower bits of a published value's ID used for the instanceID
+const pubSubInstanceBits = 12
+
+// NewBroadcastChannel creates a BroadcastChannel with the buffer size for each listener and a DropPolicy
+func NewBroadcastChannel[T any](bufferSize int, policy DropPolicy) *BroadcastChannel[T] {
+	return &BroadcastChannel[T]{bufferSize: bufferSize, policy: policy}
+}
+
+// replayEntry is a value kept in the BroadcastChannel's replay ring buffer
+type replayEntry[T any] struct {
+	id    uint64
+	value T
+}
+
+// broadcastListener is a listener's channel and the filters that decide which values it receives
+type broadcastListener[T any] struct {
+	ch      chan T
+	filters []FilterFunc[T]
 }
 
-func (bc *BroadcastChannel[T]) GetListener() chan T {
+func (l *broadcastListener[T]) matches(value T) bool {
+	for _, filter := range l.filters {
+		if !filter(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// sequencedValue is implemented by values that store the ID assigned by the BroadcastChannel. It returns a copy with
+// the ID so the caller's value and values that were already sent or kept for replay are not modified
+type sequencedValue interface {
+	withSequenceID(uint64) any
+}
+
+// SetReplayBufferSize sets how many of the most recent values are kept for GetListenerSince. Use a negative size
+// to disable replay. Changing the size clears the kept values
+func (bc *BroadcastChannel[T]) SetReplayBufferSize(size int) *BroadcastChannel[T] {
 	bc.lock.Lock()
 	defer bc.lock.Unlock()
-	newChan := make(chan T)
-	bc.listeners = append(bc.listeners, newChan)
-	return newChan
+
+	bc.replaySize = size
+	bc.history = nil
+	bc.historyStart = 0
+
+	return bc
 }
 
+// GetListener creates a new listener channel that only receives values matching all of the filters. The channel is
+// closed when it is removed or when it is disconnected by the DisconnectSlowListener policy
+func (bc *BroadcastChannel[T]) GetListener(filters ...FilterFunc[T]) chan T {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	return bc.addListener(filters).ch
+}
+
+// GetListenerSince creates a new listener channel and returns the kept values with an ID greater than lastID. Values
+// are returned in the order they were sent and values sent afterwards only go to the channel, so nothing is missed
+// or repeated. If lastID is newer than any sent value, the BroadcastChannel was probably restarted so all kept values
+// are returned. The filters are used like GetListener and also apply to the returned values
+func (bc *BroadcastChannel[T]) GetListenerSince(lastID uint64, filters ...FilterFunc[T]) (chan T, []T) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	listener := bc.addListener(filters)
+	replayAll := lastID > bc.lastID
+
+	var missed []T
+	for i := range bc.history {
+		entry := bc.history[(bc.historyStart+i)%len(bc.history)]
+		if (replayAll || entry.id > lastID) && listener.matches(entry.value) {
+			missed = append(missed, entry.value)
+		}
+	}
+
+	return listener.ch, missed
+}
+
+func (bc *BroadcastChannel[T]) addListener(filters []FilterFunc[T]) *broadcastListener[T] {
+	bufferSize := bc.bufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBroadcastBufferSize
+	}
+
+	listener := &broadcastListener[T]{make(chan T, bufferSize), filters}
+	bc.listeners = append(bc.listeners, listener)
+	return listener
+}
+
+// RemoveListener removes and closes the listener channel. It does nothing if the listener was already removed
 func (bc *BroadcastChannel[T]) RemoveListener(removeChan chan T) {
 	bc.lock.Lock()
 	defer bc.lock.Unlock()
 	for i, listener := range bc.listeners {
-		if listener == removeChan {
+		if listener.ch == removeChan {
 			bc.listeners[i] = bc.listeners[len(bc.listeners)-1]
 			bc.listeners = bc.listeners[:len(bc.listeners)-1]
-			close(listener)
+			close(listener.ch)
 			return
 		}
 	}
 }
 
+// SetPubSub subscribes to the topic and sends values through it, so every BroadcastChannel using the topic receives
+// all values. Values are encoded as JSON with an ID from the publishing instance, so IDs used for replay are the same
+// in every instance. These IDs start with the publisher's time in microseconds and are ordered as long as the
+// instances' clocks are in sync. Use Close to unsubscribe
+func (bc *BroadcastChannel[T]) SetPubSub(ps PubSub, topic string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := ps.Subscribe(ctx, topic, func(data []byte) {
+		var message pubSubMessage[T]
+		err := json.Unmarshal(data, &message)
+		if err != nil {
+			slog.Default().Error("error decoding broadcast message", "error", err, "topic", topic)
+			return
+		}
+		bc.deliver(message.Value, message.ID)
+	})
+	if err != nil {
+		cancel()
+		return fmt.Errorf("error subscribing to topic %q: %w", topic, err)
+	}
+
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	if bc.unsubscribe != nil {
+		bc.unsubscribe()
+	}
+	bc.pubSub, bc.topic, bc.unsubscribe = ps, topic, cancel
+	if bc.instanceID == 0 {
+		bc.instanceID = rand.Uint64() & (1<<pubSubInstanceBits - 1)
+	}
+
+	return nil
+}
+
+// Close unsubscribes from the PubSub topic. Values sent afterwards are only sent to listeners in this instance
+func (bc *BroadcastChannel[T]) Close() {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	if bc.unsubscribe != nil {
+		bc.unsubscribe()
+	}
+	bc.pubSub, bc.topic, bc.unsubscribe = nil, "", nil
+}
+
+// SendToAll assigns the next ID to the input, keeps it for replay, and sends it to every listener with matching
+// filters without blocking. When a listener's buffer is full, the DropPolicy is used. If a PubSub is set, the input
+// is published and listeners receive it from the subscription instead. If publishing fails, the input is only sent to
+// this instance's listeners
 func (bc *BroadcastChannel[T]) SendToAll(input T) {
-	bc.lock.RLock()
-	defer bc.lock.RUnlock()
+	bc.lock.Lock()
+	ps, topic := bc.pubSub, bc.topic
+	var id uint64
+	if ps != nil {
+		id = bc.nextPublishedID()
+	}
+	bc.lock.Unlock()
+
+	if ps == nil {
+		bc.deliver(input, 0)
+		return
+	}
+
+	message, err := json.Marshal(pubSubMessage[T]{id, input})
+	if err == nil {
+		err = ps.Publish(context.Background(), topic, message)
+	}
+	if err != nil {
+		slog.Default().Error("error publishing broadcast message", "error", err, "topic", topic)
+		bc.deliver(input, id)
+	}
+}
+
+// nextPublishedID creates an ID from the current time and the instanceID. It is always greater than the IDs this
+// instance has already used or received
+func (bc *BroadcastChannel[T]) nextPublishedID() uint64 {
+	timestamp := uint64(time.Now().UnixMicro())
+	if last := bc.lastID >> pubSubInstanceBits; timestamp <= last {
+		timestamp = last + 1
+	}
+
+	bc.lastID = timestamp<<pubSubInstanceBits | bc.instanceID
+	return bc.lastID
+}
+
+// deliver keeps the input for replay and sends it to this instance's listeners. Values from a PubSub have an ID and
+// other values are assigned the next ID
+func (bc *BroadcastChannel[T]) deliver(input T, id uint64) {
+	var slowListeners []chan T
+
+	bc.lock.Lock()
+	input = bc.record(input, id)
 	for _, listener := range bc.listeners {
-		listener <- input
+		if !listener.matches(input) {
+			continue
+		}
+
+		if bc.send(listener.ch, input) {
+			continue
+		}
+
+		if bc.policy == DisconnectSlowListener {
+			slowListeners = append(slowListeners, listener.ch)
+			continue
+		}
+		bc.dropped.Add(1)
+	}
+	bc.lock.Unlock()
+
+	for _, listener := range slowListeners {
+		bc.RemoveListener(listener)
+		bc.disconnected.Add(1)
+	}
+}
+
+// record adds the input to the replay ring buffer and returns it with its ID. Values that store the ID are copied. The
+// next ID is used if id is 0
+func (bc *BroadcastChannel[T]) record(input T, id uint64) T {
+	if id == 0 {
+		id = bc.lastID + 1
+	}
+	bc.lastID = max(bc.lastID, id)
+
+	if sv, ok := any(input).(sequencedValue); ok {
+		input = sv.withSequenceID(id).(T)
+	}
+
+	size := bc.replaySize
+	if size == 0 {
+		size = defaultReplayBufferSize
+	}
+	if size < 0 {
+		return input
+	}
+
+	entry := replayEntry[T]{id, input}
+	if len(bc.history) < size {
+		bc.history = append(bc.history, entry)
+		return input
+	}
+
+	bc.history[bc.historyStart] = entry
+	bc.historyStart = (bc.historyStart + 1) % size
+	return input
+}
+
+// send tries to send to the listener without blocking. With DropOldest, it discards the oldest value to make room
+func (bc *BroadcastChannel[T]) send(listener chan T, input T) bool {
+	select {
+	case listener <- input:
+		bc.sent.Add(1)
+		return true
+	default:
+	}
+
+	if bc.policy != DropOldest {
+		return false
+	}
+
+	// If the oldest value is discarded but the retry fails, the caller counts the input as dropped, so only one drop
+	// is counted
+	discarded := false
+	select {
+	case <-listener:
+		discarded = true
+	default:
+	}
+
+	select {
+	case listener <- input:
+		bc.sent.Add(1)
+		if discarded {
+			bc.dropped.Add(1)
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// Metrics returns the total counts for all listeners
+func (bc *BroadcastChannel[T]) Metrics() BroadcastMetrics {
+	return BroadcastMetrics{
+		Sent:         bc.sent.Load(),
+		Dropped:      bc.dropped.Load(),
+		Disconnected: bc.disconnected.Load(),
 	}
 }
 
@@ -56,25 +388,96 @@ func (bc *BroadcastChannel[T]) GetInputChannel() chan T {
 	return newInputChan
 }
 
-// ServerSentEvent is a simple struct that represents an event used in HTTP event stream
+// ServerSentEvent is a simple struct that represents an event used in HTTP event stream. The ID is set by the
+// BroadcastChannel when the event is sent. Retry tells the client how long to wait before reconnecting and Comment
+// is written as comment lines, which clients ignore. ResourceID isn't written and is only used to filter events
+// with the "id" query parameter
 type ServerSentEvent struct {
-	Event string
-	Data  string
+	ID         string
+	Event      string
+	Data       string
+	Retry      time.Duration
+	Comment    string
+	ResourceID string
+}
+
+func (sse *ServerSentEvent) withSequenceID(id uint64) any {
+	result := *sse
+	result.ID = strconv.FormatUint(id, 10)
+	return &result
 }
 
-// Write will write the ServerSentEvent to the HTTP response stream and flush. It removes all newlines
-// in the event data
+// Write will write the ServerSentEvent to the HTTP response stream and flush. Each line of the data is written
+// in its own "data:" field so clients receive the original newlines
 func (sse *ServerSentEvent) Write(w http.ResponseWriter) {
-	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", sse.Event, strings.ReplaceAll(sse.Data, "\n", ""))
+	var sb strings.Builder
+
+	if sse.Comment != "" {
+		for _, line := range splitEventLines(sse.Comment) {
+			sb.WriteString(": " + line + "\n")
+		}
+	}
+
+	if sse.Event != "" {
+		sb.WriteString("event: " + singleEventLine(sse.Event) + "\n")
+	}
+
+	if sse.Event != "" || sse.Data != "" {
+		for _, line := range splitEventLines(sse.Data) {
+			sb.WriteString("data: " + line + "\n")
+		}
+	}
+
+	if sse.ID != "" {
+		sb.WriteString("id: " + singleEventLine(sse.ID) + "\n")
+	}
+
+	if sse.Retry > 0 {
+		sb.WriteString("retry: " + strconv.FormatInt(sse.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	sb.WriteString("\n")
+
+	_, _ = io.WriteString(w, sb.String())
 	if f, ok := w.(http.Flusher); ok {
 		f.Flush()
 	}
 }
 
+// splitEventLines splits on any of the line endings allowed by the event stream format
+func splitEventLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.Split(s, "\n")
+}
+
+// singleEventLine removes characters that would end a field early or are not allowed in it
+func singleEventLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "", "\x00", "").Replace(s)
+}
+
+// SetServerSentEventHeartbeat sets how often a comment is written to idle event streams so proxies don't close them.
+// The default is 15 seconds and a negative interval disables heartbeats
+func (a *API[T]) SetServerSentEventHeartbeat(interval time.Duration) *API[T] {
+	a.sseHeartbeat = interval
+	return a
+}
+
+func (a *API[T]) heartbeatInterval() time.Duration {
+	if a.sseHeartbeat == 0 {
+		return defaultHeartbeatInterval
+	}
+	return a.sseHeartbeat
+}
+
 // AddServerSentEventHandler is a shortcut for HandleServerSentEvents that automatically creates and returns
-// the events channel and adds a custom handler for GET requests matching the provided pattern
+// the events channel and adds a custom handler for GET requests matching the provided pattern. If the API has a
+// PubSub, events are shared with other instances
 func (a *API[T]) AddServerSentEventHandler(pattern string) chan *ServerSentEvent {
 	eventsBroadcastChannel := BroadcastChannel[*ServerSentEvent]{}
+	if a.pubSub != nil {
+		connectPubSub(&eventsBroadcastChannel, a.pubSub, pattern)
+	}
 
 	a.AddCustomRoute(chi.Route{
 		Pattern: pattern,
@@ -87,24 +490,92 @@ func (a *API[T]) AddServerSentEventHandler(pattern string) chan *ServerSentEvent
 }
 
 // HandleServerSentEvents is a handler function that will listen on the provided channel and write events
-// to the HTTP response
-func (a *API[T]) HandleServerSentEvents(EventsBroadcastChannel *BroadcastChannel[*ServerSentEvent]) http.HandlerFunc {
+// to the HTTP response. If the request has a Last-Event-ID header, missed events are written first. Heartbeat
+// comments are written while the stream is idle and the stream ends when the API stops.
+// Clients only receive events matching the "event" and "id" query parameters (each can be used more than once) and
+// the filters created from the request
+func (a *API[T]) HandleServerSentEvents(EventsBroadcastChannel *BroadcastChannel[*ServerSentEvent], filters ...func(*http.Request) FilterFunc[*ServerSentEvent]) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		events := EventsBroadcastChannel.GetListener()
+		listenerFilters := []FilterFunc[*ServerSentEvent]{
+			queryParamFilter(r, func(e *ServerSentEvent) (string, string) { return e.Event, e.ResourceID }),
+		}
+		for _, filter := range filters {
+			listenerFilters = append(listenerFilters, filter(r))
+		}
+
+		events, missed := getListenerForRequest(EventsBroadcastChannel, r, listenerFilters...)
 		defer EventsBroadcastChannel.RemoveListener(events)
-		w.Header().Set("Cache-Control", "no-cache")
-		w.Header().Set("Connection", "keep-alive")
-		w.Header().Set("Content-Type", "text/event-stream")
-
-		for {
-			select {
-			case e := <-events:
-				e.Write(w)
-			case <-r.Context().Done():
-				return
-			case <-a.Done():
+
+		startEventStream(w)
+
+		for _, e := range missed {
+			e.Write(w)
+		}
+
+		streamEvents(w, r, a.Done(), a.heartbeatInterval(), events, func(e *ServerSentEvent) {
+			e.Write(w)
+		})
+	}
+}
+
+// startEventStream writes the event stream headers and flushes them so the client knows it is connected
+func startEventStream(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// streamEvents calls write for each event and writes heartbeats until the listener is closed, the request ends, or
+// the API stops
+func streamEvents[E any](w http.ResponseWriter, r *http.Request, done <-chan os.Signal, heartbeat time.Duration, events chan E, write func(E)) {
+	var heartbeats <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		heartbeats = ticker.C
+	}
+
+	for {
+		select {
+		case e, ok := <-events:
+			// The listener was disconnected for being too slow
+			if !ok {
 				return
 			}
+			write(e)
+		case <-heartbeats:
+			(&ServerSentEvent{Comment: "heartbeat"}).Write(w)
+		case <-r.Context().Done():
+			return
+		case <-done:
+			return
 		}
 	}
 }
+
+// getListenerForRequest gets a listener and the missed values when the request has a valid Last-Event-ID header
+func getListenerForRequest[T any](bc *BroadcastChannel[T], r *http.Request, filters ...FilterFunc[T]) (chan T, []T) {
+	lastEventID, err := strconv.ParseUint(r.Header.Get(lastEventIDHeader), 10, 64)
+	if err != nil {
+		return bc.GetListener(filters...), nil
+	}
+	return bc.GetListenerSince(lastEventID, filters...)
+}
+
+// queryParamFilter creates a filter from the "event" and "id" query parameters. The topic function gets the event
+// name and resource ID from a value
+func queryParamFilter[T any](r *http.Request, topic func(T) (string, string)) FilterFunc[T] {
+	query := r.URL.Query()
+	events := query["event"]
+	ids := query["id"]
+
+	return func(value T) bool {
+		event, id := topic(value)
+		return (len(events) == 0 || slices.Contains(events, event)) &&
+			(len(ids) == 0 || slices.Contains(ids, id))
+	}
+}
@@ -0,0 +1,389 @@
+package babyapi_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(listener chan int) []int {
+	var result []int
+	for {
+		select {
+		case v, ok := <-listener:
+			if !ok {
+				return result
+			}
+			result = append(result, v)
+		default:
+			return result
+		}
+	}
+}
+
+func TestBroadcastChannel(t *testing.T) {
+	t.Run("GetListenerSince", func(t *testing.T) {
+		bc := babyapi.NewBroadcastChannel[int](10, babyapi.DropOldest).SetReplayBufferSize(3)
+
+		for i := 1; i <= 5; i++ {
+			bc.SendToAll(i)
+		}
+
+		listener, missed := bc.GetListenerSince(3)
+		require.Equal(t, []int{4, 5}, missed)
+
+		// Only the last 3 values are kept
+		_, missed = bc.GetListenerSince(0)
+		require.Equal(t, []int{3, 4, 5}, missed)
+
+		// An ID newer than any sent value replays everything
+		_, missed = bc.GetListenerSince(100)
+		require.Equal(t, []int{3, 4, 5}, missed)
+
+		_, missed = bc.GetListenerSince(5)
+		require.Empty(t, missed)
+
+		bc.SendToAll(6)
+		require.Equal(t, []int{6}, readAll(listener))
+
+		_, missed = babyapi.NewBroadcastChannel[int](10, babyapi.DropOldest).SetReplayBufferSize(-1).GetListenerSince(0)
+		require.Empty(t, missed)
+	})
+
+	t.Run("SendingTheSameEventTwice", func(t *testing.T) {
+		bc := babyapi.NewBroadcastChannel[*babyapi.ServerSentEvent](10, babyapi.DropOldest)
+		listener := bc.GetListener()
+
+		event := &babyapi.ServerSentEvent{Event: "ping"}
+		bc.SendToAll(event)
+		bc.SendToAll(event)
+
+		// The caller's event isn't modified and each sent event has its own ID
+		require.Empty(t, event.ID)
+		require.Equal(t, "1", (<-listener).ID)
+		require.Equal(t, "2", (<-listener).ID)
+
+		_, missed := bc.GetListenerSince(0)
+		require.Len(t, missed, 2)
+		require.Equal(t, "1", missed[0].ID)
+		require.Equal(t, "2", missed[1].ID)
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		bc := &babyapi.BroadcastChannel[int]{}
+		even := bc.GetListener(func(i int) bool { return i%2 == 0 })
+		evenAndLarge := bc.GetListener(func(i int) bool { return i%2 == 0 }, func(i int) bool { return i > 2 })
+		all := bc.GetListener()
+
+		for i := 1; i <= 4; i++ {
+			bc.SendToAll(i)
+		}
+
+		require.Equal(t, []int{2, 4}, readAll(even))
+		require.Equal(t, []int{4}, readAll(evenAndLarge))
+		require.Equal(t, []int{1, 2, 3, 4}, readAll(all))
+		require.Equal(t, uint64(7), bc.Metrics().Sent)
+
+		_, missed := bc.GetListenerSince(1, func(i int) bool { return i%2 == 1 })
+		require.Equal(t, []int{3}, missed)
+	})
+
+	t.Run("DropOldest", func(t *testing.T) {
+		bc := babyapi.NewBroadcastChannel[int](2, babyapi.DropOldest)
+		listener := bc.GetListener()
+
+		for i := 1; i <= 4; i++ {
+			bc.SendToAll(i)
+		}
+
+		require.Equal(t, []int{3, 4}, readAll(listener))
+		require.Equal(t, babyapi.BroadcastMetrics{Sent: 4, Dropped: 2}, bc.Metrics())
+	})
+
+	t.Run("DropNewest", func(t *testing.T) {
+		bc := babyapi.NewBroadcastChannel[int](2, babyapi.DropNewest)
+		listener := bc.GetListener()
+
+		for i := 1; i <= 4; i++ {
+			bc.SendToAll(i)
+		}
+
+		require.Equal(t, []int{1, 2}, readAll(listener))
+		require.Equal(t, babyapi.BroadcastMetrics{Sent: 2, Dropped: 2}, bc.Metrics())
+	})
+
+	t.Run("DisconnectSlowListener", func(t *testing.T) {
+		bc := babyapi.NewBroadcastChannel[int](1, babyapi.DisconnectSlowListener)
+		slow := bc.GetListener()
+		fast := bc.GetListener()
+
+		bc.SendToAll(1)
+		require.Equal(t, []int{1}, readAll(fast))
+
+		bc.SendToAll(2)
+		require.Equal(t, []int{2}, readAll(fast))
+
+		v, ok := <-slow
+		require.True(t, ok)
+		require.Equal(t, 1, v)
+		_, ok = <-slow
+		require.False(t, ok, "slow listener should be closed")
+
+		require.Equal(t, babyapi.BroadcastMetrics{Sent: 3, Disconnected: 1}, bc.Metrics())
+
+		// Removing a listener that was already disconnected is allowed
+		bc.RemoveListener(slow)
+	})
+
+	t.Run("StalledListenerDoesNotBlockOthers", func(t *testing.T) {
+		bc := &babyapi.BroadcastChannel[int]{}
+		_ = bc.GetListener()
+		listener := bc.GetListener()
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		var received []int
+		go func() {
+			defer wg.Done()
+			for v := range listener {
+				received = append(received, v)
+			}
+		}()
+
+		done := make(chan struct{})
+		go func() {
+			for i := 0; i < 100; i++ {
+				bc.SendToAll(i)
+			}
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("SendToAll blocked on a stalled listener")
+		}
+
+		bc.RemoveListener(listener)
+		wg.Wait()
+		require.NotEmpty(t, received)
+	})
+
+	t.Run("RemoveListenerWhileSending", func(t *testing.T) {
+		bc := babyapi.NewBroadcastChannel[int](1, babyapi.DropOldest)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 1000; i++ {
+				bc.SendToAll(i)
+			}
+		}()
+
+		for i := 0; i < 100; i++ {
+			bc.RemoveListener(bc.GetListener())
+		}
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("deadlock between SendToAll and RemoveListener")
+		}
+	})
+}
+
+func TestServerSentEventReplay(t *testing.T) {
+	api := babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} })
+
+	bc := &babyapi.BroadcastChannel[*babyapi.ServerSentEvent]{}
+	api.AddCustomRoute(chi.Route{
+		Pattern:  "/events",
+		Handlers: map[string]http.Handler{http.MethodGet: api.HandleServerSentEvents(bc)},
+	})
+
+	address, stop := babytest.TestServe[*Memo](t, api)
+	defer stop()
+
+	for _, data := range []string{"one", "two", "three"} {
+		bc.SendToAll(&babyapi.ServerSentEvent{Event: "message", Data: data})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := listenForEvents(t, ctx, address+"/memos/events", http.Header{"Last-Event-ID": {"1"}})
+	require.Equal(t, "event: message data: two id: 2", nextEvent(t, events))
+	require.Equal(t, "event: message data: three id: 3", nextEvent(t, events))
+
+	// The listener is registered before missed events are written, so live events follow
+	bc.SendToAll(&babyapi.ServerSentEvent{Event: "message", Data: "live"})
+	require.Equal(t, "event: message data: live id: 4", nextEvent(t, events))
+}
+
+func TestServerSentEventWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    babyapi.ServerSentEvent
+		expected string
+	}{
+		{
+			"SingleLine",
+			babyapi.ServerSentEvent{Event: "event", Data: "hello"},
+			"event: event\ndata: hello\n\n",
+		},
+		{
+			"MultiLineData",
+			babyapi.ServerSentEvent{Event: "event", Data: "{\n  \"key\": \"value\"\r\n}"},
+			"event: event\ndata: {\ndata:   \"key\": \"value\"\ndata: }\n\n",
+		},
+		{
+			"AllFields",
+			babyapi.ServerSentEvent{ID: "1", Event: "event", Data: "hello", Retry: 3 * time.Second, Comment: "first\nsecond"},
+			": first\n: second\nevent: event\ndata: hello\nid: 1\nretry: 3000\n\n",
+		},
+		{
+			"NewlinesRemovedFromEventAndID",
+			babyapi.ServerSentEvent{ID: "1\n2", Event: "my\nevent"},
+			"event: myevent\ndata: \nid: 12\n\n",
+		},
+		{
+			"CommentOnly",
+			babyapi.ServerSentEvent{Comment: "heartbeat"},
+			": heartbeat\n\n",
+		},
+		{
+			"RetryOnly",
+			babyapi.ServerSentEvent{Retry: time.Second},
+			"retry: 1000\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.event.Write(w)
+			require.Equal(t, tt.expected, w.Body.String())
+			require.True(t, w.Flushed)
+		})
+	}
+}
+
+func TestServerSentEventHeartbeat(t *testing.T) {
+	api := babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} }).
+		SetServerSentEventHeartbeat(10 * time.Millisecond)
+	api.AddServerSentEventHandler("/events")
+
+	address, stop := babytest.TestServe[*Memo](t, api)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address+"/memos/events", http.NoBody)
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	require.True(t, scanner.Scan())
+	require.Equal(t, ": heartbeat", scanner.Text())
+}
+
+func TestServerSentEventsEndOnShutdown(t *testing.T) {
+	api := babyapi.NewAPI[*Album]("Albums", "/albums", func() *Album { return &Album{} })
+	api.AddServerSentEventHandler("/events")
+
+	songAPI := babyapi.NewAPI[*Song]("Songs", "/songs", func() *Song { return &Song{} }).EnableChangeStream()
+	api.AddNestedAPI(songAPI)
+
+	album := &Album{DefaultResource: babyapi.NewDefaultResource()}
+	require.NoError(t, api.Storage.Set(album))
+
+	go api.Serve("localhost:8091")
+	serverURL := "http://localhost:8091"
+	waitForAPI(serverURL)
+
+	var responses []*http.Response
+	for _, path := range []string{"/albums/events", "/albums/" + album.GetID() + "/songs/events"} {
+		resp, err := http.Get(serverURL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		responses = append(responses, resp)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		api.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for event streams to end")
+	}
+
+	for _, resp := range responses {
+		_, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+	}
+}
+
+func TestServerSentEventFilters(t *testing.T) {
+	api := babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} })
+
+	bc := &babyapi.BroadcastChannel[*babyapi.ServerSentEvent]{}
+	api.AddCustomRoute(chi.Route{
+		Pattern: "/events",
+		Handlers: map[string]http.Handler{
+			http.MethodGet: api.HandleServerSentEvents(bc, func(r *http.Request) babyapi.FilterFunc[*babyapi.ServerSentEvent] {
+				return func(e *babyapi.ServerSentEvent) bool {
+					return r.URL.Query().Get("private") == "true" || !strings.HasPrefix(e.Data, "private")
+				}
+			}),
+		},
+	})
+
+	address, stop := babytest.TestServe[*Memo](t, api)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	byEvent := listenForEvents(t, ctx, address+"/memos/events?event=newTODO", nil)
+	byID := listenForEvents(t, ctx, address+"/memos/events?id=abc&id=def", nil)
+	byEventAndID := listenForEvents(t, ctx, address+"/memos/events?event=newTODO&id=abc", nil)
+	private := listenForEvents(t, ctx, address+"/memos/events?private=true", nil)
+
+	bc.SendToAll(&babyapi.ServerSentEvent{Event: "newTODO", Data: "first", ResourceID: "xyz"})
+	bc.SendToAll(&babyapi.ServerSentEvent{Event: "other", Data: "second", ResourceID: "def"})
+	bc.SendToAll(&babyapi.ServerSentEvent{Event: "newTODO", Data: "private third", ResourceID: "abc"})
+	bc.SendToAll(&babyapi.ServerSentEvent{Event: "newTODO", Data: "fourth", ResourceID: "abc"})
+
+	require.Equal(t, "event: newTODO data: first id: 1", nextEvent(t, byEvent))
+	require.Equal(t, "event: newTODO data: fourth id: 4", nextEvent(t, byEvent))
+
+	require.Equal(t, "event: other data: second id: 2", nextEvent(t, byID))
+	require.Equal(t, "event: newTODO data: fourth id: 4", nextEvent(t, byID))
+
+	require.Equal(t, "event: newTODO data: fourth id: 4", nextEvent(t, byEventAndID))
+
+	require.Equal(t, "event: newTODO data: first id: 1", nextEvent(t, private))
+	require.Equal(t, "event: other data: second id: 2", nextEvent(t, private))
+	require.Equal(t, "event: newTODO data: private third id: 3", nextEvent(t, private))
+	require.Equal(t, "event: newTODO data: fourth id: 4", nextEvent(t, private))
+}
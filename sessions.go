@@ -0,0 +1,515 @@
+package babyapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"maps"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// CSRFHeader is the request header that can have the CSRF token. It is used by htmx requests
+	CSRFHeader = "X-CSRF-Token"
+	// CSRFFormField is the form field that can have the CSRF token. It is also read from the query parameters of
+	// WebSocket requests because browsers can't set headers for them
+	CSRFFormField = "csrf_token"
+
+	defaultSessionCookieName = "babyapi_session"
+	defaultSessionMaxAge     = 24 * time.Hour
+
+	maxSessionSweepInterval = time.Minute
+)
+
+// ErrInvalidCSRFToken is returned for POST, PUT, PATCH, and DELETE requests that use a session cookie without a valid
+// CSRF token
+var ErrInvalidCSRFToken = &ErrResponse{HTTPStatusCode: http.StatusForbidden, StatusText: "Invalid CSRF token."}
+
+// csrfProtectedMethods are the methods that modify resources and require a CSRF token
+var csrfProtectedMethods = map[string]bool{
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// Session is stored server-side and identified by a signed cookie. Each request gets its own copy of the session and
+// changes are saved after the request is handled
+type Session struct {
+	DefaultResource
+
+	Values    map[string]string `json:"values"`
+	CSRFToken string            `json:"csrfToken"`
+	Principal *Principal        `json:"principal,omitempty"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+
+	modified bool
+}
+
+// Get returns the session value for the key
+func (s *Session) Get(key string) string {
+	return s.Values[key]
+}
+
+// Set stores the value in the session
+func (s *Session) Set(key, value string) {
+	if s.Values == nil {
+		s.Values = map[string]string{}
+	}
+	s.Values[key] = value
+	s.modified = true
+}
+
+// Delete removes the key from the session
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+	s.modified = true
+}
+
+// SetPrincipal stores the Principal in the session so it is used by SessionAuthenticator. Use RenewSession before
+// setting the Principal after a login
+func (s *Session) SetPrincipal(principal *Principal) {
+	s.Principal = principal
+	s.modified = true
+}
+
+func (s *Session) copy() *Session {
+	result := *s
+	result.Values = maps.Clone(s.Values)
+	result.modified = false
+	return &result
+}
+
+// SessionConfig configures cookie sessions. Zero values use the defaults
+type SessionConfig struct {
+	// Secret is used to sign session cookies. It defaults to a random secret, so sessions end when the server
+	// restarts and can't be shared by multiple instances
+	Secret []byte
+	// Storage is used for sessions. It defaults to an in-memory map. Expired sessions are deleted periodically
+	Storage Storage[*Session]
+	// CookieName defaults to babyapi_session
+	CookieName string
+	// Path is the cookie path. It defaults to /
+	Path string
+	// MaxAge is how long a session lasts after it is created. It defaults to 24h
+	MaxAge time.Duration
+	// Secure only sends the cookie over HTTPS
+	Secure bool
+	// SameSite defaults to http.SameSiteLaxMode
+	SameSite http.SameSite
+	// DisableCSRF turns off CSRF tokens and validation
+	DisableCSRF bool
+}
+
+type sessionManager struct {
+	config SessionConfig
+
+	// lastSweep is the UnixNano time when expired sessions were last deleted
+	lastSweep atomic.Int64
+}
+
+// sessionState is stored in the request context. The session is created the first time it is used so requests that
+// don't need one, like API clients, don't create sessions
+type sessionState struct {
+	manager   *sessionManager
+	w         http.ResponseWriter
+	session   *Session
+	destroyed bool
+}
+
+// EnableSessions adds cookie sessions to the API and its child APIs. Handlers use GetSessionFromContext to read and
+// modify the session. Unless it is disabled, a CSRF token is added to each session and is required for POST, PUT,
+// PATCH, and DELETE requests that use the session cookie. The token is added to HTMLer responses that can send these
+// requests: forms using method="post" or htmx attributes get a hidden csrf_token field and the body's hx-headers
+// attribute gets the X-CSRF-Token header. Sessions are only created for these responses or when a handler uses them
+func (a *API[T]) EnableSessions(config SessionConfig) *API[T] {
+	if len(config.Secret) == 0 {
+		config.Secret = make([]byte, 32)
+		_, err := rand.Read(config.Secret)
+		if err != nil {
+			panic(fmt.Sprintf("error creating session secret: %v", err))
+		}
+	}
+	if config.Storage == nil {
+		config.Storage = newSyncMapStorage[*Session]()
+	}
+	if config.CookieName == "" {
+		config.CookieName = defaultSessionCookieName
+	}
+	if config.Path == "" {
+		config.Path = "/"
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = defaultSessionMaxAge
+	}
+	if config.SameSite == 0 {
+		config.SameSite = http.SameSiteLaxMode
+	}
+
+	manager := &sessionManager{config: config}
+	return a.AddMiddleware(manager.middleware)
+}
+
+func (m *sessionManager) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := &sessionState{manager: m, w: w}
+
+		session, err := m.load(r)
+		if err != nil {
+			logger := GetLoggerFromContext(r.Context())
+			if logger != nil {
+				logger.Info("ignoring session cookie", "error", err)
+			}
+		}
+
+		if session != nil {
+			state.session = session.copy()
+
+			// CSRF attacks rely on the browser sending the session cookie, so requests without a session don't need
+			// a token
+			if !m.config.DisableCSRF && csrfProtectedMethods[r.Method] && !validCSRFToken(r, session.CSRFToken) {
+				_ = Render(w, r, ErrInvalidCSRFToken)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionCtxKey, state)))
+
+		if state.session == nil || state.destroyed || !state.session.modified {
+			return
+		}
+
+		err = m.config.Storage.Set(state.session)
+		if err != nil {
+			slog.Default().Error("error saving session", "error", err)
+		}
+	})
+}
+
+// load reads the session for the request's cookie. It returns nil if there is no cookie or the session expired
+func (m *sessionManager) load(r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(m.config.CookieName)
+	if err != nil {
+		return nil, nil
+	}
+
+	id, ok := m.verify(cookie.Value)
+	if !ok {
+		return nil, errors.New("invalid signature")
+	}
+
+	session, err := m.config.Storage.Get(id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting session: %w", err)
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		err = m.config.Storage.Delete(id)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("error deleting expired session: %w", err)
+		}
+		return nil, nil
+	}
+
+	return session, nil
+}
+
+// sweep deletes expired sessions in the background so sessions with cookies that are never used again don't stay in
+// storage. It runs when sessions are created and at most once per MaxAge or minute
+func (m *sessionManager) sweep() {
+	now := time.Now()
+	last := m.lastSweep.Load()
+	if now.Sub(time.Unix(0, last)) < min(m.config.MaxAge, maxSessionSweepInterval) {
+		return
+	}
+	if !m.lastSweep.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	go func() {
+		expired, err := m.config.Storage.GetAll(func(session *Session) bool {
+			return now.After(session.ExpiresAt)
+		})
+		if err != nil {
+			slog.Default().Error("error getting expired sessions", "error", err)
+			return
+		}
+
+		for _, session := range expired {
+			err = m.config.Storage.Delete(session.GetID())
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				slog.Default().Error("error deleting expired session", "error", err)
+			}
+		}
+	}()
+}
+
+func (m *sessionManager) newSession() *Session {
+	return &Session{
+		DefaultResource: NewDefaultResource(),
+		Values:          map[string]string{},
+		CSRFToken:       newCSRFToken(),
+		ExpiresAt:       time.Now().Add(m.config.MaxAge),
+		modified:        true,
+	}
+}
+
+func (m *sessionManager) setCookie(w http.ResponseWriter, session *Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.config.CookieName,
+		Value:    m.sign(session.GetID()),
+		Path:     m.config.Path,
+		Expires:  session.ExpiresAt,
+		MaxAge:   int(time.Until(session.ExpiresAt).Seconds()),
+		Secure:   m.config.Secure,
+		HttpOnly: true,
+		SameSite: m.config.SameSite,
+	})
+}
+
+func (m *sessionManager) sign(id string) string {
+	mac := hmac.New(sha256.New, m.config.Secret)
+	mac.Write([]byte(id))
+	return id + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (m *sessionManager) verify(value string) (string, bool) {
+	id, _, ok := strings.Cut(value, ".")
+	if !ok {
+		return "", false
+	}
+	return id, hmac.Equal([]byte(value), []byte(m.sign(id)))
+}
+
+// get returns the session and creates it if it doesn't exist. The cookie can only be set before the response is
+// written
+func (s *sessionState) get() *Session {
+	if s.session == nil {
+		s.manager.sweep()
+		s.session = s.manager.newSession()
+		s.destroyed = false
+		s.manager.setCookie(s.w, s.session)
+	}
+	return s.session
+}
+
+func getSessionState(ctx context.Context) *sessionState {
+	state, _ := ctx.Value(sessionCtxKey).(*sessionState)
+	return state
+}
+
+// GetSessionFromContext returns the request's session and creates one if it doesn't exist yet. It returns nil if the
+// API doesn't use sessions. New sessions set a cookie, so this has to be used before writing the response
+func GetSessionFromContext(ctx context.Context) *Session {
+	state := getSessionState(ctx)
+	if state == nil {
+		return nil
+	}
+	return state.get()
+}
+
+// GetCSRFTokenFromContext returns the session's CSRF token. It is empty if the API doesn't use sessions or CSRF is
+// disabled
+func GetCSRFTokenFromContext(ctx context.Context) string {
+	state := getSessionState(ctx)
+	if state == nil || state.manager.config.DisableCSRF {
+		return ""
+	}
+	return state.get().CSRFToken
+}
+
+// CSRFField returns a hidden form input with the CSRF token for use in templates
+func CSRFField(r *http.Request) template.HTML {
+	token := GetCSRFTokenFromContext(r.Context())
+	if token == "" {
+		return ""
+	}
+	return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`, CSRFFormField, token))
+}
+
+// RenewSession replaces the session ID and CSRF token while keeping the values. Use it after a login to prevent session
+// fixation
+func RenewSession(ctx context.Context) (*Session, error) {
+	state := getSessionState(ctx)
+	if state == nil {
+		return nil, errors.New("sessions are not enabled")
+	}
+
+	previous := state.session
+	err := DestroySession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	session := state.get()
+	if previous != nil {
+		session.Values = previous.Values
+		session.Principal = previous.Principal
+	}
+
+	return session, nil
+}
+
+// DestroySession deletes the session and clears the cookie. Use it for logouts
+func DestroySession(ctx context.Context) error {
+	state := getSessionState(ctx)
+	if state == nil {
+		return errors.New("sessions are not enabled")
+	}
+	if state.session == nil {
+		return nil
+	}
+
+	err := state.manager.config.Storage.Delete(state.session.GetID())
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("error deleting session: %w", err)
+	}
+
+	http.SetCookie(state.w, &http.Cookie{
+		Name:     state.manager.config.CookieName,
+		Path:     state.manager.config.Path,
+		MaxAge:   -1,
+		Secure:   state.manager.config.Secure,
+		HttpOnly: true,
+		SameSite: state.manager.config.SameSite,
+	})
+
+	state.session = nil
+	state.destroyed = true
+	return nil
+}
+
+// SessionAuthenticator uses the Principal stored in the session with SetPrincipal. EnableSessions must be used before
+// SetAuthenticators so the session is available
+var SessionAuthenticator = AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+	state := getSessionState(r.Context())
+	if state == nil || state.session == nil || state.session.Principal == nil {
+		return nil, ErrNoCredentials
+	}
+
+	principal := *state.session.Principal
+	principal.Method = "session"
+	return &principal, nil
+})
+
+func newCSRFToken() string {
+	token := make([]byte, 32)
+	_, err := rand.Read(token)
+	if err != nil {
+		panic(fmt.Sprintf("error creating CSRF token: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// validCSRFToken checks the token from the header or form field. The body is read to get the form field, so it is
+// restored for the handler
+func validCSRFToken(r *http.Request, expected string) bool {
+	token := r.Header.Get(CSRFHeader)
+	if token == "" {
+		token = csrfFormValue(r)
+	}
+
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// csrfFormValue reads the token from a form body using a copy of the request so the body can still be used by the
+// handler
+func csrfFormValue(r *http.Request) string {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/x-www-form-urlencoded" && mediaType != "multipart/form-data" {
+		return ""
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxDecodedBodySize))
+	if err != nil {
+		return ""
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	form := r.Clone(r.Context())
+	form.Body = io.NopCloser(bytes.NewReader(body))
+	defer func() {
+		if form.MultipartForm != nil {
+			_ = form.MultipartForm.RemoveAll()
+		}
+	}()
+
+	return form.PostFormValue(CSRFFormField)
+}
+
+var (
+	// csrfFormTag matches forms that send requests which need a CSRF token, using the method or htmx attributes
+	csrfFormTag = regexp.MustCompile(`(?i)<form\b[^>]*\b(?:method\s*=\s*["']?post\b|hx-(?:post|put|patch|delete)\s*=)[^>]*>`)
+	// htmxRequestAttr matches elements that use htmx to send requests which need a CSRF token
+	htmxRequestAttr = regexp.MustCompile(`(?i)\bhx-(?:post|put|patch|delete)\s*=`)
+	bodyTag         = regexp.MustCompile(`(?i)<body\b[^>]*>`)
+	hxHeadersAttr   = regexp.MustCompile(`(?i)\bhx-headers\s*=\s*'([^']*)'`)
+)
+
+// injectCSRFToken adds the CSRF token to forms that send POST, PUT, PATCH, or DELETE requests and adds it to the body's
+// hx-headers so all htmx requests send it. Forms are not changed if the HTML already uses the token. The session is
+// only created if the HTML has forms or htmx attributes that need the token
+func injectCSRFToken(r *http.Request, html string) string {
+	state := getSessionState(r.Context())
+	if state == nil || state.manager.config.DisableCSRF {
+		return html
+	}
+
+	if !csrfFormTag.MatchString(html) && !htmxRequestAttr.MatchString(html) {
+		return html
+	}
+
+	token := state.get().CSRFToken
+
+	if !strings.Contains(html, token) {
+		field := string(CSRFField(r))
+		html = csrfFormTag.ReplaceAllStringFunc(html, func(form string) string {
+			return form + field
+		})
+	}
+
+	return bodyTag.ReplaceAllStringFunc(html, func(body string) string {
+		return addCSRFHeader(body, token)
+	})
+}
+
+// addCSRFHeader adds the token to the body tag's hx-headers. Existing headers are kept. htmx merges hx-headers from
+// parent elements, so elements with their own hx-headers also send the token
+func addCSRFHeader(body, token string) string {
+	match := hxHeadersAttr.FindStringSubmatchIndex(body)
+	if match == nil {
+		return body[:len("<body")] + fmt.Sprintf(` hx-headers='{"%s": "%s"}'`, CSRFHeader, token) + body[len("<body"):]
+	}
+
+	headers := map[string]any{}
+	err := json.Unmarshal([]byte(body[match[2]:match[3]]), &headers)
+	if err != nil {
+		return body
+	}
+	headers[CSRFHeader] = token
+
+	data, err := json.Marshal(headers)
+	if err != nil {
+		return body
+	}
+
+	return body[:match[2]] + string(data) + body[match[3]:]
+}
@@ -0,0 +1,98 @@
+package babyapi
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// exampleHTML reads the HTML templates used by the htmx examples
+func exampleHTML(t *testing.T) map[string]string {
+	t.Helper()
+
+	eventRSVP, err := os.ReadFile("examples/event-rsvp/template.html")
+	require.NoError(t, err)
+
+	file, err := parser.ParseFile(token.NewFileSet(), "examples/todo-htmx/main.go", nil, 0)
+	require.NoError(t, err)
+
+	var todoHTMX string
+	ast.Inspect(file, func(n ast.Node) bool {
+		lit, ok := n.(*ast.BasicLit)
+		if ok && lit.Kind == token.STRING && strings.Contains(lit.Value, "<body") {
+			todoHTMX, err = strconv.Unquote(lit.Value)
+			require.NoError(t, err)
+		}
+		return true
+	})
+	require.NotEmpty(t, todoHTMX)
+
+	return map[string]string{
+		"EventRSVP": string(eventRSVP),
+		"TodoHTMX":  todoHTMX,
+	}
+}
+
+func TestInjectCSRFTokenExamples(t *testing.T) {
+	for name, html := range exampleHTML(t) {
+		t.Run(name, func(t *testing.T) {
+			manager := &sessionManager{config: SessionConfig{
+				Secret:  []byte("secret"),
+				Storage: newSyncMapStorage[*Session](),
+				MaxAge:  defaultSessionMaxAge,
+			}}
+			state := &sessionState{manager: manager, w: httptest.NewRecorder()}
+
+			r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			r = r.WithContext(context.WithValue(r.Context(), sessionCtxKey, state))
+
+			result := injectCSRFToken(r, html)
+			require.NotNil(t, state.session)
+			token := state.session.CSRFToken
+
+			field := string(CSRFField(r))
+			forms := csrfFormTag.FindAllString(html, -1)
+			require.NotEmpty(t, forms)
+			for _, form := range forms {
+				require.Contains(t, form, "hx-post")
+				require.Contains(t, result, form+field)
+			}
+
+			// The body gets the token even when other elements use hx-headers, like the event-rsvp forms
+			require.Contains(t, result, `<body hx-headers='{"X-CSRF-Token": "`+token+`"}'>`)
+		})
+	}
+}
+
+func TestSessionSweep(t *testing.T) {
+	storage := newSyncMapStorage[*Session]()
+	manager := &sessionManager{config: SessionConfig{
+		Secret:  []byte("secret"),
+		Storage: storage,
+		MaxAge:  time.Millisecond,
+	}}
+
+	expired := manager.newSession()
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	require.NoError(t, storage.Set(expired))
+
+	// Creating a session deletes expired sessions in the background
+	state := &sessionState{manager: manager, w: httptest.NewRecorder()}
+	state.get()
+
+	require.Eventually(t, func() bool {
+		_, err := storage.Get(expired.GetID())
+		return errors.Is(err, ErrNotFound)
+	}, time.Second, 10*time.Millisecond)
+}
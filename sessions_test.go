@@ -0,0 +1,344 @@
+package babyapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+type sessionPage struct {
+	babyapi.DefaultRenderer
+	html string
+}
+
+func (p *sessionPage) HTML(*http.Request) string {
+	return p.html
+}
+
+func sessionPageRoute(pattern, html string) chi.Route {
+	return chi.Route{
+		Pattern: pattern,
+		Handlers: map[string]http.Handler{
+			http.MethodGet: babyapi.Handler(func(http.ResponseWriter, *http.Request) render.Renderer {
+				return &sessionPage{html: html}
+			}),
+		},
+	}
+}
+
+var csrfFieldRegexp = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+func TestSessions(t *testing.T) {
+	api := babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} })
+	api.EnableSessions(babyapi.SessionConfig{Secret: []byte("secret")}).EnableWebSocket()
+	api.AddCustomRoute(sessionPageRoute(
+		"/page",
+		`<html><body><form method="post" action="/memos/counter"></form><form hx-get="/memos"></form></body></html>`,
+	))
+	api.AddCustomRoute(sessionPageRoute(
+		"/htmx",
+		`<html><body hx-headers='{"Accept": "text/html"}'><button hx-delete="/memos/1">Delete</button></body></html>`,
+	))
+	api.AddCustomRoute(sessionPageRoute("/static", `<html><body><a href="/memos">Memos</a></body></html>`))
+	api.AddCustomRoute(chi.Route{
+		Pattern: "/echo",
+		Handlers: map[string]http.Handler{
+			http.MethodPost: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = fmt.Fprint(w, r.PostFormValue("text"))
+			}),
+		},
+	})
+	api.AddCustomRoute(chi.Route{
+		Pattern: "/counter",
+		Handlers: map[string]http.Handler{
+			http.MethodPost: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				session := babyapi.GetSessionFromContext(r.Context())
+				count, _ := strconv.Atoi(session.Get("count"))
+				session.Set("count", strconv.Itoa(count+1))
+				_, _ = fmt.Fprint(w, count+1)
+			}),
+		},
+	})
+
+	address, stop := babytest.TestServe[*Memo](t, api)
+	defer stop()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	browser := &http.Client{Jar: jar}
+
+	getPage := func(t *testing.T, client *http.Client, path string) (*http.Response, string) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, address+path, http.NoBody)
+		require.NoError(t, err)
+		req.Header.Set("Accept", "text/html")
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(body)
+	}
+
+	t.Run("SessionIsNotCreatedWithoutForms", func(t *testing.T) {
+		resp, body := getPage(t, http.DefaultClient, "/memos/static")
+		require.Empty(t, resp.Header.Get("Set-Cookie"))
+		require.Equal(t, `<html><body><a href="/memos">Memos</a></body></html>`, body)
+	})
+
+	t.Run("TokenIsAddedToExistingHXHeaders", func(t *testing.T) {
+		resp, body := getPage(t, http.DefaultClient, "/memos/htmx")
+		require.Contains(t, resp.Header.Get("Set-Cookie"), "babyapi_session=")
+
+		matches := regexp.MustCompile(`<body hx-headers='([^']*)'>`).FindStringSubmatch(body)
+		require.Len(t, matches, 2)
+
+		var headers map[string]string
+		require.NoError(t, json.Unmarshal([]byte(matches[1]), &headers))
+		require.Equal(t, "text/html", headers["Accept"])
+		require.NotEmpty(t, headers[babyapi.CSRFHeader])
+	})
+
+	var token string
+	t.Run("TokenIsInjectedIntoHTML", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, address+"/memos/page", http.NoBody)
+		require.NoError(t, err)
+		req.Header.Set("Accept", "text/html")
+
+		resp, err := browser.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		cookie := resp.Header.Get("Set-Cookie")
+		require.Contains(t, cookie, "babyapi_session=")
+		require.Contains(t, cookie, "HttpOnly")
+		require.Contains(t, cookie, "SameSite=Lax")
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		matches := csrfFieldRegexp.FindAllStringSubmatch(string(body), -1)
+		require.Len(t, matches, 1, "only the POST form has the field")
+		token = matches[0][1]
+		require.Contains(t, string(body), fmt.Sprintf(`<body hx-headers='{"X-CSRF-Token": "%s"}'>`, token))
+	})
+
+	postCounter := func(t *testing.T, form url.Values, header http.Header) (int, string) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, address+"/memos/counter", strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for key, values := range header {
+			req.Header[key] = values
+		}
+
+		resp, err := browser.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	t.Run("CSRFTokenIsRequired", func(t *testing.T) {
+		status, _ := postCounter(t, url.Values{}, nil)
+		require.Equal(t, http.StatusForbidden, status)
+
+		status, _ = postCounter(t, url.Values{"csrf_token": {"wrong"}}, nil)
+		require.Equal(t, http.StatusForbidden, status)
+	})
+
+	t.Run("SessionValuesArePersisted", func(t *testing.T) {
+		status, body := postCounter(t, url.Values{"csrf_token": {token}}, nil)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "1", body)
+
+		status, body = postCounter(t, nil, http.Header{babyapi.CSRFHeader: {token}})
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "2", body)
+	})
+
+	t.Run("FormBodyIsAvailableToHandler", func(t *testing.T) {
+		form := url.Values{"csrf_token": {token}, "text": {"hello"}}
+		req, err := http.NewRequest(http.MethodPost, address+"/memos/echo", strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := browser.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "hello", string(body))
+	})
+
+	t.Run("RequestsWithoutSessionDoNotNeedToken", func(t *testing.T) {
+		client := api.Client(address)
+
+		resp, err := client.Post(context.Background(), &Memo{Text: "no session"})
+		require.NoError(t, err)
+		require.Empty(t, resp.Response.Header.Get("Set-Cookie"))
+	})
+
+	t.Run("InvalidCookieIsIgnored", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, address+"/memos/counter", http.NoBody)
+		require.NoError(t, err)
+
+		cookies := jar.Cookies(req.URL)
+		require.Len(t, cookies, 1)
+		req.AddCookie(&http.Cookie{Name: "babyapi_session", Value: cookies[0].Value + "x"})
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "1", string(body), "a new session is used")
+	})
+
+	t.Run("WebSocketCommandsUseQueryToken", func(t *testing.T) {
+		wsAddress := "ws" + strings.TrimPrefix(address, "http") + "/memos/ws"
+		serverURL, err := url.Parse(address)
+		require.NoError(t, err)
+		header := http.Header{"Cookie": {"babyapi_session=" + jar.Cookies(serverURL)[0].Value}}
+
+		for _, tt := range []struct {
+			query          string
+			expectedStatus int
+		}{
+			{"", http.StatusForbidden},
+			{"?csrf_token=" + token, http.StatusCreated},
+		} {
+			conn, _, err := websocket.DefaultDialer.Dial(wsAddress+tt.query, header)
+			require.NoError(t, err)
+
+			require.NoError(t, conn.WriteJSON(babyapi.WebSocketMessage{
+				Type:   babyapi.WebSocketMessageCommand,
+				ID:     "create",
+				Action: babyapi.WebSocketActionCreate,
+				Data:   json.RawMessage(`{"text": "hello"}`),
+			}))
+
+			response := readWebSocketMessages(t, conn, 1)[babyapi.WebSocketMessageResponse]
+			require.Equal(t, tt.expectedStatus, response.Status)
+			require.NoError(t, conn.Close())
+		}
+	})
+}
+
+func TestSessionLogin(t *testing.T) {
+	api := babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} })
+	api.EnableSessions(babyapi.SessionConfig{DisableCSRF: true})
+	api.AddCustomRoute(chi.Route{
+		Pattern: "/login",
+		Handlers: map[string]http.Handler{
+			http.MethodPost: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				session, err := babyapi.RenewSession(r.Context())
+				require.NoError(t, err)
+				session.SetPrincipal(&babyapi.Principal{ID: r.URL.Query().Get("user")})
+			}),
+		},
+	})
+	api.AddCustomRoute(chi.Route{
+		Pattern: "/logout",
+		Handlers: map[string]http.Handler{
+			http.MethodPost: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.NoError(t, babyapi.DestroySession(r.Context()))
+			}),
+		},
+	})
+	api.AddCustomRoute(chi.Route{
+		Pattern: "/me",
+		Handlers: map[string]http.Handler{
+			http.MethodGet: babyapi.AuthenticationMiddleware(babyapi.SessionAuthenticator)(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					principal := babyapi.GetPrincipalFromContext(r.Context())
+					_, _ = fmt.Fprintf(w, "%s:%s", principal.Method, principal.ID)
+				}),
+			),
+		},
+	})
+
+	address, stop := babytest.TestServe[*Memo](t, api)
+	defer stop()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	browser := &http.Client{Jar: jar}
+	serverURL, err := url.Parse(address)
+	require.NoError(t, err)
+
+	request := func(t *testing.T, method, path string) (int, string) {
+		t.Helper()
+
+		req, err := http.NewRequest(method, address+path, http.NoBody)
+		require.NoError(t, err)
+
+		resp, err := browser.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	status, _ := request(t, http.MethodGet, "/memos/me")
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = request(t, http.MethodPost, "/memos/login?user=alice")
+	require.Equal(t, http.StatusOK, status)
+	firstCookie := jar.Cookies(serverURL)[0].Value
+
+	status, body := request(t, http.MethodGet, "/memos/me")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "session:alice", body)
+
+	t.Run("LoginRenewsSessionID", func(t *testing.T) {
+		status, _ := request(t, http.MethodPost, "/memos/login?user=bob")
+		require.Equal(t, http.StatusOK, status)
+		require.NotEqual(t, firstCookie, jar.Cookies(serverURL)[0].Value)
+
+		// The old session no longer works
+		req, err := http.NewRequest(http.MethodGet, address+"/memos/me", http.NoBody)
+		require.NoError(t, err)
+		req.AddCookie(&http.Cookie{Name: "babyapi_session", Value: firstCookie})
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Logout", func(t *testing.T) {
+		status, _ := request(t, http.MethodPost, "/memos/logout")
+		require.Equal(t, http.StatusOK, status)
+		require.Empty(t, jar.Cookies(serverURL))
+
+		status, _ = request(t, http.MethodGet, "/memos/me")
+		require.Equal(t, http.StatusUnauthorized, status)
+	})
+}
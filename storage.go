@@ -2,6 +2,7 @@ package babyapi
 
 import (
 	"errors"
+	"sync"
 
 	"golang.org/x/exp/maps"
 )
@@ -61,3 +62,38 @@ func (m MapStorage[T]) Delete(id string) error {
 	delete(m, id)
 	return nil
 }
+
+// syncMapStorage is a MapStorage that is safe for concurrent use. It is used by default for storage that is
+// accessed by background workers in addition to HTTP handlers
+type syncMapStorage[T Resource] struct {
+	m   MapStorage[T]
+	mtx sync.RWMutex
+}
+
+func newSyncMapStorage[T Resource]() *syncMapStorage[T] {
+	return &syncMapStorage[T]{m: MapStorage[T]{}}
+}
+
+func (s *syncMapStorage[T]) Get(id string) (T, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.m.Get(id)
+}
+
+func (s *syncMapStorage[T]) GetAll(filter FilterFunc[T]) ([]T, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.m.GetAll(filter)
+}
+
+func (s *syncMapStorage[T]) Set(resource T) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.m.Set(resource)
+}
+
+func (s *syncMapStorage[T]) Delete(id string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.m.Delete(id)
+}
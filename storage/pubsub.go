@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/FZambia/sentinel"
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/madflojo/hord/drivers/redis"
+)
+
+const redisResubscribeDelay = time.Second
+
+// RedisPubSub implements babyapi.PubSub using Redis PUBLISH and SUBSCRIBE so BroadcastChannels in different instances
+// of an application share values
+type RedisPubSub struct {
+	pool *redigo.Pool
+}
+
+// NewRedisPubSub connects to Redis using the same configuration as NewRedisDB
+func NewRedisPubSub(cfg redis.Config) (*RedisPubSub, error) {
+	if cfg.Server == "" && len(cfg.SentinelConfig.Servers) == 0 {
+		return nil, errors.New("must specify either a Redis Server or Sentinel Pool")
+	}
+	if len(cfg.SentinelConfig.Servers) > 0 && cfg.SentinelConfig.Master == "" {
+		return nil, errors.New("if using Sentinel the Redis Master must be defined")
+	}
+
+	opts := []redigo.DialOption{
+		redigo.DialConnectTimeout(cfg.ConnectTimeout),
+		redigo.DialDatabase(cfg.Database),
+		redigo.DialKeepAlive(cfg.KeepAlive),
+		redigo.DialPassword(cfg.Password),
+		redigo.DialWriteTimeout(cfg.WriteTimeout),
+	}
+	if cfg.TLSConfig != nil {
+		opts = append(opts,
+			redigo.DialUseTLS(true),
+			redigo.DialTLSConfig(cfg.TLSConfig),
+			redigo.DialTLSSkipVerify(cfg.SkipTLSVerify),
+		)
+	}
+
+	var sntnl *sentinel.Sentinel
+	if len(cfg.SentinelConfig.Servers) > 0 {
+		sntnl = &sentinel.Sentinel{
+			Addrs:      cfg.SentinelConfig.Servers,
+			MasterName: cfg.SentinelConfig.Master,
+			Dial: func(addr string) (redigo.Conn, error) {
+				return redigo.Dial("tcp", addr, opts...)
+			},
+		}
+	}
+
+	// ReadTimeout is not used because subscriptions wait for messages without a deadline
+	pool := &redigo.Pool{
+		IdleTimeout:     cfg.IdleTimeout,
+		MaxActive:       cfg.MaxActive,
+		MaxConnLifetime: cfg.MaxConnLifetime,
+		MaxIdle:         cfg.MaxIdle,
+		Wait:            true,
+		Dial: func() (redigo.Conn, error) {
+			server := cfg.Server
+			if sntnl != nil {
+				var err error
+				server, err = sntnl.MasterAddr()
+				if err != nil {
+					return nil, err
+				}
+			}
+			return redigo.Dial("tcp", server, opts...)
+		},
+	}
+
+	conn := pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("PING")
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to redis: %w", err)
+	}
+
+	return &RedisPubSub{pool}, nil
+}
+
+// Publish sends the message to the Redis channel named by the topic
+func (ps *RedisPubSub) Publish(ctx context.Context, topic string, message []byte) error {
+	conn, err := ps.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting redis connection: %w", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("PUBLISH", topic, message)
+	if err != nil {
+		return fmt.Errorf("error publishing message: %w", err)
+	}
+
+	return nil
+}
+
+// Subscribe subscribes to the Redis channel named by the topic. If the connection fails after subscribing, it
+// resubscribes until the context is done. Messages published while disconnected are lost
+func (ps *RedisPubSub) Subscribe(ctx context.Context, topic string, handler func([]byte)) error {
+	psc, err := ps.subscribe(ctx, topic)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			err := ps.receive(ctx, psc, handler)
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Default().Error("error receiving from redis", "error", err, "topic", topic)
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(redisResubscribeDelay):
+				}
+
+				psc, err = ps.subscribe(ctx, topic)
+				if err == nil {
+					break
+				}
+				slog.Default().Error("error resubscribing to redis", "error", err, "topic", topic)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (ps *RedisPubSub) subscribe(ctx context.Context, topic string) (*redigo.PubSubConn, error) {
+	conn, err := ps.pool.GetContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting redis connection: %w", err)
+	}
+
+	psc := &redigo.PubSubConn{Conn: conn}
+	err = psc.Subscribe(topic)
+	if err != nil {
+		psc.Close()
+		return nil, fmt.Errorf("error subscribing: %w", err)
+	}
+
+	return psc, nil
+}
+
+// receive calls the handler for each message until the context is done or there is an error
+func (ps *RedisPubSub) receive(ctx context.Context, psc *redigo.PubSubConn, handler func([]byte)) error {
+	defer psc.Close()
+
+	for {
+		switch v := psc.ReceiveContext(ctx).(type) {
+		case redigo.Message:
+			handler(v.Data)
+		case error:
+			return v
+		}
+	}
+}
+
+// Close closes the connection pool
+func (ps *RedisPubSub) Close() error {
+	return ps.pool.Close()
+}
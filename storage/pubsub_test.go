@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/madflojo/hord/drivers/redis"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRedisPubSubConfigErrors(t *testing.T) {
+	_, err := NewRedisPubSub(redis.Config{})
+	require.EqualError(t, err, "must specify either a Redis Server or Sentinel Pool")
+
+	_, err = NewRedisPubSub(redis.Config{SentinelConfig: redis.SentinelConfig{Servers: []string{"localhost:26379"}}})
+	require.EqualError(t, err, "if using Sentinel the Redis Master must be defined")
+}
@@ -0,0 +1,60 @@
+package babytest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/calvinmclean/babyapi"
+)
+
+// RequireEvent waits for the next event from a Client.Listen channel and fails the test if it doesn't arrive
+// within the timeout or the channel is closed
+func RequireEvent[T any](t *testing.T, events <-chan *babyapi.ListenEvent[T], timeout time.Duration) *babyapi.ListenEvent[T] {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("event channel closed before receiving an event")
+		}
+		return event
+	case <-time.After(timeout):
+		t.Fatalf("timed out after %s waiting for event", timeout)
+		return nil
+	}
+}
+
+// RequireEventMatching waits for an event that matches the function and fails the test if it doesn't arrive within
+// the timeout. Events that don't match are skipped
+func RequireEventMatching[T any](t *testing.T, events <-chan *babyapi.ListenEvent[T], timeout time.Duration, match func(*babyapi.ListenEvent[T]) bool) *babyapi.ListenEvent[T] {
+	t.Helper()
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatal("event channel closed before receiving a matching event")
+			}
+			if match(event) {
+				return event
+			}
+		case <-deadline:
+			t.Fatalf("timed out after %s waiting for matching event", timeout)
+			return nil
+		}
+	}
+}
+
+// RequireNoEvent fails the test if an event is received before the duration passes
+func RequireNoEvent[T any](t *testing.T, events <-chan *babyapi.ListenEvent[T], duration time.Duration) {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		if ok {
+			t.Fatalf("unexpected event %q with data %q", event.Event, event.RawData)
+		}
+	case <-time.After(duration):
+	}
+}
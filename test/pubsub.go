@@ -0,0 +1,101 @@
+package babytest
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/calvinmclean/babyapi"
+)
+
+// PubSub is a babyapi.PubSub test double. APIs using the same PubSub in one test behave like multiple instances of an
+// application sharing an external PubSub. It records published messages and active subscriptions and can simulate
+// publishing errors
+type PubSub struct {
+	*babyapi.MemoryPubSub
+
+	lock        sync.Mutex
+	published   map[string][][]byte
+	subscribers map[string]int
+	publishErr  error
+}
+
+// NewPubSub creates a PubSub test double
+func NewPubSub() *PubSub {
+	return &PubSub{
+		MemoryPubSub: babyapi.NewMemoryPubSub(),
+		published:    map[string][][]byte{},
+		subscribers:  map[string]int{},
+	}
+}
+
+// Subscribe adds the handler for the topic and counts the subscription until the context is done
+func (ps *PubSub) Subscribe(ctx context.Context, topic string, handler func([]byte)) error {
+	err := ps.MemoryPubSub.Subscribe(ctx, topic, handler)
+	if err != nil {
+		return err
+	}
+
+	ps.lock.Lock()
+	ps.subscribers[topic]++
+	ps.lock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		ps.lock.Lock()
+		defer ps.lock.Unlock()
+		ps.subscribers[topic]--
+	}()
+
+	return nil
+}
+
+// Subscribers returns the number of active subscriptions to the topic
+func (ps *PubSub) Subscribers(topic string) int {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	return ps.subscribers[topic]
+}
+
+// Publish records the message and sends it to subscribers. If an error is set with SetPublishError, it is returned
+// and the message is not recorded or sent
+func (ps *PubSub) Publish(ctx context.Context, topic string, message []byte) error {
+	ps.lock.Lock()
+	if ps.publishErr != nil {
+		ps.lock.Unlock()
+		return ps.publishErr
+	}
+	ps.published[topic] = append(ps.published[topic], slices.Clone(message))
+	ps.lock.Unlock()
+
+	return ps.MemoryPubSub.Publish(ctx, topic, message)
+}
+
+// SetPublishError sets the error returned by Publish. Use nil to publish normally again
+func (ps *PubSub) SetPublishError(err error) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	ps.publishErr = err
+}
+
+// Published returns the messages published to the topic
+func (ps *PubSub) Published(topic string) [][]byte {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	return slices.Clone(ps.published[topic])
+}
+
+// Topics returns the topics that have published messages
+func (ps *PubSub) Topics() []string {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	topics := make([]string, 0, len(ps.published))
+	for topic := range ps.published {
+		topics = append(topics, topic)
+	}
+	slices.Sort(topics)
+
+	return topics
+}
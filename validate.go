@@ -0,0 +1,420 @@
+package babyapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const validateTag = "validate"
+
+// FieldError describes a single field that failed validation. Field is the JSON path to the field
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (fe FieldError) String() string {
+	return fmt.Sprintf("%s: %s", fe.Field, fe.Message)
+}
+
+// ErrValidation creates a 400 response that lists all of the fields that failed validation
+func ErrValidation(fieldErrors []FieldError) *ErrResponse {
+	messages := make([]string, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		messages = append(messages, fe.String())
+	}
+
+	return &ErrResponse{
+		Err:              fmt.Errorf("validation failed: %s", strings.Join(messages, "; ")),
+		HTTPStatusCode:   http.StatusBadRequest,
+		StatusText:       "Invalid request.",
+		ErrorText:        strings.Join(messages, "; "),
+		ValidationErrors: fieldErrors,
+	}
+}
+
+// validateRequestBody validates the resource using the rules from its "validate" struct tags. PATCH requests only
+// contain the fields that are changing, so the required rule is skipped and zero-valued fields are ignored
+func validateRequestBody(r *http.Request, resource any) *ErrResponse {
+	return validateResource(resource, r.Method == http.MethodPatch)
+}
+
+// validateResource checks all "validate" struct tags and returns an ErrResponse with every failing field. The
+// supported rules are required, min, max, oneof, and email. Nested structs and slices of structs are also validated
+func validateResource(resource any, partial bool) *ErrResponse {
+	fieldErrors, err := validateValue(reflect.ValueOf(resource), "", partial)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return ErrValidation(fieldErrors)
+	}
+
+	return nil
+}
+
+// validationRule is a single parsed rule from a "validate" struct tag
+type validationRule struct {
+	name    string
+	param   string
+	limit   float64
+	options []string
+}
+
+// validatedField is a struct field with its parsed rules. Embedded fields have their fields validated as if they
+// were part of the parent struct
+type validatedField struct {
+	index    int
+	name     string
+	embedded bool
+	rules    []validationRule
+}
+
+type structValidation struct {
+	fields []validatedField
+	err    error
+}
+
+// validationCache stores the parsed "validate" tags for each struct type so they are only parsed once
+var validationCache sync.Map
+
+// parseValidateTags parses the "validate" tags of the type and all struct types it contains. NewAPI uses this so
+// invalid tags are found when the API is created instead of returning errors for every request. Types that are only
+// known at runtime, like values in interface fields, are parsed when they are first validated
+func parseValidateTags(t reflect.Type) error {
+	return parseValidateTagsRecursive(t, map[reflect.Type]bool{})
+}
+
+func parseValidateTagsRecursive(t reflect.Type, seen map[reflect.Type]bool) error {
+	t = derefType(t)
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return parseValidateTagsRecursive(t.Elem(), seen)
+	case reflect.Struct:
+	default:
+		return nil
+	}
+
+	if !canValidateFields(t) {
+		return nil
+	}
+
+	validation := structFields(t)
+	if validation.err != nil {
+		return validation.err
+	}
+
+	for _, f := range validation.fields {
+		err := parseValidateTagsRecursive(t.Field(f.index).Type, seen)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// structFields gets the fields and parsed rules for a struct type from the cache, or parses them
+func structFields(t reflect.Type) structValidation {
+	cached, ok := validationCache.Load(t)
+	if ok {
+		return cached.(structValidation)
+	}
+
+	fields, err := parseStructFields(t)
+	validation := structValidation{fields, err}
+	validationCache.Store(t, validation)
+
+	return validation
+}
+
+func parseStructFields(t reflect.Type) ([]validatedField, error) {
+	var result []validatedField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		jsonName, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if jsonName == "-" {
+			continue
+		}
+
+		if f.Anonymous && jsonName == "" {
+			result = append(result, validatedField{index: i, embedded: true})
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if jsonName == "" {
+			jsonName = f.Name
+		}
+
+		rules, err := parseRules(f.Type, f.Tag.Get(validateTag))
+		if err != nil {
+			return nil, fmt.Errorf("invalid validate tag on field %s.%s: %w", t.String(), f.Name, err)
+		}
+
+		result = append(result, validatedField{index: i, name: jsonName, rules: rules})
+	}
+
+	return result, nil
+}
+
+// parseRules parses the tag and makes sure each rule can be used with the field's type. Interface fields are
+// checked when they are validated since the type of the value isn't known yet
+func parseRules(fieldType reflect.Type, tag string) ([]validationRule, error) {
+	if tag == "" {
+		return nil, nil
+	}
+
+	kind := derefType(fieldType).Kind()
+
+	var result []validationRule
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		parsed := validationRule{name: name, param: param}
+
+		switch name {
+		case "required":
+		case "min", "max":
+			limit, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s parameter %q: %w", name, param, err)
+			}
+			parsed.limit = limit
+
+			if kind != reflect.Interface && !hasRuleSize(kind) {
+				return nil, fmt.Errorf("%s is not supported for %s", name, kind)
+			}
+		case "oneof":
+			parsed.options = strings.Fields(param)
+		case "email":
+			if kind != reflect.Interface && kind != reflect.String {
+				return nil, fmt.Errorf("email is not supported for %s", kind)
+			}
+		default:
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+
+		result = append(result, parsed)
+	}
+
+	return result, nil
+}
+
+func validateValue(v reflect.Value, path string, partial bool) ([]FieldError, error) {
+	v = derefValue(v)
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if !canValidateFields(v.Type()) {
+			return nil, nil
+		}
+		return validateStruct(v, path, partial)
+	case reflect.Slice, reflect.Array:
+		var result []FieldError
+		for i := 0; i < v.Len(); i++ {
+			fieldErrors, err := validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), partial)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, fieldErrors...)
+		}
+		return result, nil
+	}
+
+	return nil, nil
+}
+
+func validateStruct(v reflect.Value, path string, partial bool) ([]FieldError, error) {
+	validation := structFields(v.Type())
+	if validation.err != nil {
+		return nil, validation.err
+	}
+
+	var result []FieldError
+	for _, f := range validation.fields {
+		fieldValue := v.Field(f.index)
+
+		// Fields from embedded structs are validated as if they were part of this struct
+		if f.embedded {
+			fieldErrors, err := validateValue(fieldValue, path, partial)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, fieldErrors...)
+			continue
+		}
+
+		fieldPath := f.name
+		if path != "" {
+			fieldPath = path + "." + f.name
+		}
+
+		if partial && fieldValue.IsZero() {
+			continue
+		}
+
+		fieldErrors, err := validateField(fieldValue, fieldPath, f.rules, partial)
+		if err != nil {
+			return nil, err
+		}
+		if len(fieldErrors) > 0 {
+			result = append(result, fieldErrors...)
+			continue
+		}
+
+		fieldErrors, err = validateValue(fieldValue, fieldPath, partial)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, fieldErrors...)
+	}
+
+	return result, nil
+}
+
+// validateField applies each rule to the field's value and stops at the first failure
+func validateField(v reflect.Value, path string, rules []validationRule, partial bool) ([]FieldError, error) {
+	for _, rule := range rules {
+		if rule.name == "required" {
+			if partial {
+				continue
+			}
+			if v.IsZero() {
+				return []FieldError{{path, rule.name, "is required"}}, nil
+			}
+			continue
+		}
+
+		// Other rules don't apply to nil values, so optional fields can use pointers
+		value := derefValue(v)
+		if !value.IsValid() {
+			return nil, nil
+		}
+
+		message, err := checkRule(value, rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validate tag on field %q: %w", path, err)
+		}
+		if message != "" {
+			return []FieldError{{path, rule.name, message}}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// checkRule returns a message describing the failure or an empty string if the value is valid. The rule's type
+// was already checked when it was parsed unless the field is an interface
+func checkRule(v reflect.Value, rule validationRule) (string, error) {
+	switch rule.name {
+	case "min", "max":
+		size, isLength, ok := ruleSize(v)
+		if !ok {
+			return "", fmt.Errorf("%s is not supported for %s", rule.name, v.Kind())
+		}
+
+		description := "must be"
+		if isLength {
+			description = "length must be"
+		}
+
+		if rule.name == "min" && size < rule.limit {
+			return fmt.Sprintf("%s at least %s", description, rule.param), nil
+		}
+		if rule.name == "max" && size > rule.limit {
+			return fmt.Sprintf("%s at most %s", description, rule.param), nil
+		}
+	case "oneof":
+		value := fmt.Sprint(v.Interface())
+		for _, option := range rule.options {
+			if value == option {
+				return "", nil
+			}
+		}
+		return fmt.Sprintf("must be one of [%s]", strings.Join(rule.options, ", ")), nil
+	case "email":
+		if v.Kind() != reflect.String {
+			return "", fmt.Errorf("email is not supported for %s", v.Kind())
+		}
+		addr, err := mail.ParseAddress(v.String())
+		if err != nil || addr.Address != v.String() {
+			return "must be a valid email address", nil
+		}
+	}
+
+	return "", nil
+}
+
+// ruleSize gets the value used by min and max. This is the length for strings, slices, and maps
+func ruleSize(v reflect.Value) (float64, bool, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true, true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	}
+	return 0, false, false
+}
+
+// hasRuleSize is true for the kinds that ruleSize supports
+func hasRuleSize(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func derefValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// canValidateFields is false for structs that are encoded as a single value, like time.Time and ID
+func canValidateFields(t reflect.Type) bool {
+	if t == timeType {
+		return false
+	}
+	return !t.Implements(textMarshalerType) && !reflect.PointerTo(t).Implements(textMarshalerType)
+}
@@ -0,0 +1,135 @@
+package babyapi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/stretchr/testify/require"
+)
+
+type Address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type Member struct {
+	babyapi.DefaultResource
+	Name      string    `json:"name" validate:"required,min=1,max=10"`
+	Email     string    `json:"email" validate:"required,email"`
+	Plan      string    `json:"plan" validate:"oneof=free pro"`
+	Age       *int      `json:"age" validate:"min=18"`
+	Addresses []Address `json:"addresses"`
+}
+
+func (m *Member) Patch(newMember *Member) *babyapi.ErrResponse {
+	if newMember.Name != "" {
+		m.Name = newMember.Name
+	}
+	if newMember.Plan != "" {
+		m.Plan = newMember.Plan
+	}
+	return nil
+}
+
+func TestValidation(t *testing.T) {
+	api := babyapi.NewAPI[*Member]("Members", "/members", func() *Member { return &Member{} })
+
+	client, stop := babytest.NewTestClient[*Member](t, api)
+	defer stop()
+
+	var member *Member
+	t.Run("ValidPost", func(t *testing.T) {
+		resp, err := client.Post(context.Background(), &Member{Name: "Alice", Email: "alice@example.com", Plan: "free"})
+		require.NoError(t, err)
+		member = resp.Data
+	})
+
+	t.Run("AllFailingFieldsReturned", func(t *testing.T) {
+		young := 16
+		_, err := client.Post(context.Background(), &Member{
+			Name:      "Bartholomew Jr",
+			Email:     "not-an-email",
+			Plan:      "enterprise",
+			Age:       &young,
+			Addresses: []Address{{City: "Tucson"}, {}},
+		})
+		require.Error(t, err)
+
+		var errResp *babyapi.ErrResponse
+		require.True(t, errors.As(err, &errResp))
+		require.Equal(t, http.StatusBadRequest, errResp.HTTPStatusCode)
+		require.Equal(t, []babyapi.FieldError{
+			{Field: "name", Rule: "max", Message: "length must be at most 10"},
+			{Field: "email", Rule: "email", Message: "must be a valid email address"},
+			{Field: "plan", Rule: "oneof", Message: "must be one of [free, pro]"},
+			{Field: "age", Rule: "min", Message: "must be at least 18"},
+			{Field: "addresses[1].city", Rule: "required", Message: "is required"},
+		}, errResp.ValidationErrors)
+	})
+
+	t.Run("RequiredOnPut", func(t *testing.T) {
+		_, err := client.Put(context.Background(), &Member{DefaultResource: member.DefaultResource, Plan: "pro"})
+		require.Error(t, err)
+
+		var errResp *babyapi.ErrResponse
+		require.True(t, errors.As(err, &errResp))
+		require.Equal(t, []babyapi.FieldError{
+			{Field: "name", Rule: "required", Message: "is required"},
+			{Field: "email", Rule: "required", Message: "is required"},
+		}, errResp.ValidationErrors)
+	})
+
+	t.Run("PatchSkipsRequired", func(t *testing.T) {
+		resp, err := client.Patch(context.Background(), member.GetID(), &Member{Plan: "pro"})
+		require.NoError(t, err)
+		require.Equal(t, "pro", resp.Data.Plan)
+		require.Equal(t, "Alice", resp.Data.Name)
+	})
+
+	t.Run("PatchValidatesProvidedFields", func(t *testing.T) {
+		_, err := client.Patch(context.Background(), member.GetID(), &Member{Plan: "gold"})
+		require.Error(t, err)
+
+		var errResp *babyapi.ErrResponse
+		require.True(t, errors.As(err, &errResp))
+		require.Equal(t, []babyapi.FieldError{
+			{Field: "plan", Rule: "oneof", Message: "must be one of [free, pro]"},
+		}, errResp.ValidationErrors)
+	})
+}
+
+func TestInvalidValidateTags(t *testing.T) {
+	type UnknownRule struct {
+		babyapi.DefaultResource
+		Name string `json:"name" validate:"required,uppercase"`
+	}
+
+	type InvalidParameter struct {
+		babyapi.DefaultResource
+		Name string `json:"name" validate:"max=ten"`
+	}
+
+	type NestedEmail struct {
+		Count int `json:"count" validate:"email"`
+	}
+
+	type UnsupportedNested struct {
+		babyapi.DefaultResource
+		Items []*NestedEmail `json:"items"`
+	}
+
+	require.PanicsWithValue(t, `invalid UnknownRules resource: invalid validate tag on field babyapi_test.UnknownRule.Name: unknown rule "uppercase"`, func() {
+		babyapi.NewAPI[*UnknownRule]("UnknownRules", "/unknown", func() *UnknownRule { return &UnknownRule{} })
+	})
+
+	require.Panics(t, func() {
+		babyapi.NewAPI[*InvalidParameter]("InvalidParameters", "/invalid", func() *InvalidParameter { return &InvalidParameter{} })
+	})
+
+	require.PanicsWithValue(t, `invalid Unsupported resource: invalid validate tag on field babyapi_test.NestedEmail.Count: email is not supported for int`, func() {
+		babyapi.NewAPI[*UnsupportedNested]("Unsupported", "/unsupported", func() *UnsupportedNested { return &UnsupportedNested{} })
+	})
+}
@@ -0,0 +1,641 @@
+package babyapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+const (
+	// WebhookSignatureHeader contains the HMAC-SHA256 signature of the request body using the webhook's secret
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	// WebhookEventHeader contains the event that triggered the delivery
+	WebhookEventHeader = "X-Webhook-Event"
+	// WebhookDeliveryHeader contains the delivery ID, which is the same for each attempt of a delivery
+	WebhookDeliveryHeader = "X-Webhook-Delivery"
+
+	webhooksPath = "/webhooks"
+
+	defaultWebhookMaxAttempts    = 5
+	defaultWebhookInitialBackoff = 5 * time.Second
+	defaultWebhookMaxBackoff     = time.Hour
+	defaultWebhookPollInterval   = 5 * time.Second
+	defaultWebhookTimeout        = 10 * time.Second
+	defaultWebhookConcurrency    = 4
+	defaultWebhookRetention      = 7 * 24 * time.Hour
+)
+
+// ErrWebhookTargetNotAllowed is returned when a webhook URL is a loopback, link-local, or private address and
+// WebhookConfig.AllowPrivateTargets is not set
+var ErrWebhookTargetNotAllowed = errors.New("webhook target address is not allowed")
+
+// WebhookEvent is a change to a resource that is sent to webhook subscribers
+type WebhookEvent string
+
+const (
+	WebhookEventCreate WebhookEvent = "create"
+	WebhookEventUpdate WebhookEvent = "update"
+	WebhookEventDelete WebhookEvent = "delete"
+)
+
+// WebhookDeliveryStatus is the state of a WebhookDelivery
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// Webhook is a subscription to events for an API's resources. If Events is empty, all events are sent. The Secret
+// is used to sign deliveries and is not included in responses
+type Webhook struct {
+	DefaultResource
+
+	URL    string         `json:"url" validate:"required"`
+	Events []WebhookEvent `json:"events,omitempty"`
+	Secret string         `json:"secret,omitempty" validate:"required"`
+}
+
+// Bind validates the webhook's URL and events
+func (wh *Webhook) Bind(r *http.Request) error {
+	err := wh.DefaultResource.Bind(r)
+	if err != nil {
+		return err
+	}
+
+	if wh.URL != "" {
+		u, err := url.Parse(wh.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("invalid webhook URL: it must be an absolute http or https URL")
+		}
+	}
+
+	for _, event := range wh.Events {
+		switch event {
+		case WebhookEventCreate, WebhookEventUpdate, WebhookEventDelete:
+		default:
+			return fmt.Errorf("invalid webhook event %q", event)
+		}
+	}
+
+	return nil
+}
+
+func (wh *Webhook) subscribed(event WebhookEvent) bool {
+	return len(wh.Events) == 0 || slices.Contains(wh.Events, event)
+}
+
+// WebhookPayload is the JSON body of a webhook delivery. Previous is only included for update and delete events
+type WebhookPayload struct {
+	ID        string          `json:"id"`
+	API       string          `json:"api"`
+	Event     WebhookEvent    `json:"event"`
+	Resource  json.RawMessage `json:"resource,omitempty"`
+	Previous  json.RawMessage `json:"previous,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// WebhookDelivery is a payload for a single webhook. Pending deliveries are the retry queue and the others are kept
+// as a delivery log
+type WebhookDelivery struct {
+	DefaultResource
+
+	WebhookID      string                `json:"webhookID"`
+	Event          WebhookEvent          `json:"event"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	ResponseStatus int                   `json:"responseStatus,omitempty"`
+	Error          string                `json:"error,omitempty"`
+	CreatedAt      time.Time             `json:"createdAt"`
+	LastAttempt    time.Time             `json:"lastAttempt,omitempty"`
+	NextAttempt    time.Time             `json:"nextAttempt,omitempty"`
+}
+
+// WebhookConfig configures webhook subscriptions and deliveries. Zero values use the defaults
+type WebhookConfig struct {
+	// Authorizer is middleware for the /base/webhooks routes, like AuthenticationMiddleware. It is required because
+	// anyone who can create a webhook receives every change to the API's resources
+	Authorizer func(http.Handler) http.Handler
+	// AllowPrivateTargets allows webhook URLs with loopback, link-local, and private addresses. These are rejected by
+	// default so webhooks can't be used to send requests to internal services. The check is done when connecting, so
+	// a custom Client is responsible for its own checks
+	AllowPrivateTargets bool
+	// Storage is used for webhook subscriptions. It defaults to an in-memory map
+	Storage Storage[*Webhook]
+	// DeliveryStorage is used for the queue of pending deliveries and the delivery log. It defaults to an in-memory map
+	DeliveryStorage Storage[*WebhookDelivery]
+	// Client is used to send deliveries. It defaults to a client with a 10 second timeout that only connects to
+	// allowed addresses
+	Client *http.Client
+	// MaxAttempts is the number of attempts before a delivery fails. It defaults to 5
+	MaxAttempts int
+	// InitialBackoff is the delay after the first failed attempt. It doubles after each attempt and defaults to 5s
+	InitialBackoff time.Duration
+	// MaxBackoff is the longest delay between attempts. It defaults to 1h
+	MaxBackoff time.Duration
+	// PollInterval is how often the pending deliveries are checked by RunWebhookDelivery. It defaults to 5s
+	PollInterval time.Duration
+	// Concurrency is the number of deliveries that are sent at the same time. It defaults to 4
+	Concurrency int
+	// Retention is how long succeeded and failed deliveries are kept in the delivery log. It defaults to 7 days
+	Retention time.Duration
+}
+
+type webhookManager struct {
+	config     WebhookConfig
+	apiName    string
+	api        *API[*Webhook]
+	deliveries *API[*WebhookDelivery]
+
+	notify chan struct{}
+
+	// inFlight has the IDs of deliveries that are being sent so they aren't sent again by a concurrent call
+	inFlight map[string]bool
+	mtx      sync.Mutex
+}
+
+// EnableWebhooks sends create, update, and delete events for this API's resources to webhook subscribers. Webhooks
+// are managed with the /base/webhooks endpoints or AddWebhook and each webhook's deliveries are available at
+// /base/webhooks/{ID}/deliveries. Deliveries are sent in the background by Serve. When using the Router directly,
+// use RunWebhookDelivery or DeliverWebhooks. Panics if the config doesn't have an Authorizer.
+//
+// The webhook routes are static, so they take precedence over a resource with the ID "webhooks" and that resource
+// can't be used with the HTTP API. IDs created by POST never collide with it, but custom IDs used with PUT or Storage
+// should avoid it. The webhook API is not a child of this API, so its Parent is nil
+func (a *API[T]) EnableWebhooks(config WebhookConfig) *API[T] {
+	if a.rootAPI {
+		panic("webhooks cannot be used with a root API")
+	}
+	if config.Authorizer == nil {
+		panic("webhooks require an Authorizer")
+	}
+
+	if config.Storage == nil {
+		config.Storage = newSyncMapStorage[*Webhook]()
+	}
+	if config.DeliveryStorage == nil {
+		config.DeliveryStorage = newSyncMapStorage[*WebhookDelivery]()
+	}
+	if config.Client == nil {
+		config.Client = newWebhookClient(config.AllowPrivateTargets)
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultWebhookMaxAttempts
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = defaultWebhookInitialBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaultWebhookMaxBackoff
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultWebhookPollInterval
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultWebhookConcurrency
+	}
+	if config.Retention <= 0 {
+		config.Retention = defaultWebhookRetention
+	}
+
+	webhookAPI := NewAPI[*Webhook]("Webhooks", webhooksPath, func() *Webhook { return &Webhook{} })
+	webhookAPI.Storage = config.Storage
+	webhookAPI.SetResponseWrapper(func(wh *Webhook) render.Renderer {
+		result := *wh
+		result.Secret = ""
+		return &result
+	})
+	validateTarget := func(r *http.Request, _, wh *Webhook) (*Webhook, *ErrResponse) {
+		err := validateWebhookTarget(wh.URL, config.AllowPrivateTargets)
+		if err != nil {
+			return nil, ErrInvalidRequest(err)
+		}
+		return wh, nil
+	}
+	webhookAPI.AddBeforeCreateHook(validateTarget)
+	webhookAPI.AddBeforeUpdateHook(validateTarget)
+
+	deliveryAPI := NewAPI[*WebhookDelivery]("WebhookDeliveries", "/deliveries", func() *WebhookDelivery { return &WebhookDelivery{} })
+	deliveryAPI.Storage = config.DeliveryStorage
+	deliveryAPI.Post = nil
+	deliveryAPI.Put = nil
+	deliveryAPI.Patch = nil
+	deliveryAPI.Delete = nil
+	deliveryAPI.SetGetAllFilter(func(r *http.Request) FilterFunc[*WebhookDelivery] {
+		webhookID := deliveryAPI.GetParentIDParam(r)
+		return func(d *WebhookDelivery) bool {
+			return d.WebhookID == webhookID
+		}
+	})
+	deliveryAPI.AddAfterReadHook(func(r *http.Request, _, d *WebhookDelivery) (*WebhookDelivery, *ErrResponse) {
+		if d.WebhookID != deliveryAPI.GetParentIDParam(r) {
+			return nil, ErrNotFoundResponse
+		}
+		return d, nil
+	})
+	webhookAPI.AddNestedAPI(deliveryAPI)
+
+	a.webhooks = &webhookManager{
+		config:     config,
+		apiName:    a.name,
+		api:        webhookAPI,
+		deliveries: deliveryAPI,
+		notify:     make(chan struct{}, 1),
+		inFlight:   map[string]bool{},
+	}
+
+	a.AddAfterCreateHook(a.webhookHook(WebhookEventCreate))
+	a.AddAfterUpdateHook(a.webhookHook(WebhookEventUpdate))
+	a.AddAfterDeleteHook(a.webhookHook(WebhookEventDelete))
+
+	return a
+}
+
+// AddWebhook adds a webhook subscription without using the HTTP API. EnableWebhooks must be used first
+func (a *API[T]) AddWebhook(webhook *Webhook) error {
+	if a.webhooks == nil {
+		return errors.New("webhooks are not enabled")
+	}
+	if webhook.GetID() == "" {
+		webhook.DefaultResource = NewDefaultResource()
+	}
+
+	httpErr := validateResource(webhook, false)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	err := validateWebhookTarget(webhook.URL, a.webhooks.config.AllowPrivateTargets)
+	if err != nil {
+		return err
+	}
+
+	return a.webhooks.api.Storage.Set(webhook)
+}
+
+// DeliverWebhooks attempts all pending deliveries that are ready to be sent
+func (a *API[T]) DeliverWebhooks(ctx context.Context) error {
+	if a.webhooks == nil {
+		return errors.New("webhooks are not enabled")
+	}
+	return a.webhooks.deliverPending(ctx)
+}
+
+// RunWebhookDelivery delivers webhooks until the context is cancelled. It runs when new events are queued and on
+// the configured PollInterval to retry failed deliveries
+func (a *API[T]) RunWebhookDelivery(ctx context.Context) {
+	if a.webhooks == nil {
+		return
+	}
+	a.webhooks.run(ctx)
+}
+
+// webhookHook creates deliveries for all webhooks that are subscribed to the event
+func (a *API[T]) webhookHook(event WebhookEvent) Hook[T] {
+	return func(r *http.Request, previous, resource T) (T, *ErrResponse) {
+		resourceJSON, previousJSON, err := a.webhookResources(r, previous, resource)
+		if err == nil {
+			err = a.webhooks.enqueue(event, resourceJSON, previousJSON)
+		}
+		if err != nil {
+			GetLoggerFromContext(r.Context()).Error("error queueing webhook deliveries", "error", err, "event", event)
+		}
+		return resource, nil
+	}
+}
+
+func (wm *webhookManager) enqueue(event WebhookEvent, resourceJSON, previousJSON json.RawMessage) error {
+	webhooks, err := wm.api.Storage.GetAll(func(wh *Webhook) bool {
+		return wh.subscribed(event)
+	})
+	if err != nil {
+		return fmt.Errorf("error getting webhooks: %w", err)
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	for _, wh := range webhooks {
+		delivery := &WebhookDelivery{
+			DefaultResource: NewDefaultResource(),
+			WebhookID:       wh.GetID(),
+			Event:           event,
+			Status:          WebhookDeliveryPending,
+			CreatedAt:       now,
+			NextAttempt:     now,
+		}
+
+		delivery.Payload, err = json.Marshal(WebhookPayload{
+			ID:        delivery.GetID(),
+			API:       wm.apiName,
+			Event:     event,
+			Resource:  resourceJSON,
+			Previous:  previousJSON,
+			Timestamp: now,
+		})
+		if err != nil {
+			return fmt.Errorf("error encoding webhook payload: %w", err)
+		}
+
+		err = wm.deliveries.Storage.Set(delivery)
+		if err != nil {
+			return fmt.Errorf("error storing webhook delivery: %w", err)
+		}
+	}
+
+	select {
+	case wm.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// webhookResources encodes the resources for a payload the same way as responses. Zero values, like the new resource
+// for a delete, are omitted
+func (a *API[T]) webhookResources(r *http.Request, previous, resource T) (json.RawMessage, json.RawMessage, error) {
+	encode := func(resource T) (json.RawMessage, error) {
+		if isNilValue(reflect.ValueOf(resource)) {
+			return nil, nil
+		}
+		return a.renderJSON(r, resource)
+	}
+
+	resourceJSON, err := encode(resource)
+	if err != nil {
+		return nil, nil, err
+	}
+	previousJSON, err := encode(previous)
+	if err != nil {
+		return nil, nil, fmt.Errorf("previous resource: %w", err)
+	}
+
+	return resourceJSON, previousJSON, nil
+}
+
+func (wm *webhookManager) run(ctx context.Context) {
+	ticker := time.NewTicker(wm.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := wm.deliverPending(ctx)
+		if err != nil {
+			slog.Default().Error("error delivering webhooks", "error", err, "api", wm.apiName)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wm.notify:
+		}
+	}
+}
+
+// deliverPending sends the deliveries that are ready. They are sent concurrently without holding the lock so slow
+// receivers don't block other calls, and deliveries that are already being sent are skipped
+func (wm *webhookManager) deliverPending(ctx context.Context) error {
+	pending, err := wm.startPending()
+	if err != nil {
+		return err
+	}
+
+	var (
+		errs    []error
+		errsMtx sync.Mutex
+		wg      sync.WaitGroup
+	)
+	sem := make(chan struct{}, wm.config.Concurrency)
+	for _, delivery := range pending {
+		if ctx.Err() != nil {
+			wm.finish(delivery.GetID())
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(delivery *WebhookDelivery) {
+			defer func() {
+				wm.finish(delivery.GetID())
+				<-sem
+				wg.Done()
+			}()
+
+			// Update a copy so the stored delivery isn't modified while it might be read by another request
+			updated := *delivery
+			wm.attempt(ctx, &updated)
+
+			err := wm.deliveries.Storage.Set(&updated)
+			if err != nil {
+				errsMtx.Lock()
+				errs = append(errs, fmt.Errorf("error storing webhook delivery %q: %w", delivery.GetID(), err))
+				errsMtx.Unlock()
+			}
+		}(delivery)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// startPending gets the deliveries that are ready and marks them as in flight. Old deliveries are also removed from
+// the delivery log
+func (wm *webhookManager) startPending() ([]*WebhookDelivery, error) {
+	wm.mtx.Lock()
+	defer wm.mtx.Unlock()
+
+	err := wm.prune()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	pending, err := wm.deliveries.Storage.GetAll(func(d *WebhookDelivery) bool {
+		return d.Status == WebhookDeliveryPending && !d.NextAttempt.After(now) && !wm.inFlight[d.GetID()]
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting pending deliveries: %w", err)
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+
+	for _, delivery := range pending {
+		wm.inFlight[delivery.GetID()] = true
+	}
+
+	return pending, nil
+}
+
+func (wm *webhookManager) finish(id string) {
+	wm.mtx.Lock()
+	defer wm.mtx.Unlock()
+	delete(wm.inFlight, id)
+}
+
+// prune deletes succeeded and failed deliveries that are older than the Retention
+func (wm *webhookManager) prune() error {
+	cutoff := time.Now().Add(-wm.config.Retention)
+	expired, err := wm.deliveries.Storage.GetAll(func(d *WebhookDelivery) bool {
+		return d.Status != WebhookDeliveryPending && d.LastAttempt.Before(cutoff)
+	})
+	if err != nil {
+		return fmt.Errorf("error getting expired deliveries: %w", err)
+	}
+
+	for _, delivery := range expired {
+		err = wm.deliveries.Storage.Delete(delivery.GetID())
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("error deleting webhook delivery %q: %w", delivery.GetID(), err)
+		}
+	}
+
+	return nil
+}
+
+// attempt sends the delivery and updates its status. Failed deliveries are retried with exponential backoff until
+// MaxAttempts is reached
+func (wm *webhookManager) attempt(ctx context.Context, delivery *WebhookDelivery) {
+	delivery.Attempts++
+	delivery.LastAttempt = time.Now().UTC()
+
+	webhook, err := wm.api.Storage.Get(delivery.WebhookID)
+	if err != nil {
+		delivery.Status = WebhookDeliveryFailed
+		delivery.Error = fmt.Sprintf("error getting webhook: %v", err)
+		return
+	}
+
+	delivery.ResponseStatus, err = wm.send(ctx, webhook, delivery)
+	if err == nil {
+		delivery.Status = WebhookDeliverySucceeded
+		delivery.Error = ""
+		return
+	}
+
+	delivery.Error = err.Error()
+	if delivery.Attempts >= wm.config.MaxAttempts {
+		delivery.Status = WebhookDeliveryFailed
+		return
+	}
+
+	backoff := wm.config.InitialBackoff << (delivery.Attempts - 1)
+	if backoff <= 0 || backoff > wm.config.MaxBackoff {
+		backoff = wm.config.MaxBackoff
+	}
+	delivery.NextAttempt = delivery.LastAttempt.Add(backoff)
+}
+
+func (wm *webhookManager) send(ctx context.Context, webhook *Webhook, delivery *WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", jsonContentType)
+	req.Header.Set(WebhookEventHeader, string(delivery.Event))
+	req.Header.Set(WebhookDeliveryHeader, delivery.GetID())
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, delivery.Payload))
+
+	resp, err := wm.config.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status: %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload creates the value of the WebhookSignatureHeader for a payload
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the WebhookSignatureHeader from a delivery. Receivers should use this before
+// trusting the payload
+func VerifyWebhookSignature(secret string, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhookPayload(secret, payload)), []byte(signature))
+}
+
+// validateWebhookTarget checks that a webhook URL with an IP address host is allowed. Hostnames are checked when
+// connecting since they could resolve to a different address later
+func validateWebhookTarget(target string, allowPrivate bool) error {
+	if allowPrivate {
+		return nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return errors.New("invalid webhook URL")
+	}
+
+	addr, err := netip.ParseAddr(u.Hostname())
+	if err == nil && !allowedWebhookAddr(addr) {
+		return ErrWebhookTargetNotAllowed
+	}
+
+	return nil
+}
+
+// allowedWebhookAddr is false for loopback, link-local, private, multicast, and unspecified addresses
+func allowedWebhookAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
+
+// newWebhookClient creates the default client for deliveries. Unless private targets are allowed, the address is
+// checked after DNS resolution when connecting, which also applies to redirects
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: defaultWebhookTimeout}
+	if !allowPrivate {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			addr, err := netip.ParseAddr(host)
+			if err != nil || !allowedWebhookAddr(addr) {
+				return ErrWebhookTargetNotAllowed
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// A proxy would be dialed instead of the target, so it would bypass the check
+	transport.Proxy = nil
+
+	return &http.Client{Timeout: defaultWebhookTimeout, Transport: transport}
+}
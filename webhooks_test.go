@@ -0,0 +1,406 @@
+package babyapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookReceiver struct {
+	sync.Mutex
+	payloads []babyapi.WebhookPayload
+	failures int
+}
+
+func (wr *webhookReceiver) handler(t *testing.T, secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		wr.Lock()
+		defer wr.Unlock()
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.True(t, babyapi.VerifyWebhookSignature(secret, body, r.Header.Get(babyapi.WebhookSignatureHeader)))
+
+		if wr.failures > 0 {
+			wr.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var payload babyapi.WebhookPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		require.Equal(t, payload.ID, r.Header.Get(babyapi.WebhookDeliveryHeader))
+		require.Equal(t, string(payload.Event), r.Header.Get(babyapi.WebhookEventHeader))
+
+		wr.payloads = append(wr.payloads, payload)
+	}
+}
+
+func (wr *webhookReceiver) received() []babyapi.WebhookPayload {
+	wr.Lock()
+	defer wr.Unlock()
+	return wr.payloads
+}
+
+var webhookAuthorizer = babyapi.AuthenticationMiddleware(&babyapi.APIKeyAuthenticator{
+	Keys: map[string]string{"webhooks": "webhook-key"},
+})
+
+func TestWebhooks(t *testing.T) {
+	receiver := &webhookReceiver{}
+	receiverServer := httptest.NewServer(receiver.handler(t, "secret"))
+	defer receiverServer.Close()
+
+	api := babyapi.NewAPI[*Album]("Albums", "/albums", func() *Album { return &Album{} }).
+		EnableWebhooks(babyapi.WebhookConfig{
+			Authorizer:          webhookAuthorizer,
+			AllowPrivateTargets: true,
+			MaxAttempts:         2,
+			InitialBackoff:      time.Nanosecond,
+		})
+
+	client, stop := babytest.NewTestClient[*Album](t, api)
+	defer stop()
+
+	webhookClient := babyapi.NewClient[*babyapi.Webhook](client.Address, "/albums/webhooks").SetAPIKey("webhook-key")
+
+	ctx := context.Background()
+
+	var webhook *babyapi.Webhook
+	t.Run("CreateWebhook", func(t *testing.T) {
+		resp, err := webhookClient.Post(ctx, &babyapi.Webhook{URL: receiverServer.URL, Secret: "secret"})
+		require.NoError(t, err)
+		require.Empty(t, resp.Data.Secret)
+		webhook = resp.Data
+	})
+
+	t.Run("AuthorizerIsRequired", func(t *testing.T) {
+		_, err := babyapi.NewClient[*babyapi.Webhook](client.Address, "/albums/webhooks").GetAll(ctx, "")
+		requireStatus(t, err, http.StatusUnauthorized)
+
+		require.Panics(t, func() {
+			babyapi.NewAPI[*Album]("Albums", "/albums", func() *Album { return &Album{} }).EnableWebhooks(babyapi.WebhookConfig{})
+		})
+	})
+
+	t.Run("InvalidWebhook", func(t *testing.T) {
+		_, err := webhookClient.Post(ctx, &babyapi.Webhook{URL: "ftp://example.com", Secret: "secret"})
+		require.Error(t, err)
+
+		_, err = webhookClient.Post(ctx, &babyapi.Webhook{URL: receiverServer.URL})
+		require.Error(t, err)
+	})
+
+	var album *Album
+	t.Run("CreateEvent", func(t *testing.T) {
+		resp, err := client.Post(ctx, &Album{Title: "New Album"})
+		require.NoError(t, err)
+		album = resp.Data
+
+		require.NoError(t, api.DeliverWebhooks(ctx))
+
+		payloads := receiver.received()
+		require.Len(t, payloads, 1)
+		require.Equal(t, babyapi.WebhookEventCreate, payloads[0].Event)
+		require.Equal(t, "Albums", payloads[0].API)
+		require.Nil(t, payloads[0].Previous)
+
+		var received Album
+		require.NoError(t, json.Unmarshal(payloads[0].Resource, &received))
+		require.Equal(t, *album, received)
+	})
+
+	t.Run("UpdateEventIsRetried", func(t *testing.T) {
+		receiver.Lock()
+		receiver.failures = 1
+		receiver.Unlock()
+
+		_, err := client.Patch(ctx, album.GetID(), &Album{Title: "Updated"})
+		require.NoError(t, err)
+
+		require.NoError(t, api.DeliverWebhooks(ctx))
+		require.Len(t, receiver.received(), 1)
+
+		require.NoError(t, api.DeliverWebhooks(ctx))
+		payloads := receiver.received()
+		require.Len(t, payloads, 2)
+		require.Equal(t, babyapi.WebhookEventUpdate, payloads[1].Event)
+		require.JSONEq(t, `{"id":"`+album.GetID()+`","title":"New Album"}`, string(payloads[1].Previous))
+		require.JSONEq(t, `{"id":"`+album.GetID()+`","title":"Updated"}`, string(payloads[1].Resource))
+	})
+
+	t.Run("DeliveryLog", func(t *testing.T) {
+		deliveryClient := babyapi.NewSubClient[*babyapi.Webhook, *babyapi.WebhookDelivery](webhookClient, "/deliveries")
+		resp, err := deliveryClient.GetAll(ctx, "", webhook.GetID())
+		require.NoError(t, err)
+		require.Len(t, resp.Data.Items, 2)
+
+		attempts := map[babyapi.WebhookEvent]int{}
+		for _, delivery := range resp.Data.Items {
+			require.Equal(t, babyapi.WebhookDeliverySucceeded, delivery.Status)
+			require.Equal(t, http.StatusOK, delivery.ResponseStatus)
+			attempts[delivery.Event] = delivery.Attempts
+		}
+		require.Equal(t, map[babyapi.WebhookEvent]int{babyapi.WebhookEventCreate: 1, babyapi.WebhookEventUpdate: 2}, attempts)
+
+		_, err = deliveryClient.Get(ctx, "DoesNotExist", webhook.GetID())
+		var errResp *babyapi.ErrResponse
+		require.True(t, errors.As(err, &errResp))
+		require.Equal(t, http.StatusNotFound, errResp.HTTPStatusCode)
+	})
+
+	t.Run("FailedAfterMaxAttempts", func(t *testing.T) {
+		receiver.Lock()
+		receiver.failures = 2
+		receiver.Unlock()
+
+		_, err := client.Put(ctx, &Album{DefaultResource: album.DefaultResource, Title: "Put"})
+		require.NoError(t, err)
+
+		require.NoError(t, api.DeliverWebhooks(ctx))
+		require.NoError(t, api.DeliverWebhooks(ctx))
+		require.NoError(t, api.DeliverWebhooks(ctx))
+		require.Len(t, receiver.received(), 2)
+
+		deliveryClient := babyapi.NewSubClient[*babyapi.Webhook, *babyapi.WebhookDelivery](webhookClient, "/deliveries")
+		resp, err := deliveryClient.GetAll(ctx, "", webhook.GetID())
+		require.NoError(t, err)
+
+		var failed []*babyapi.WebhookDelivery
+		for _, delivery := range resp.Data.Items {
+			if delivery.Status == babyapi.WebhookDeliveryFailed {
+				failed = append(failed, delivery)
+			}
+		}
+		require.Len(t, failed, 1)
+		require.Equal(t, 2, failed[0].Attempts)
+		require.Equal(t, http.StatusServiceUnavailable, failed[0].ResponseStatus)
+	})
+
+	t.Run("EventFilterAndAddWebhook", func(t *testing.T) {
+		deleteReceiver := &webhookReceiver{}
+		deleteServer := httptest.NewServer(deleteReceiver.handler(t, "other-secret"))
+		defer deleteServer.Close()
+
+		require.NoError(t, api.AddWebhook(&babyapi.Webhook{
+			URL:    deleteServer.URL,
+			Events: []babyapi.WebhookEvent{babyapi.WebhookEventDelete},
+			Secret: "other-secret",
+		}))
+
+		_, err := client.Post(ctx, &Album{Title: "Another"})
+		require.NoError(t, err)
+		_, err = client.Delete(ctx, album.GetID())
+		require.NoError(t, err)
+
+		require.NoError(t, api.DeliverWebhooks(ctx))
+
+		payloads := deleteReceiver.received()
+		require.Len(t, payloads, 1)
+		require.Equal(t, babyapi.WebhookEventDelete, payloads[0].Event)
+		require.Nil(t, payloads[0].Resource)
+		require.JSONEq(t, `{"id":"`+album.GetID()+`","title":"Put"}`, string(payloads[0].Previous))
+
+		require.Len(t, receiver.received(), 4)
+	})
+
+	t.Run("RunWebhookDelivery", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			api.RunWebhookDelivery(ctx)
+			close(done)
+		}()
+
+		_, err := client.Post(ctx, &Album{Title: "Background"})
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			return len(receiver.received()) == 5
+		}, time.Second, 10*time.Millisecond)
+
+		cancel()
+		<-done
+	})
+}
+
+type renderedAlbum struct {
+	*Album
+	Rendered bool `json:"rendered"`
+}
+
+func (ra *renderedAlbum) Render(http.ResponseWriter, *http.Request) error {
+	ra.Rendered = true
+	return nil
+}
+
+func TestWebhookSafety(t *testing.T) {
+	receiver := &webhookReceiver{}
+	receiverServer := httptest.NewServer(receiver.handler(t, "secret"))
+	defer receiverServer.Close()
+
+	t.Run("PayloadUsesResponseWrapper", func(t *testing.T) {
+		api := babyapi.NewAPI[*Album]("Albums", "/albums", func() *Album { return &Album{} }).
+			SetResponseWrapper(func(album *Album) render.Renderer {
+				return &renderedAlbum{Album: album}
+			}).
+			EnableWebhooks(babyapi.WebhookConfig{Authorizer: webhookAuthorizer, AllowPrivateTargets: true, Retention: time.Nanosecond})
+		require.NoError(t, api.AddWebhook(&babyapi.Webhook{URL: receiverServer.URL, Secret: "secret"}))
+
+		client, stop := babytest.NewTestClient[*Album](t, api)
+		defer stop()
+
+		ctx := context.Background()
+		resp, err := client.Post(ctx, &Album{Title: "Rendered"})
+		require.NoError(t, err)
+
+		require.NoError(t, api.DeliverWebhooks(ctx))
+		payloads := receiver.received()
+		require.Len(t, payloads, 1)
+		require.JSONEq(t, `{"id":"`+resp.Data.GetID()+`","title":"Rendered","rendered":true}`, string(payloads[0].Resource))
+
+		t.Run("OldDeliveriesArePruned", func(t *testing.T) {
+			webhooks, err := babyapi.NewClient[*babyapi.Webhook](client.Address, "/albums/webhooks").SetAPIKey("webhook-key").GetAll(ctx, "")
+			require.NoError(t, err)
+			require.Len(t, webhooks.Data.Items, 1)
+
+			require.NoError(t, api.DeliverWebhooks(ctx))
+
+			deliveryClient := babyapi.NewSubClient[*babyapi.Webhook, *babyapi.WebhookDelivery](
+				babyapi.NewClient[*babyapi.Webhook](client.Address, "/albums/webhooks").SetAPIKey("webhook-key"),
+				"/deliveries",
+			)
+			deliveries, err := deliveryClient.GetAll(ctx, "", webhooks.Data.Items[0].GetID())
+			require.NoError(t, err)
+			require.Empty(t, deliveries.Data.Items)
+		})
+	})
+
+	t.Run("PrivateTargetsAreRejected", func(t *testing.T) {
+		api := babyapi.NewAPI[*Album]("Albums", "/albums", func() *Album { return &Album{} }).
+			EnableWebhooks(babyapi.WebhookConfig{Authorizer: webhookAuthorizer, MaxAttempts: 1})
+
+		client, stop := babytest.NewTestClient[*Album](t, api)
+		defer stop()
+
+		ctx := context.Background()
+		webhookClient := babyapi.NewClient[*babyapi.Webhook](client.Address, "/albums/webhooks").SetAPIKey("webhook-key")
+
+		for _, target := range []string{
+			receiverServer.URL,
+			"http://10.0.0.1",
+			"http://192.168.1.1:8080",
+			"http://169.254.169.254/latest/meta-data",
+			"http://[::1]:8080",
+			"http://0.0.0.0",
+		} {
+			_, err := webhookClient.Post(ctx, &babyapi.Webhook{URL: target, Secret: "secret"})
+			requireStatus(t, err, http.StatusBadRequest)
+
+			require.ErrorIs(t, api.AddWebhook(&babyapi.Webhook{URL: target, Secret: "secret"}), babyapi.ErrWebhookTargetNotAllowed)
+		}
+
+		// Hostnames are checked after they are resolved
+		_, port, err := net.SplitHostPort(strings.TrimPrefix(receiverServer.URL, "http://"))
+		require.NoError(t, err)
+		webhook, err := webhookClient.Post(ctx, &babyapi.Webhook{URL: "http://localhost:" + port, Secret: "secret"})
+		require.NoError(t, err)
+
+		_, err = client.Post(ctx, &Album{Title: "Private"})
+		require.NoError(t, err)
+		require.NoError(t, api.DeliverWebhooks(ctx))
+
+		deliveryClient := babyapi.NewSubClient[*babyapi.Webhook, *babyapi.WebhookDelivery](webhookClient, "/deliveries")
+		deliveries, err := deliveryClient.GetAll(ctx, "", webhook.Data.GetID())
+		require.NoError(t, err)
+		require.Len(t, deliveries.Data.Items, 1)
+		require.Equal(t, babyapi.WebhookDeliveryFailed, deliveries.Data.Items[0].Status)
+		require.Contains(t, deliveries.Data.Items[0].Error, babyapi.ErrWebhookTargetNotAllowed.Error())
+		require.Zero(t, deliveries.Data.Items[0].ResponseStatus)
+		require.Len(t, receiver.received(), 1)
+	})
+
+	t.Run("SlowDeliveriesDoNotBlock", func(t *testing.T) {
+		release := make(chan struct{})
+		var requests sync.WaitGroup
+		requests.Add(2)
+		var count int32
+		slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&count, 1) <= 2 {
+				requests.Done()
+			}
+			<-release
+		}))
+		defer slowServer.Close()
+
+		api := babyapi.NewAPI[*Album]("Albums", "/albums", func() *Album { return &Album{} }).
+			EnableWebhooks(babyapi.WebhookConfig{Authorizer: webhookAuthorizer, AllowPrivateTargets: true, Concurrency: 2})
+		require.NoError(t, api.AddWebhook(&babyapi.Webhook{URL: slowServer.URL, Secret: "secret"}))
+
+		client, stop := babytest.NewTestClient[*Album](t, api)
+		defer stop()
+
+		ctx := context.Background()
+		for _, title := range []string{"One", "Two"} {
+			_, err := client.Post(ctx, &Album{Title: title})
+			require.NoError(t, err)
+		}
+
+		done := make(chan error)
+		go func() {
+			done <- api.DeliverWebhooks(ctx)
+		}()
+
+		// Both deliveries are sent at the same time and another call skips them while they are in flight
+		requests.Wait()
+		require.NoError(t, api.DeliverWebhooks(ctx))
+		require.Equal(t, int32(2), atomic.LoadInt32(&count))
+
+		close(release)
+		require.NoError(t, <-done)
+	})
+}
+
+func TestWebhooksInChildAPI(t *testing.T) {
+	albumAPI := babyapi.NewAPI[*Album]("Albums", "/albums", func() *Album { return &Album{} }).EnableProblemDetails()
+	songAPI := babyapi.NewAPI[*Song]("Songs", "/songs", func() *Song { return &Song{} }).
+		EnableWebhooks(babyapi.WebhookConfig{Authorizer: webhookAuthorizer, AllowPrivateTargets: true})
+	albumAPI.AddNestedAPI(songAPI)
+
+	album := &Album{DefaultResource: babyapi.NewDefaultResource(), Title: "Album"}
+	require.NoError(t, albumAPI.Storage.Set(album))
+	webhook := &babyapi.Webhook{DefaultResource: babyapi.NewDefaultResource(), URL: "http://localhost", Secret: "secret"}
+	require.NoError(t, songAPI.AddWebhook(webhook))
+
+	request := func(path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/albums/"+album.GetID()+"/songs/webhooks"+path, http.NoBody)
+		r.Header.Set(babyapi.APIKeyHeader, "webhook-key")
+		return babytest.TestRequest[*Album](t, albumAPI, r)
+	}
+
+	t.Run("DeliveriesUseWebhookID", func(t *testing.T) {
+		w := request("/" + webhook.GetID() + "/deliveries")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, `{"items":[]}`, w.Body.String())
+	})
+
+	t.Run("ErrorsUseParentFormat", func(t *testing.T) {
+		w := request("/DoesNotExist")
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	})
+}
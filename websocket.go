@@ -0,0 +1,334 @@
+package babyapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	webSocketPath = "/ws"
+
+	webSocketWriteTimeout = 10 * time.Second
+)
+
+// WebSocketMessageType identifies the kind of message sent on the WebSocket connection
+type WebSocketMessageType string
+
+const (
+	// WebSocketMessageEvent is sent by the server for each change event
+	WebSocketMessageEvent WebSocketMessageType = "event"
+	// WebSocketMessageCommand is sent by the client to create, update, or delete a resource
+	WebSocketMessageCommand WebSocketMessageType = "command"
+	// WebSocketMessageResponse is sent by the server with the result of a command
+	WebSocketMessageResponse WebSocketMessageType = "response"
+)
+
+// WebSocketAction is the operation requested by a WebSocket command
+type WebSocketAction string
+
+const (
+	// WebSocketActionCreate uses POST /base
+	WebSocketActionCreate WebSocketAction = "create"
+	// WebSocketActionUpdate uses PUT /base/{ID}
+	WebSocketActionUpdate WebSocketAction = "update"
+	// WebSocketActionPatch uses PATCH /base/{ID}
+	WebSocketActionPatch WebSocketAction = "patch"
+	// WebSocketActionDelete uses DELETE /base/{ID}
+	WebSocketActionDelete WebSocketAction = "delete"
+)
+
+var webSocketActionMethods = map[WebSocketAction]string{
+	WebSocketActionCreate: http.MethodPost,
+	WebSocketActionUpdate: http.MethodPut,
+	WebSocketActionPatch:  http.MethodPatch,
+	WebSocketActionDelete: http.MethodDelete,
+}
+
+// WebSocketMessage is the JSON message sent in both directions on the WebSocket connection.
+//   - Events have the Event name, the event ID in ID, and the resource in Data
+//   - Commands have an Action, the ResourceID for update, patch, and delete, the request body in Data, and an ID that
+//     is used as the correlation ID for the response
+//   - Responses have the command's ID, the HTTP Status, and either the response body in Data or the error in Error
+type WebSocketMessage struct {
+	Type       WebSocketMessageType `json:"type"`
+	ID         string               `json:"id,omitempty"`
+	Event      string               `json:"event,omitempty"`
+	Action     WebSocketAction      `json:"action,omitempty"`
+	ResourceID string               `json:"resourceID,omitempty"`
+	Status     int                  `json:"status,omitempty"`
+	Data       json.RawMessage      `json:"data,omitempty"`
+	Error      *ErrResponse         `json:"error,omitempty"`
+}
+
+// EnableWebSocket adds a GET /base/ws endpoint that upgrades to a WebSocket connection. The server sends the same
+// change events as the change stream, which is enabled automatically, and filters them the same way. Clients can send
+// commands to create, update, patch, or delete resources. Commands are handled as HTTP requests by the API's router
+// with the headers from the WebSocket request, so they use the same middleware, hooks, validation, and authorization
+func (a *API[T]) EnableWebSocket() *API[T] {
+	a.EnableChangeStream()
+	a.webSocket = true
+	return a
+}
+
+func (a *API[T]) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerFromContext(r.Context())
+
+	// The router that is serving this request is used to handle commands so they go through all parent APIs' routes
+	// and middleware
+	router, ok := chi.RouteContext(r.Context()).Routes.(http.Handler)
+	if !ok {
+		router = a.Router()
+	}
+	collectionPath := strings.TrimSuffix(r.URL.Path, webSocketPath)
+
+	html := acceptsHTMLEvents(r)
+	requestFilter := a.requestFilter(r)
+	changes, missed := getListenerForRequest(a.changes, r,
+		func(change *resourceChange[T]) bool { return requestFilter(change.resource) },
+		queryParamFilter(r, func(change *resourceChange[T]) (string, string) {
+			return change.event, change.resource.GetID()
+		}),
+	)
+	defer a.changes.RemoveListener(changes)
+
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade already wrote the error response
+		logger.Error("error upgrading to websocket", "error", err)
+		return
+	}
+
+	ws := &webSocketConn{Conn: conn}
+	defer ws.Close()
+
+	// Commands use a new context because the WebSocket request's context has routing details that would affect the
+	// command's request
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := context.AfterFunc(r.Context(), cancel)
+	defer stop()
+
+	go func() {
+		defer cancel()
+		for {
+			var msg WebSocketMessage
+			err := conn.ReadJSON(&msg)
+			if err != nil {
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					logger.Debug("websocket connection closed", "error", err)
+				}
+				return
+			}
+
+			err = ws.writeJSON(a.handleWebSocketCommand(ctx, r, router, collectionPath, msg))
+			if err != nil {
+				logger.Error("error writing websocket response", "error", err)
+				return
+			}
+		}
+	}()
+
+	writeChange := func(change *resourceChange[T]) {
+		data, err := a.renderChange(r, change.resource, html)
+		if err != nil {
+			logger.Error("error rendering change event", "error", err)
+			return
+		}
+
+		if html {
+			data = strconv.Quote(data)
+		}
+
+		err = ws.writeJSON(WebSocketMessage{
+			Type:  WebSocketMessageEvent,
+			ID:    strconv.FormatUint(change.id, 10),
+			Event: change.event,
+			Data:  json.RawMessage(data),
+		})
+		if err != nil {
+			logger.Error("error writing websocket event", "error", err)
+			cancel()
+		}
+	}
+
+	for _, change := range missed {
+		writeChange(change)
+	}
+
+	var pings <-chan time.Time
+	if heartbeat := a.heartbeatInterval(); heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		pings = ticker.C
+	}
+
+	for {
+		select {
+		case change, ok := <-changes:
+			// The listener was disconnected for being too slow
+			if !ok {
+				ws.close(websocket.CloseTryAgainLater, "too slow")
+				return
+			}
+			writeChange(change)
+		case <-pings:
+			err := ws.ping()
+			if err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		case <-a.Done():
+			ws.close(websocket.CloseGoingAway, "server shutting down")
+			return
+		}
+	}
+}
+
+// handleWebSocketCommand runs the command as an HTTP request and creates the response message
+func (a *API[T]) handleWebSocketCommand(ctx context.Context, r *http.Request, router http.Handler, collectionPath string, msg WebSocketMessage) WebSocketMessage {
+	response := WebSocketMessage{Type: WebSocketMessageResponse, ID: msg.ID}
+
+	method, ok := webSocketActionMethods[msg.Action]
+	switch {
+	case msg.Type != WebSocketMessageCommand:
+		response.Error = ErrInvalidRequest(fmt.Errorf("unsupported message type %q", msg.Type))
+	case !ok:
+		response.Error = ErrInvalidRequest(fmt.Errorf("unsupported action %q", msg.Action))
+	case msg.Action != WebSocketActionCreate && msg.ResourceID == "":
+		response.Error = ErrInvalidRequest(fmt.Errorf("resourceID is required for %q", msg.Action))
+	}
+	if response.Error != nil {
+		response.Status = response.Error.HTTPStatusCode
+		return response
+	}
+
+	path := collectionPath
+	if msg.Action != WebSocketActionCreate {
+		path += "/" + url.PathEscape(msg.ResourceID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, path, bytes.NewReader(msg.Data))
+	if err != nil {
+		response.Error = InternalServerError(err)
+		response.Status = response.Error.HTTPStatusCode
+		return response
+	}
+
+	// Headers from the WebSocket request are used so authentication applies to commands
+	req.Header = r.Header.Clone()
+	for _, header := range []string{"Connection", "Upgrade", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions", "Sec-Websocket-Protocol"} {
+		req.Header.Del(header)
+	}
+	// Browsers can't set headers on WebSocket requests, so the CSRF token can be a query parameter
+	if token := r.URL.Query().Get(CSRFFormField); token != "" {
+		req.Header.Set(CSRFHeader, token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.RemoteAddr = r.RemoteAddr
+	req.Host = r.Host
+
+	w := &commandResponseWriter{header: http.Header{}}
+	router.ServeHTTP(w, req)
+
+	response.Status = w.status()
+
+	body := bytes.TrimSpace(w.body.Bytes())
+	if response.Status >= http.StatusBadRequest {
+		response.Error = decodeCommandError(w.header.Get("Content-Type"), body)
+		response.Error.HTTPStatusCode = response.Status
+		return response
+	}
+
+	if json.Valid(body) {
+		response.Data = body
+	}
+
+	return response
+}
+
+// decodeCommandError reads an error response body as an ErrResponse or ProblemDetails. Other bodies are used as the
+// error text
+func decodeCommandError(contentType string, body []byte) *ErrResponse {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == problemJSONContentType {
+		var problem ProblemDetails
+		if json.Unmarshal(body, &problem) == nil {
+			return problem.ErrResponse()
+		}
+	}
+
+	var errResp ErrResponse
+	if json.Unmarshal(body, &errResp) == nil && errResp.StatusText != "" {
+		return &errResp
+	}
+
+	return &ErrResponse{StatusText: "Command failed.", ErrorText: string(body)}
+}
+
+// webSocketConn serializes writes because gorilla/websocket allows only one concurrent writer
+type webSocketConn struct {
+	*websocket.Conn
+	lock sync.Mutex
+}
+
+func (c *webSocketConn) writeJSON(v any) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_ = c.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+	return c.WriteJSON(v)
+}
+
+func (c *webSocketConn) ping() error {
+	return c.WriteControl(websocket.PingMessage, nil, time.Now().Add(webSocketWriteTimeout))
+}
+
+func (c *webSocketConn) close(code int, text string) {
+	_ = c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(webSocketWriteTimeout))
+}
+
+// commandResponseWriter collects the response to a WebSocket command
+type commandResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (w *commandResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *commandResponseWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *commandResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+func (w *commandResponseWriter) status() int {
+	if w.statusCode == 0 {
+		return http.StatusOK
+	}
+	return w.statusCode
+}
@@ -0,0 +1,173 @@
+package babyapi_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/calvinmclean/babyapi"
+	babytest "github.com/calvinmclean/babyapi/test"
+	"github.com/go-chi/render"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// readWebSocketMessages reads n messages and returns them by type since events and responses can arrive in any order
+func readWebSocketMessages(t *testing.T, conn *websocket.Conn, n int) map[babyapi.WebSocketMessageType]babyapi.WebSocketMessage {
+	t.Helper()
+
+	messages := map[babyapi.WebSocketMessageType]babyapi.WebSocketMessage{}
+	for i := 0; i < n; i++ {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+		var msg babyapi.WebSocketMessage
+		require.NoError(t, conn.ReadJSON(&msg))
+		messages[msg.Type] = msg
+	}
+	return messages
+}
+
+func TestWebSocket(t *testing.T) {
+	api := babyapi.NewAPI[*Memo]("Memos", "/memos", func() *Memo { return &Memo{} }).EnableWebSocket()
+	api.SetResponseWrapper(func(m *Memo) render.Renderer {
+		return &memoResponse{Memo: m}
+	})
+	api.AddBeforeCreateHook(func(_ *http.Request, _, memo *Memo) (*Memo, *babyapi.ErrResponse) {
+		if memo.Text == "" {
+			return nil, babyapi.ErrInvalidRequest(errors.New("text is required"))
+		}
+		return memo, nil
+	})
+	api.AddMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "secret" {
+				_ = babyapi.Render(w, r, babyapi.ErrForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	address, stop := babytest.TestServe[*Memo](t, api)
+	defer stop()
+
+	wsAddress := "ws" + strings.TrimPrefix(address, "http") + "/memos/ws"
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(wsAddress, nil)
+		require.ErrorIs(t, err, websocket.ErrBadHandshake)
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsAddress, http.Header{"Authorization": {"secret"}})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var created Memo
+	t.Run("Create", func(t *testing.T) {
+		require.NoError(t, conn.WriteJSON(babyapi.WebSocketMessage{
+			Type:   babyapi.WebSocketMessageCommand,
+			ID:     "request-1",
+			Action: babyapi.WebSocketActionCreate,
+			Data:   json.RawMessage(`{"text": "hello"}`),
+		}))
+
+		messages := readWebSocketMessages(t, conn, 2)
+
+		response := messages[babyapi.WebSocketMessageResponse]
+		require.Equal(t, "request-1", response.ID)
+		require.Equal(t, http.StatusCreated, response.Status)
+		require.Nil(t, response.Error)
+		require.NoError(t, json.Unmarshal(response.Data, &created))
+		require.Equal(t, "hello", created.Text)
+
+		event := messages[babyapi.WebSocketMessageEvent]
+		require.Equal(t, babyapi.ChangeEventCreated, event.Event)
+		require.Equal(t, "1", event.ID)
+		require.JSONEq(t, `{"id": "`+created.GetID()+`", "text": "hello", "length": 5}`, string(event.Data))
+	})
+
+	t.Run("UpdateAndDelete", func(t *testing.T) {
+		require.NoError(t, conn.WriteJSON(babyapi.WebSocketMessage{
+			Type:       babyapi.WebSocketMessageCommand,
+			ID:         "request-2",
+			Action:     babyapi.WebSocketActionUpdate,
+			ResourceID: created.GetID(),
+			Data:       json.RawMessage(`{"id": "` + created.GetID() + `", "text": "updated"}`),
+		}))
+
+		messages := readWebSocketMessages(t, conn, 2)
+		require.Equal(t, "request-2", messages[babyapi.WebSocketMessageResponse].ID)
+		require.Equal(t, http.StatusOK, messages[babyapi.WebSocketMessageResponse].Status)
+		require.Equal(t, babyapi.ChangeEventUpdated, messages[babyapi.WebSocketMessageEvent].Event)
+
+		require.NoError(t, conn.WriteJSON(babyapi.WebSocketMessage{
+			Type:       babyapi.WebSocketMessageCommand,
+			ID:         "request-3",
+			Action:     babyapi.WebSocketActionDelete,
+			ResourceID: created.GetID(),
+		}))
+
+		messages = readWebSocketMessages(t, conn, 2)
+		require.Equal(t, "request-3", messages[babyapi.WebSocketMessageResponse].ID)
+		require.Equal(t, http.StatusNoContent, messages[babyapi.WebSocketMessageResponse].Status)
+		require.Empty(t, messages[babyapi.WebSocketMessageResponse].Data)
+		require.Equal(t, babyapi.ChangeEventDeleted, messages[babyapi.WebSocketMessageEvent].Event)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := []struct {
+			name           string
+			msg            babyapi.WebSocketMessage
+			expectedStatus int
+			expectedError  string
+		}{
+			{
+				"HookError",
+				babyapi.WebSocketMessage{Type: babyapi.WebSocketMessageCommand, Action: babyapi.WebSocketActionCreate, Data: json.RawMessage(`{}`)},
+				http.StatusBadRequest,
+				"text is required",
+			},
+			{
+				"NotFound",
+				babyapi.WebSocketMessage{Type: babyapi.WebSocketMessageCommand, Action: babyapi.WebSocketActionDelete, ResourceID: created.GetID()},
+				http.StatusNotFound,
+				"",
+			},
+			{
+				"UnknownAction",
+				babyapi.WebSocketMessage{Type: babyapi.WebSocketMessageCommand, Action: "archive"},
+				http.StatusBadRequest,
+				`unsupported action "archive"`,
+			},
+			{
+				"MissingResourceID",
+				babyapi.WebSocketMessage{Type: babyapi.WebSocketMessageCommand, Action: babyapi.WebSocketActionPatch},
+				http.StatusBadRequest,
+				`resourceID is required for "patch"`,
+			},
+			{
+				"UnknownType",
+				babyapi.WebSocketMessage{Type: babyapi.WebSocketMessageEvent},
+				http.StatusBadRequest,
+				`unsupported message type "event"`,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				tt.msg.ID = tt.name
+				require.NoError(t, conn.WriteJSON(tt.msg))
+
+				response := readWebSocketMessages(t, conn, 1)[babyapi.WebSocketMessageResponse]
+				require.Equal(t, tt.name, response.ID)
+				require.Equal(t, tt.expectedStatus, response.Status)
+				require.NotNil(t, response.Error)
+				require.Equal(t, tt.expectedError, response.Error.ErrorText)
+			})
+		}
+	})
+}